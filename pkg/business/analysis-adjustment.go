@@ -0,0 +1,103 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"slices"
+	"time"
+
+	"github.com/algotiqa/core/req"
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+//--- Split ratio is new shares per old share (2 for a 2:1 split), 0 when there is no
+//--- split. Dividend is the cash amount per share. Date is the ex-date
+
+type CorporateAction struct {
+	Date       time.Time
+	SplitRatio float64
+	Dividend   float64
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- Back-adjusts prices before each action, so that the series has no artificial
+//--- jumps on ex-dates. The original data points are left untouched
+
+func adjustDataPoints(dataPoints []*ds.DataPoint, actions []CorporateAction) ([]*ds.DataPoint, error) {
+	if len(actions) == 0 {
+		return dataPoints, nil
+	}
+
+	for _, ca := range actions {
+		if ca.SplitRatio < 0 || ca.Dividend < 0 {
+			return nil, req.NewBadRequestError("Bad corporate action on "+ ca.Date.Format(time.DateOnly) +" (split ratio and dividend cannot be negative)")
+		}
+	}
+
+	actions = slices.Clone(actions)
+	slices.SortFunc(actions, func(a, b CorporateAction) int {
+		return a.Date.Compare(b.Date)
+	})
+
+	list   := cloneDataPoints(dataPoints)
+	factor := 1.0
+	next   := len(actions) -1
+
+	for i := len(list) -1; i >= 0; i-- {
+		dp := list[i]
+
+		//--- dp is the last bar before the actions' ex-dates
+
+		for next >= 0 && dp.Time.Before(actions[next].Date) {
+			f, err := adjustmentFactor(actions[next], dp.Close)
+			if err != nil {
+				return nil, err
+			}
+
+			factor *= f
+			next--
+		}
+
+		dp.Open  *= factor
+		dp.High  *= factor
+		dp.Low   *= factor
+		dp.Close *= factor
+	}
+
+	return list, nil
+}
+
+//=============================================================================
+
+func adjustmentFactor(ca CorporateAction, prevClose float64) (float64, error) {
+	factor := 1.0
+
+	if ca.SplitRatio > 0 {
+		factor /= ca.SplitRatio
+	}
+
+	if ca.Dividend > 0 {
+		if prevClose <= ca.Dividend {
+			return 0, req.NewBadRequestError("Bad corporate action on "+ ca.Date.Format(time.DateOnly) +" (dividend "+ formatFloat(ca.Dividend) +" is not lower than the previous close "+ formatFloat(prevClose) +")")
+		}
+
+		factor *= 1 - ca.Dividend / prevClose
+	}
+
+	return factor, nil
+}
+
+//=============================================================================
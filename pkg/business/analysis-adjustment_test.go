@@ -0,0 +1,93 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"math"
+	"testing"
+)
+
+//=============================================================================
+
+func TestSplitAdjustment(t *testing.T) {
+	points  := buildDataPoints([]float64{ 100, 102, 104, 52, 53 })
+	actions := []CorporateAction{{ Date: points[3].Time, SplitRatio: 2 }}
+
+	list, err := adjustDataPoints(points, actions)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []float64{ 50, 51, 52, 52, 53 }
+
+	for i, dp := range list {
+		if math.Abs(dp.Close - expected[i]) > 1e-9 {
+			t.Errorf("Bad adjusted close at %d. Got %v, expected %v", i, dp.Close, expected[i])
+		}
+	}
+
+	if list[0].High != 50.5 || list[0].Low != 49.5 {
+		t.Errorf("High and low must be adjusted too. Got %v / %v", list[0].High, list[0].Low)
+	}
+
+	if points[0].Close != 100 {
+		t.Errorf("Original data points must not be changed. Got %v", points[0].Close)
+	}
+
+	//--- The jump on the ex-date is gone
+
+	results := createBarResults(list, defaultParams())
+	if change := results[2].BarChangePerc; change != 0 {
+		t.Errorf("Split must not produce a price change. Got %v", change)
+	}
+}
+
+//=============================================================================
+
+func TestDividendAdjustment(t *testing.T) {
+	points  := buildDataPoints([]float64{ 100, 101, 99, 99.5 })
+	actions := []CorporateAction{{ Date: points[2].Time, Dividend: 2 }}
+
+	list, err := adjustDataPoints(points, actions)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	factor := 1 - 2.0/101
+
+	if math.Abs(list[1].Close - 101*factor) > 1e-9 || math.Abs(list[0].Close - 100*factor) > 1e-9 {
+		t.Errorf("Bad dividend adjustment. Got %v, %v", list[0].Close, list[1].Close)
+	}
+
+	if list[2].Close != 99 || list[3].Close != 99.5 {
+		t.Errorf("Bars from the ex-date on must not be adjusted")
+	}
+
+	//--- The drop on the ex-date is (almost) entirely explained by the dividend
+
+	results := createBarResults(list, defaultParams())
+	if change := results[1].BarChangePerc; math.Abs(change) > 0.001 {
+		t.Errorf("Dividend must not produce a large price change. Got %v", change)
+	}
+
+	//--- No actions, no changes
+
+	same, _ := adjustDataPoints(points, nil)
+	if &same[0] != &points[0] {
+		t.Errorf("Data points must be returned as they are without actions")
+	}
+
+	if _, err = adjustDataPoints(points, []CorporateAction{{ Date: points[1].Time, Dividend: 200 }}); err == nil {
+		t.Errorf("A dividend larger than the price must be rejected")
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,222 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/algotiqa/data-collector/pkg/core"
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+//--- Time the data points are kept. Bars closing meanwhile are not seen until then
+
+const DefaultDataPointCacheTTL = 5 * time.Minute
+
+//=============================================================================
+
+type Clock func() time.Time
+
+//=============================================================================
+
+type DataPointCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	now     Clock
+	entries map[dataPointKey]*dataPointEntry
+	hits    int
+	misses  int
+}
+
+//=============================================================================
+
+type dataPointKey struct {
+	symbol      string
+	selector    any
+	userTable   bool
+	timeframe   int
+	from        int64
+	to          int64
+	limit       int
+	location    string
+	session     string
+	aggregation string
+}
+
+//=============================================================================
+
+type dataPointEntry struct {
+	dataPoints []*ds.DataPoint
+	expiry     time.Time
+}
+
+//=============================================================================
+//--- Cache used by the analysis. It is disabled when nil
+
+var analysisCache *DataPointCache
+
+//=============================================================================
+
+func NewDataPointCache(ttl time.Duration, now Clock) *DataPointCache {
+	if now == nil {
+		now = time.Now
+	}
+
+	return &DataPointCache{
+		ttl    : ttl,
+		now    : now,
+		entries: map[dataPointKey]*dataPointEntry{},
+	}
+}
+
+//=============================================================================
+
+func SetDataPointCache(cache *DataPointCache) {
+	analysisCache = cache
+}
+
+//=============================================================================
+
+func (c *DataPointCache) Stats() (hits int, misses int) {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.hits, c.misses
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func getCachedDataPoints(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+	if analysisCache == nil {
+		return getDataPoints(ctx, params, config)
+	}
+
+	return analysisCache.get(newDataPointKey(params, config), func() ([]*ds.DataPoint, error) {
+		return getDataPoints(ctx, params, config)
+	})
+}
+
+//=============================================================================
+
+func (c *DataPointCache) get(key dataPointKey, load func() ([]*ds.DataPoint, error)) ([]*ds.DataPoint, error) {
+	c.Lock()
+	entry, ok := c.entries[key]
+	now       := c.now()
+
+	if ok && now.Before(entry.expiry) {
+		c.hits++
+		c.Unlock()
+		return cloneDataPoints(entry.dataPoints), nil
+	}
+
+	c.misses++
+	delete(c.entries, key)
+	c.Unlock()
+
+	//--- Loading is done outside the lock to not block other symbols
+
+	dataPoints, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Lock()
+	c.evictExpired(now)
+	c.entries[key] = &dataPointEntry{
+		dataPoints: cloneDataPoints(dataPoints),
+		expiry    : now.Add(c.ttl),
+	}
+	c.Unlock()
+
+	return dataPoints, nil
+}
+
+//=============================================================================
+
+func (c *DataPointCache) evictExpired(now time.Time) {
+	for key, entry := range c.entries {
+		if !now.Before(entry.expiry) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+//=============================================================================
+
+func newDataPointKey(params *QueryParams, config *core.QueryConfig) dataPointKey {
+	key := dataPointKey{
+		symbol     : config.DataConfig.Symbol,
+		selector   : config.DataConfig.Selector,
+		userTable  : config.DataConfig.UserTable,
+		timeframe  : params.Timeframe,
+		limit      : params.Limit,
+		aggregation: params.Aggregation,
+	}
+
+	//--- Ranges computed from the current time (like with daysBack) would never hit:
+	//--- bounds are moved to the bar times, so that ranges with the same bars match
+
+	step := time.Duration(params.Timeframe) * time.Minute
+
+	if params.From != nil {
+		from := params.From.Truncate(step)
+		if from.Before(*params.From) {
+			from = from.Add(step)
+		}
+
+		key.from = from.Unix()
+	}
+
+	if params.To != nil {
+		key.to = params.To.Truncate(step).Unix()
+	}
+
+	if params.TargetLoc != nil {
+		key.location = params.TargetLoc.String()
+	}
+
+	//--- Daily bars are aggregated on the trading session
+
+	if config.TradingSession != nil {
+		session, _ := json.Marshal(config.TradingSession)
+		key.session = string(session)
+	}
+
+	return key
+}
+
+//=============================================================================
+//--- Cached data points are shared, so callers always get their own copy
+
+func cloneDataPoints(dataPoints []*ds.DataPoint) []*ds.DataPoint {
+	if dataPoints == nil {
+		return nil
+	}
+
+	list := make([]*ds.DataPoint, len(dataPoints))
+
+	for i, dp := range dataPoints {
+		clone  := *dp
+		list[i] = &clone
+	}
+
+	return list
+}
+
+//=============================================================================
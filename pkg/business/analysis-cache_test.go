@@ -0,0 +1,139 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"testing"
+	"time"
+
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (fc *fakeClock) Now() time.Time {
+	return fc.now
+}
+
+//=============================================================================
+
+func TestDataPointCacheHitAndMiss(t *testing.T) {
+	clock := &fakeClock{ now: startTime }
+	cache := NewDataPointCache(time.Minute, clock.Now)
+	key   := dataPointKey{ symbol: "ES", timeframe: 1440 }
+	loads := 0
+
+	load := func() ([]*ds.DataPoint, error) {
+		loads++
+		return buildDataPoints([]float64{ 100, 101, 102 }), nil
+	}
+
+	first,  _ := cache.get(key, load)
+	second, _ := cache.get(key, load)
+
+	if hits, misses := cache.Stats(); hits != 1 || misses != 1 || loads != 1 {
+		t.Errorf("Expected 1 hit and 1 miss. Got %d hits, %d misses, %d loads", hits, misses, loads)
+	}
+
+	//--- Hits must not share data with previous callers
+
+	first[0].Close = 0
+	if second[0].Close != 100 {
+		t.Errorf("Cached data points must be cloned. Got close %f", second[0].Close)
+	}
+
+	third, _ := cache.get(key, load)
+	if third[0].Close != 100 {
+		t.Errorf("Callers must not alter the cache. Got close %f", third[0].Close)
+	}
+
+	//--- A different key is a miss
+
+	_, _ = cache.get(dataPointKey{ symbol: "ES", timeframe: 60 }, load)
+
+	if hits, misses := cache.Stats(); hits != 2 || misses != 2 {
+		t.Errorf("Expected 2 hits and 2 misses. Got %d hits, %d misses", hits, misses)
+	}
+}
+
+//=============================================================================
+
+func TestDataPointCacheExpiry(t *testing.T) {
+	clock := &fakeClock{ now: startTime }
+	cache := NewDataPointCache(time.Minute, clock.Now)
+	key   := dataPointKey{ symbol: "ES", timeframe: 1440 }
+	loads := 0
+
+	load := func() ([]*ds.DataPoint, error) {
+		loads++
+		return buildDataPoints([]float64{ 100, 101 }), nil
+	}
+
+	_, _ = cache.get(key, load)
+
+	clock.now = clock.now.Add(59 * time.Second)
+	_, _ = cache.get(key, load)
+
+	clock.now = clock.now.Add(time.Second)
+	_, _ = cache.get(key, load)
+
+	if hits, misses := cache.Stats(); hits != 1 || misses != 2 || loads != 2 {
+		t.Errorf("Entry must expire after the TTL. Got %d hits, %d misses, %d loads", hits, misses, loads)
+	}
+}
+
+//=============================================================================
+
+func TestDataPointKey(t *testing.T) {
+	config := buildQueryConfig()
+
+	params := func(from time.Time, days int) *QueryParams {
+		to := from.AddDate(0, 0, days)
+		return &QueryParams{ From: &from, To: &to, Timeframe: 60, TargetLoc: time.UTC, Aggregation: AggregationLast }
+	}
+
+	//--- Ranges taken from the current time a few seconds apart
+
+	now := time.Date(2024, 6, 3, 10, 15, 7, 123, time.UTC)
+	key := newDataPointKey(params(now, 30), config)
+
+	if other := newDataPointKey(params(now.Add(3 * time.Second), 30), config); other != key {
+		t.Errorf("Ranges with the same bars must share the key. Got %+v and %+v", key, other)
+	}
+
+	if other := newDataPointKey(params(now.Add(time.Hour), 30), config); other == key {
+		t.Errorf("Ranges with other bars must not share the key")
+	}
+
+	//--- And on the aggregation mode
+
+	median := params(now, 30)
+	median.Aggregation = AggregationMedian
+
+	if other := newDataPointKey(median, config); other == key {
+		t.Errorf("Another aggregation mode must not share the key")
+	}
+
+	//--- Bars are aggregated on the session
+
+	session := *config
+	session.TradingSession = nil
+
+	if other := newDataPointKey(params(now, 30), &session); other == key {
+		t.Errorf("Another session must not share the key")
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,114 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+//=============================================================================
+//--- Days the market is expected to trade on: a weekday mask plus a list of holidays
+
+type TradingCalendar struct {
+	weekdays [7]bool
+	holidays map[string]bool
+}
+
+//=============================================================================
+//--- Used for daily gaps when the spec has no calendar
+
+var weekdayCalendar = &TradingCalendar{
+	weekdays: [7]bool{ false, true, true, true, true, true, false },
+}
+
+//=============================================================================
+//--- The calendar is given either as a weekday mask with ISO numbers (e.g. "12345"
+//--- for Monday to Friday) or as a comma separated list of holidays (e.g.
+//--- "2024-05-27,2024-07-04") on top of a Monday to Friday week
+
+func NewTradingCalendar(value string) (*TradingCalendar, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	tc := &TradingCalendar{
+		holidays: map[string]bool{},
+	}
+
+	if strings.Contains(value, "-") {
+		for _, day := range strings.Split(value, ",") {
+			day = strings.TrimSpace(day)
+			if _, err := time.Parse(time.DateOnly, day); err != nil {
+				return nil, errors.New("bad holiday '"+ day +"', expected format is YYYY-MM-DD")
+			}
+
+			tc.holidays[day] = true
+		}
+
+		value = "12345"
+	}
+
+	for _, c := range value {
+		if c < '1' || c > '7' {
+			return nil, errors.New("weekday mask must contain only digits from 1 (Monday) to 7 (Sunday)")
+		}
+
+		tc.weekdays[int(c - '0') % 7] = true
+	}
+
+	return tc, nil
+}
+
+//=============================================================================
+
+func (tc *TradingCalendar) IsTradingDay(t time.Time) bool {
+	return tc.weekdays[t.Weekday()] && !tc.holidays[t.Format(time.DateOnly)]
+}
+
+//=============================================================================
+//--- Trading days strictly between the dates of from and to
+
+func (tc *TradingCalendar) tradingDaysBetween(from time.Time, to time.Time) int {
+	count := 0
+
+	y, m, d := from.Date()
+	day     := time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+
+	y, m, d  = to.Date()
+	end     := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+
+	for ; day.Before(end); day = day.AddDate(0, 0, 1) {
+		if tc.IsTradingDay(day) {
+			count++
+		}
+	}
+
+	return count
+}
+
+//=============================================================================
+//--- Whether the ISO week (Monday to Sunday) of t has at least a trading day
+
+func (tc *TradingCalendar) hasTradingDayInWeek(t time.Time) bool {
+	monday := t.AddDate(0, 0, -(int(t.Weekday()) +6) % 7)
+
+	for d := 0; d < 7; d++ {
+		if tc.IsTradingDay(monday.AddDate(0, 0, d)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//=============================================================================
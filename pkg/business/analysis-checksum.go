@@ -0,0 +1,69 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+//=============================================================================
+
+type checksumInput struct {
+	Bars        int
+	Sharpe      float64
+	Sortino     float64
+	HistVol     float64
+	MaxDrawdown float64
+	UpDays      int
+	DownDays    int
+	FlatDays    int
+	Skewness    float64
+	Kurtosis    float64
+	BarResults  []*BarResult
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- SHA-256 of the returned bar results, in their order, and of the main summary
+//--- values. Values are already normalized and JSON encodes them the same way on
+//--- every run, so identical analyses always get the same checksum
+
+func calcChecksum(res *DataProductAnalysisResponse) string {
+	data, err := json.Marshal(&checksumInput{
+		Bars       : res.Bars,
+		Sharpe     : res.Sharpe,
+		Sortino    : res.Sortino,
+		HistVol    : res.HistVol,
+		MaxDrawdown: res.MaxDrawdown,
+		UpDays     : res.UpDays,
+		DownDays   : res.DownDays,
+		FlatDays   : res.FlatDays,
+		Skewness   : res.Skewness,
+		Kurtosis   : res.Kurtosis,
+		BarResults : res.BarResults,
+	})
+
+	//--- Bar results are sanitized, so encoding cannot fail
+
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+//=============================================================================
@@ -0,0 +1,68 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+
+func TestAnalysisChecksum(t *testing.T) {
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	run := func(points []*ds.DataPoint) string {
+		res, err := AnalyzeProduct(c, &DataProductAnalysisSpec{
+			QuerySpec: QuerySpec{
+				Id      : 1,
+				From    : "2024-01-01 00:00:00",
+				To      : "2024-12-31 00:00:00",
+				Timezone: "UTC",
+				Config  : buildQueryConfig(),
+			},
+			Source: NewSliceDataSource(points),
+		})
+
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		if len(res.Checksum) != 64 {
+			t.Fatalf("Bad checksum: %q", res.Checksum)
+		}
+
+		return res.Checksum
+	}
+
+	step := func(i int) float64 { return float64(i%5) -2 }
+
+	first  := run(buildDataPoints(buildCloses(300, 100, step)))
+	second := run(buildDataPoints(buildCloses(300, 100, step)))
+
+	if first != second {
+		t.Errorf("Identical inputs must have the same checksum. Got %v and %v", first, second)
+	}
+
+	//--- A single changed bar
+
+	points := buildDataPoints(buildCloses(300, 100, step))
+	points[250].Close += 0.5
+
+	if changed := run(points); changed == first {
+		t.Errorf("A changed bar must change the checksum")
+	}
+}
+
+//=============================================================================
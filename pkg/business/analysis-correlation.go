@@ -0,0 +1,334 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/core/req"
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+//--- Minimum number of common returns needed for a meaningful correlation
+
+const MinCorrelationDays = 10
+
+//--- How days without a benchmark bar are handled by the relative strength
+
+const (
+	RelStrengthFillSkip    = "skip"
+	RelStrengthFillForward = "forward"
+)
+
+//=============================================================================
+
+type ProductCorrelation struct {
+	IdA         uint    `json:"idA"`
+	SymbolA     string  `json:"symbolA"`
+	IdB         uint    `json:"idB"`
+	SymbolB     string  `json:"symbolB"`
+	Correlation float64 `json:"correlation"`
+	Days        int     `json:"days"`
+}
+
+//=============================================================================
+
+func CorrelateProducts(c *auth.Context, specA, specB *DataProductAnalysisSpec) (*ProductCorrelation, error) {
+	ctx := requestContext(c)
+
+	symbolA := specA.Config.DataConfig.Symbol
+	symbolB := specB.Config.DataConfig.Symbol
+
+	returnsA, err := fetchReturns(ctx, specA)
+	if err != nil {
+		return nil, err
+	}
+
+	returnsB, err := fetchReturns(ctx, specB)
+	if err != nil {
+		return nil, err
+	}
+
+	a, b := alignReturns(returnsA, returnsB)
+
+	if len(a) < MinCorrelationDays {
+		return nil, req.NewBadRequestError("not enough overlapping data: "+ strconv.Itoa(len(a)) +" common days found but at least "+ strconv.Itoa(MinCorrelationDays) +" are required")
+	}
+
+	corr, ok := calcCorrelation(a, b)
+	if !ok {
+		return nil, req.NewBadRequestError("cannot correlate a series without price changes")
+	}
+
+	return &ProductCorrelation{
+		IdA        : specA.Id,
+		SymbolA    : symbolA,
+		IdB        : specB.Id,
+		SymbolB    : symbolB,
+		Correlation: corr,
+		Days       : len(a),
+	}, nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+type datedReturn struct {
+	date  time.Time
+	value float64
+}
+
+//=============================================================================
+//--- Loads the data points of a spec, as the analysis would do
+
+func fetchSpecDataPoints(ctx context.Context, spec *DataProductAnalysisSpec) ([]*ds.DataPoint, *AnalysisParams, error) {
+	ap, err := NewAnalysisParams(spec)
+	if err != nil {
+		return nil, nil, req.NewBadRequestError(err.Error())
+	}
+
+	qs := spec.QuerySpec
+	qs.Timeframe = strconv.Itoa(ap.fetchTimeframe())
+
+	params, err := NewQueryParams(&qs)
+	if err != nil {
+		return nil, nil, req.NewBadRequestError(err.Error())
+	}
+
+	dataPoints, err := spec.dataSource().Fetch(ctx, params, spec.Config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataPoints, err = checkDataOrder(dataPoints, ap.FixOrder)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataPoints = trimAfterAsOf(dataPoints, params.AsOf)
+
+	dataPoints, err = adjustDataPoints(dataPoints, spec.Actions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataPoints, _ = filterSession(dataPoints, ap, params.TargetLoc)
+
+	return aggregateWeekly(dataPoints, ap, params.CloseFunc), ap, nil
+}
+
+//=============================================================================
+
+func fetchReturns(ctx context.Context, spec *DataProductAnalysisSpec) ([]datedReturn, error) {
+	dataPoints, ap, err := fetchSpecDataPoints(ctx, spec)
+	if err != nil {
+		return nil, err
+	}
+
+	return barReturns(dataPoints, ap), nil
+}
+
+//=============================================================================
+//--- Returns keyed by date for daily and weekly bars and by bar time for intraday ones
+
+func barReturns(dataPoints []*ds.DataPoint, ap *AnalysisParams) []datedReturn {
+	var list []datedReturn
+
+	for i := 1; i < len(dataPoints); i++ {
+		dp := dataPoints[i]
+
+		list = append(list, datedReturn{
+			date : returnKey(dp.Time, ap.Timeframe),
+			value: calcReturn(priceOf(dp, ap.PriceField), priceOf(dataPoints[i-1], ap.PriceField), ap.ReturnMode),
+		})
+	}
+
+	return list
+}
+
+//=============================================================================
+
+func returnKey(t time.Time, timeframe int) time.Time {
+	if timeframe >= DefaultTimeframe {
+		y, m, d := t.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	}
+
+	return t.UTC()
+}
+
+//=============================================================================
+//--- Keeps only the dates present in both series, in a's order
+
+func alignReturns(a, b []datedReturn) ([]float64, []float64) {
+	index := map[time.Time]float64{}
+	for _, r := range b {
+		index[r.date] = r.value
+	}
+
+	var listA, listB []float64
+
+	for _, r := range a {
+		if value, ok := index[r.date]; ok {
+			listA = append(listA, r.value)
+			listB = append(listB, value)
+		}
+	}
+
+	return listA, listB
+}
+
+//=============================================================================
+//--- Rolling beta of the product against the benchmark over the last betaLen returns.
+//--- Dates missing in the benchmark are skipped
+
+func calcBeta(list []*BarResult, returns []datedReturn, benchmark []datedReturn, betaLen int, timeframe int) {
+	positions := map[time.Time]int{}
+	for i, r := range returns {
+		positions[r.date] = i
+	}
+
+	index := map[time.Time]float64{}
+	for _, r := range benchmark {
+		index[r.date] = r.value
+	}
+
+	for _, br := range list {
+		pos, ok := positions[returnKey(br.Time, timeframe)]
+		if !ok || pos < betaLen-1 {
+			continue
+		}
+
+		var prod, bench []float64
+
+		for _, r := range returns[pos - betaLen +1 : pos+1] {
+			if value, found := index[r.date]; found {
+				prod  = append(prod,  r.value)
+				bench = append(bench, value)
+			}
+		}
+
+		if beta, ok := calcRegressionSlope(bench, prod); ok {
+			br.Beta = beta
+		}
+	}
+}
+
+//=============================================================================
+//--- Ratio of the product's price to the benchmark's one, normalized to 1.0 on the
+//--- first bar where both are available. Days without a benchmark bar are left at 0
+//--- or, when forward filling, use the last benchmark price before them
+
+func calcRelStrength(list []*BarResult, benchmark []*ds.DataPoint, priceField string, fill string, timeframe int) {
+	closes := map[time.Time]float64{}
+	for _, dp := range benchmark {
+		closes[returnKey(dp.Time, timeframe)] = priceOf(dp, priceField)
+	}
+
+	base      := 0.0
+	lastClose := 0.0
+
+	for _, br := range list {
+		benchClose, ok := closes[returnKey(br.Time, timeframe)]
+
+		if ok {
+			lastClose = benchClose
+		} else if fill == RelStrengthFillForward && lastClose != 0 {
+			benchClose = lastClose
+		} else {
+			continue
+		}
+
+		if benchClose == 0 {
+			continue
+		}
+
+		ratio := br.price / benchClose
+
+		if base == 0 {
+			base = ratio
+		}
+
+		if base != 0 {
+			br.RelStrength = ratio / base
+		}
+	}
+}
+
+//=============================================================================
+//--- cov(x,y) / var(x). Fails when there are too few values or x has no variance
+
+func calcRegressionSlope(x, y []float64) (float64, bool) {
+	n := float64(len(x))
+	if n < 2 {
+		return 0, false
+	}
+
+	meanX, meanY := 0.0, 0.0
+	for i := range x {
+		meanX += x[i] / n
+		meanY += y[i] / n
+	}
+
+	cov, varX := 0.0, 0.0
+	for i := range x {
+		dx := x[i] - meanX
+		cov  += dx * (y[i] - meanY)
+		varX += dx * dx
+	}
+
+	if varX == 0 {
+		return 0, false
+	}
+
+	return cov / varX, true
+}
+
+//=============================================================================
+//--- Pearson correlation. Fails when a series has no variance
+
+func calcCorrelation(a, b []float64) (float64, bool) {
+	n := float64(len(a))
+	if n == 0 {
+		return 0, false
+	}
+
+	meanA, meanB := 0.0, 0.0
+	for i := range a {
+		meanA += a[i] / n
+		meanB += b[i] / n
+	}
+
+	cov, varA, varB := 0.0, 0.0, 0.0
+	for i := range a {
+		da := a[i] - meanA
+		db := b[i] - meanB
+		cov  += da * db
+		varA += da * da
+		varB += db * db
+	}
+
+	if varA == 0 || varB == 0 {
+		return 0, false
+	}
+
+	return cov / math.Sqrt(varA * varB), true
+}
+
+//=============================================================================
@@ -0,0 +1,178 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"math"
+	"testing"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+
+func TestCorrelateProducts(t *testing.T) {
+	step   := func(i int) float64 { return float64(i%5) -2 }
+	base   := buildDataPoints(buildCloses(60, 100, step))
+	same   := buildDataPoints(buildCloses(60, 200, func(i int) float64 { return 2*step(i) }))
+	mirror := buildDataPoints(buildCloses(60, 300, func(i int) float64 { return -step(i) }))
+
+	//--- Leave a hole in one series, to check alignment by date
+
+	mirror = append(mirror[:30], mirror[31:]...)
+
+	series := map[string][]*ds.DataPoint{ "A": base, "B": same, "C": mirror }
+
+	spec := func(symbol string) *DataProductAnalysisSpec {
+		config := buildQueryConfig()
+		config.DataConfig.Symbol = symbol
+		return &DataProductAnalysisSpec{
+			QuerySpec: QuerySpec{ Timezone: "UTC", Config: config },
+			Source   : NewSliceDataSource(series[symbol]),
+		}
+	}
+
+	c := &auth.Context{}
+
+	res, err := CorrelateProducts(c, spec("A"), spec("B"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	//--- Prices are proportional, so returns are the same
+
+	if math.Abs(res.Correlation - 1) > 1e-9 || res.Days != 59 {
+		t.Errorf("Series must be correlated. Got %v over %d days", res.Correlation, res.Days)
+	}
+
+	res, err = CorrelateProducts(c, spec("A"), spec("C"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.Correlation > -0.95 || res.Days != 58 {
+		t.Errorf("Series must be anti-correlated. Got %v over %d days", res.Correlation, res.Days)
+	}
+
+	//--- Not enough overlap
+
+	series["D"] = buildDataPoints(buildCloses(5, 100, step))
+
+	if _, err = CorrelateProducts(c, spec("A"), spec("D")); err == nil {
+		t.Errorf("Insufficient overlap must be rejected")
+	}
+}
+
+//=============================================================================
+
+func TestCalcCorrelation(t *testing.T) {
+	a := []float64{ 1, 2, 3, 4, 5 }
+
+	if corr, _ := calcCorrelation(a, []float64{ 2, 4, 6, 8, 10 }); math.Abs(corr - 1) > 1e-12 {
+		t.Errorf("Expected perfect correlation. Got %v", corr)
+	}
+
+	if corr, _ := calcCorrelation(a, []float64{ 5, 4, 3, 2, 1 }); math.Abs(corr + 1) > 1e-12 {
+		t.Errorf("Expected perfect anti-correlation. Got %v", corr)
+	}
+
+	if _, ok := calcCorrelation(a, []float64{ 3, 3, 3, 3, 3 }); ok {
+		t.Errorf("A constant series cannot be correlated")
+	}
+}
+
+//=============================================================================
+
+func TestRollingBeta(t *testing.T) {
+	ap     := defaultParams()
+	step   := func(i int) float64 { return float64(i%7) -3 }
+	points := buildDataPoints(buildCloses(200, 1000, step))
+	list   := createBarResults(points, ap)
+	prod   := barReturns(points, ap)
+
+	//--- Same series: beta is 1
+
+	calcBeta(list, prod, prod, DefaultBetaLen, ap.Timeframe)
+
+	for i := DefaultBetaLen-1; i < len(list); i++ {
+		if math.Abs(list[i].Beta - 1) > 1e-9 {
+			t.Fatalf("Beta against itself must be 1 at %d. Got %v", i, list[i].Beta)
+		}
+	}
+
+	if list[DefaultBetaLen-2].Beta != 0 {
+		t.Errorf("Beta must not be set before the window is full")
+	}
+
+	//--- Product moving twice the benchmark (same percentage moves, doubled): beta is 2
+
+	bench := make([]datedReturn, len(prod))
+	for i, r := range prod {
+		bench[i] = datedReturn{ date: r.date, value: r.value / 2 }
+	}
+
+	//--- Missing benchmark days are skipped
+
+	bench = append(bench[:100], bench[101:]...)
+
+	list = createBarResults(points, ap)
+	calcBeta(list, prod, bench, DefaultBetaLen, ap.Timeframe)
+
+	for i := DefaultBetaLen-1; i < len(list); i++ {
+		if math.Abs(list[i].Beta - 2) > 1e-9 {
+			t.Fatalf("Beta must be 2 at %d. Got %v", i, list[i].Beta)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRelStrength(t *testing.T) {
+	ap     := defaultParams()
+	points := buildDataPoints(buildCloses(50, 100, func(i int) float64 { return 2 }))
+	bench  := buildDataPoints(buildCloses(50, 100, func(i int) float64 { return 1 }))
+	list   := createBarResults(points, ap)
+
+	calcRelStrength(list, bench, PriceFieldClose, RelStrengthFillSkip, ap.Timeframe)
+
+	if list[0].RelStrength != 1 {
+		t.Fatalf("Relative strength must start at 1. Got %v", list[0].RelStrength)
+	}
+
+	for i := 1; i < len(list); i++ {
+		if list[i].RelStrength <= list[i-1].RelStrength {
+			t.Fatalf("Relative strength must increase when outperforming the benchmark (%d: %v -> %v)", i, list[i-1].RelStrength, list[i].RelStrength)
+		}
+	}
+
+	//--- Missing benchmark day: skipped or filled with the previous close
+
+	hole := append(append([]*ds.DataPoint{}, bench[:21]...), bench[22:]...)
+
+	list = createBarResults(points, ap)
+	calcRelStrength(list, hole, PriceFieldClose, RelStrengthFillSkip, ap.Timeframe)
+
+	if list[20].RelStrength != 0 {
+		t.Errorf("Relative strength must not be set without a benchmark bar. Got %v", list[20].RelStrength)
+	}
+
+	list = createBarResults(points, ap)
+	calcRelStrength(list, hole, PriceFieldClose, RelStrengthFillForward, ap.Timeframe)
+
+	expected := (points[21].Close / bench[20].Close) / (points[1].Close / bench[1].Close)
+
+	if math.Abs(list[20].RelStrength - expected) > 1e-9 {
+		t.Errorf("Relative strength must use the previous benchmark close. Expected %v, got %v", expected, list[20].RelStrength)
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,94 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+)
+
+//=============================================================================
+
+var csvHeader = []string{ "date", "close", "changePerc", "sqn100", "trueRange", "atrPerc", "direction", "volatility" }
+
+//=============================================================================
+//--- Writes one row per bar result. Values are written as in the response, so
+//--- it must be called on a normalized response
+
+func (r *DataProductAnalysisResponse) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, br := range r.BarResults {
+		row := []string{
+			formatBarTime(br.Time, r.Timeframe),
+			formatFloat(br.Close),
+			formatFloat(br.BarChangePerc),
+			formatFloat(br.Sqn100),
+			formatFloat(br.TrueRange),
+			formatFloat(br.AtrPerc),
+			strconv.Itoa(br.Direction),
+			strconv.Itoa(br.Volatility),
+		}
+
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+//=============================================================================
+//--- Writes newline-delimited JSON: a first line with the response's metadata (all
+//--- fields except the bar results) followed by one line per bar result
+
+func (r *DataProductAnalysisResponse) WriteJSONL(w io.Writer) error {
+	enc := json.NewEncoder(w)
+
+	meta := *r
+	meta.BarResults = nil
+
+	if err := enc.Encode(&meta); err != nil {
+		return err
+	}
+
+	for _, br := range r.BarResults {
+		if err := enc.Encode(br); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func formatBarTime(t time.Time, timeframe int) string {
+	if timeframe >= DefaultTimeframe {
+		return t.Format(time.DateOnly)
+	}
+
+	return t.Format("2006-01-02 15:04")
+}
+
+//=============================================================================
@@ -0,0 +1,120 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestWriteCSV(t *testing.T) {
+	res := &DataProductAnalysisResponse{
+		Timeframe : DefaultTimeframe,
+		BarResults: []*BarResult{
+			{ Time: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC), Close: 101.5, BarChangePerc:  1.5,  Sqn100: 0.82, TrueRange: 2.25, AtrPerc: 1.9, Direction: DirectionBull, Volatility: VolatilityNormal },
+			{ Time: time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC), Close: 99,    BarChangePerc: -2.46, Sqn100: -0.1, TrueRange: 3,    AtrPerc: 2.1, Direction: DirectionNeutral, Volatility: VolatilityVolatile },
+		},
+	}
+
+	var buf bytes.Buffer
+	if err := res.WriteCSV(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Written CSV cannot be read back: %v", err)
+	}
+
+	if len(rows) != 3 {
+		t.Fatalf("Expected header plus 2 rows. Got %d rows", len(rows))
+	}
+
+	if !slices.Equal(rows[0], csvHeader) {
+		t.Errorf("Bad header: %v", rows[0])
+	}
+
+	expected := [][]string{
+		{ "2024-03-04", "101.5", "1.5", "0.82", "2.25", "1.9", "1", "1" },
+		{ "2024-03-05", "99", "-2.46", "-0.1", "3", "2.1", "0", "2" },
+	}
+
+	for i, exp := range expected {
+		if !slices.Equal(rows[i+1], exp) {
+			t.Errorf("Bad row %d: got %v, expected %v", i+1, rows[i+1], exp)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestWriteJSONL(t *testing.T) {
+	points := buildDataPoints(buildCloses(150, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	res, err := analyzeDataPoints(context.Background(), points, defaultParams(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	res.Symbol = "ES"
+	normalizeValues(res)
+
+	var buf bytes.Buffer
+	if err = res.WriteJSONL(&buf); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	scanner := bufio.NewScanner(&buf)
+
+	//--- Metadata first
+
+	if !scanner.Scan() {
+		t.Fatalf("Missing metadata line")
+	}
+
+	var meta DataProductAnalysisResponse
+	if err = json.Unmarshal(scanner.Bytes(), &meta); err != nil {
+		t.Fatalf("Bad metadata line: %v", err)
+	}
+
+	if meta.Symbol != "ES" || meta.Bars != res.Bars || meta.BarResults != nil {
+		t.Errorf("Bad metadata. Got symbol=%v, bars=%d, %d bar results", meta.Symbol, meta.Bars, len(meta.BarResults))
+	}
+
+	rows := 0
+
+	for scanner.Scan() {
+		var br BarResult
+		if err = json.Unmarshal(scanner.Bytes(), &br); err != nil {
+			t.Fatalf("Bad line %d: %v", rows+2, err)
+		}
+
+		if !br.Time.Equal(res.BarResults[rows].Time) || br.Sqn100 != res.BarResults[rows].Sqn100 {
+			t.Fatalf("Line %d doesn't match its bar result", rows+2)
+		}
+
+		rows++
+	}
+
+	if rows != len(res.BarResults) {
+		t.Errorf("Bad row count. Got %d, expected %d", rows, len(res.BarResults))
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,826 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"math"
+	"sort"
+
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+//===
+//=== RSI
+//===
+//=============================================================================
+//--- Wilder's RSI. The first value is available once rsiLen price changes are known
+
+func calcRsi(list []*BarResult, rsiLen int) {
+	n := float64(rsiLen)
+
+	avgGain := 0.0
+	avgLoss := 0.0
+
+	for i, br := range list {
+		gain, loss := 0.0, 0.0
+		change     := br.price - br.prevPrice
+
+		if change > 0 {
+			gain = change
+		} else {
+			loss = -change
+		}
+
+		if i < rsiLen {
+			avgGain += gain / n
+			avgLoss += loss / n
+		} else {
+			avgGain = (avgGain * (n-1) + gain) / n
+			avgLoss = (avgLoss * (n-1) + loss) / n
+		}
+
+		if i >= rsiLen-1 {
+			br.Rsi = rsiValue(avgGain, avgLoss)
+		}
+	}
+}
+
+//=============================================================================
+
+func rsiValue(avgGain, avgLoss float64) float64 {
+	if avgLoss == 0 {
+		if avgGain == 0 {
+			return 50
+		}
+
+		return 100
+	}
+
+	return 100 - 100 / (1 + avgGain/avgLoss)
+}
+
+//=============================================================================
+//===
+//=== Moving averages
+//===
+//=============================================================================
+
+func calcMovingAverages(list []*BarResult, maLen int) {
+	values := priceValues(list)
+	sma    := smaSeries(values, maLen, 0)
+	ema    := emaSeries(values, maLen, 0)
+
+	for i := maLen-1; i < len(list); i++ {
+		list[i].Sma = sma[i]
+		list[i].Ema = ema[i]
+	}
+}
+
+//=============================================================================
+//===
+//=== MACD
+//===
+//=============================================================================
+//--- The MACD line is available once the slow EMA is ready (at slow-1), while
+//--- signal and histogram need 'signal' more MACD values (at slow+signal-2)
+
+func calcMacd(list []*BarResult, fast, slow, signal int) {
+	values  := priceValues(list)
+	fastEma := emaSeries(values, fast, 0)
+	slowEma := emaSeries(values, slow, 0)
+	macd    := make([]float64, len(list))
+
+	for i := slow-1; i < len(list); i++ {
+		macd[i] = fastEma[i] - slowEma[i]
+	}
+
+	signalEma := emaSeries(macd, signal, slow-1)
+
+	for i := slow-1; i < len(list); i++ {
+		br := list[i]
+		br.MacdLine = macd[i]
+
+		if i >= slow+signal-2 {
+			br.MacdSignal = signalEma[i]
+			br.MacdHist   = br.MacdLine - br.MacdSignal
+		}
+	}
+}
+
+//=============================================================================
+//===
+//=== Bollinger bands
+//===
+//=============================================================================
+
+func calcBollinger(list []*BarResult, bollLen int, k float64) {
+	for i := bollLen-1; i < len(list); i++ {
+		mean, stdDev := calcMeanAndStdDev(list, i, bollLen, func(br *BarResult) float64 {
+			return br.price
+		})
+
+		br := list[i]
+		br.BollMiddle = mean
+		br.BollUpper  = mean + k*stdDev
+		br.BollLower  = mean - k*stdDev
+	}
+}
+
+//=============================================================================
+//===
+//=== Keltner channels
+//===
+//=============================================================================
+//--- EMA of the close +/- k times the ATR computed by createBarResults
+
+func calcKeltner(list []*BarResult, keltLen int, k float64) {
+	ema := emaSeries(priceValues(list), keltLen, 0)
+
+	for i := keltLen-1; i < len(list); i++ {
+		br := list[i]
+		br.KeltMiddle = ema[i]
+		br.KeltUpper  = ema[i] + k*br.Atr
+		br.KeltLower  = ema[i] - k*br.Atr
+	}
+}
+
+//=============================================================================
+//===
+//=== ADX
+//===
+//=============================================================================
+//--- Wilder's directional movement. +DI/-DI are available once adxLen bars are
+//--- known (at adxLen-1), ADX averages adxLen DX values (at 2*adxLen-2)
+
+func calcAdx(list []*BarResult, adxLen int) {
+	n := float64(adxLen)
+
+	avgTr    := 0.0
+	avgPlus  := 0.0
+	avgMinus := 0.0
+	adx      := 0.0
+
+	for i, br := range list {
+		plusDm, minusDm := directionalMovement(br.point, br.prevPoint)
+
+		if i < adxLen {
+			avgTr    += br.TrueRange / n
+			avgPlus  += plusDm      / n
+			avgMinus += minusDm     / n
+		} else {
+			avgTr    = (avgTr    * (n-1) + br.TrueRange) / n
+			avgPlus  = (avgPlus  * (n-1) + plusDm)       / n
+			avgMinus = (avgMinus * (n-1) + minusDm)      / n
+		}
+
+		if i < adxLen-1 {
+			continue
+		}
+
+		if avgTr != 0 {
+			br.PlusDi  = 100 * avgPlus  / avgTr
+			br.MinusDi = 100 * avgMinus / avgTr
+		}
+
+		dx := 0.0
+		if sum := br.PlusDi + br.MinusDi; sum != 0 {
+			dx = 100 * math.Abs(br.PlusDi - br.MinusDi) / sum
+		}
+
+		if i < 2*adxLen-1 {
+			adx += dx / n
+		} else {
+			adx = (adx * (n-1) + dx) / n
+		}
+
+		if i >= 2*adxLen-2 {
+			br.Adx14 = adx
+		}
+	}
+
+	calcAdxr(list, adxLen)
+}
+
+//=============================================================================
+//--- Mean of the current ADX and the one adxLen bars before. Bars whose lagged
+//--- ADX is still warming up are left at zero
+
+func calcAdxr(list []*BarResult, adxLen int) {
+	for i := 3*adxLen -2; i < len(list); i++ {
+		list[i].Adxr = (list[i].Adx14 + list[i - adxLen].Adx14) / 2
+	}
+}
+
+//=============================================================================
+
+func directionalMovement(curr *ds.DataPoint, prev *ds.DataPoint) (float64, float64) {
+	upMove   := curr.High - prev.High
+	downMove := prev.Low  - curr.Low
+
+	plusDm, minusDm := 0.0, 0.0
+
+	if upMove > downMove && upMove > 0 {
+		plusDm = upMove
+	}
+
+	if downMove > upMove && downMove > 0 {
+		minusDm = downMove
+	}
+
+	return plusDm, minusDm
+}
+
+//=============================================================================
+//===
+//=== VWAP
+//===
+//=============================================================================
+//--- Rolling VWAP of the typical price over vwapLen bars (DefaultVwapLen when not
+//--- given). Windows without volume are left unset
+
+func calcVwap(list []*BarResult, vwapLen int) {
+	for i := vwapLen-1; i < len(list); i++ {
+		sumPv  := 0.0
+		sumVol := 0.0
+
+		for j := i - vwapLen + 1; j <= i; j++ {
+			dp     := list[j].point
+			volume := float64(dp.UpVolume + dp.DownVolume)
+			sumPv  += list[j].TypicalPrice * volume
+			sumVol += volume
+		}
+
+		if sumVol > 0 {
+			list[i].Vwap = sumPv / sumVol
+		}
+	}
+}
+
+//=============================================================================
+
+func typicalPrice(dp *ds.DataPoint) float64 {
+	return (dp.High + dp.Low + dp.Close) / 3
+}
+
+//=============================================================================
+
+func medianPrice(dp *ds.DataPoint) float64 {
+	return (dp.High + dp.Low) / 2
+}
+
+//=============================================================================
+//--- The price the returns and the price based indicators are computed on
+
+func priceOf(dp *ds.DataPoint, field string) float64 {
+	switch field {
+	case PriceFieldOpen:
+		return dp.Open
+	case PriceFieldTypical:
+		return typicalPrice(dp)
+	case PriceFieldMedian:
+		return medianPrice(dp)
+	case PriceFieldWeighted:
+		return (dp.High + dp.Low + 2*dp.Close) / 4
+	}
+
+	return dp.Close
+}
+
+//=============================================================================
+//===
+//=== MFI
+//===
+//=============================================================================
+//--- Money flow is positive when the typical price rises from the previous bar and
+//--- negative when it falls. Without negative flow MFI is 100
+
+func calcMfi(list []*BarResult, mfiLen int) {
+	flows := make([]float64, len(list))
+
+	for i, br := range list {
+		typical := br.TypicalPrice
+		flow    := typical * float64(br.point.UpVolume + br.point.DownVolume)
+
+		if prev := typicalPrice(br.prevPoint); typical < prev {
+			flow = -flow
+		} else if typical == prev {
+			flow = 0
+		}
+
+		flows[i] = flow
+	}
+
+	for i := mfiLen-1; i < len(list); i++ {
+		positive, negative := 0.0, 0.0
+
+		for _, flow := range flows[i - mfiLen +1 : i+1] {
+			if flow > 0 {
+				positive += flow
+			} else {
+				negative -= flow
+			}
+		}
+
+		list[i].Mfi14 = rsiValue(positive, negative)
+	}
+}
+
+//=============================================================================
+//===
+//=== CMF
+//===
+//=============================================================================
+//--- Chaikin money flow: volume weighted by the position of the close within the
+//--- bar's range (+1 at the high, -1 at the low), over the total volume of the
+//--- window. Bars with no range add no flow, and a window with no volume gives 0
+
+func calcCmf(list []*BarResult, cmfLen int) {
+	flows   := make([]float64, len(list))
+	volumes := make([]float64, len(list))
+
+	for i, br := range list {
+		dp := br.point
+		volumes[i] = float64(dp.UpVolume + dp.DownVolume)
+
+		if rng := dp.High - dp.Low; rng != 0 {
+			flows[i] = ((dp.Close - dp.Low) - (dp.High - dp.Close)) / rng * volumes[i]
+		}
+	}
+
+	for i := cmfLen-1; i < len(list); i++ {
+		sumFlow, sumVol := 0.0, 0.0
+
+		for k := i - cmfLen +1; k <= i; k++ {
+			sumFlow += flows[k]
+			sumVol  += volumes[k]
+		}
+
+		if sumVol > 0 {
+			list[i].Cmf20 = sumFlow / sumVol
+		}
+	}
+}
+
+//=============================================================================
+//===
+//=== OBV
+//===
+//=============================================================================
+//--- Cumulative volume, added on up bars and subtracted on down bars. The series
+//--- starts from 0 on the first returned bar
+
+func calcObv(list []*BarResult, start int) {
+	if start < 0 || start >= len(list) {
+		return
+	}
+
+	obv := 0.0
+
+	for _, br := range list[start+1:] {
+		volume := float64(br.point.UpVolume + br.point.DownVolume)
+
+		if br.price > br.prevPrice {
+			obv += volume
+		} else if br.price < br.prevPrice {
+			obv -= volume
+		}
+
+		br.Obv = obv
+	}
+}
+
+//=============================================================================
+//===
+//=== ROC and momentum
+//===
+//=============================================================================
+//--- Change of the price over the last rocLen bars, as a fraction (ROC) and as a
+//--- price difference (momentum)
+
+func calcRoc(list []*BarResult, rocLen int) {
+	for i := rocLen; i < len(list); i++ {
+		prevPrice := list[i - rocLen].price
+
+		list[i].Momentum12 = list[i].price - prevPrice
+
+		if prevPrice != 0 {
+			list[i].Roc12 = list[i].Momentum12 / prevPrice
+		}
+	}
+}
+
+//=============================================================================
+//===
+//=== CCI
+//===
+//=============================================================================
+//--- Distance of the typical price from its average, scaled by the mean absolute
+//--- deviation. A flat window has no deviation, so CCI is 0
+
+func calcCci(list []*BarResult, cciLen int) {
+	for i := cciLen-1; i < len(list); i++ {
+		window := list[i - cciLen +1 : i+1]
+		mean   := 0.0
+
+		for _, br := range window {
+			mean += br.TypicalPrice
+		}
+
+		mean /= float64(cciLen)
+
+		deviation := 0.0
+
+		for _, br := range window {
+			deviation += math.Abs(br.TypicalPrice - mean)
+		}
+
+		deviation /= float64(cciLen)
+
+		if deviation != 0 {
+			list[i].Cci20 = (list[i].TypicalPrice - mean) / (0.015 * deviation)
+		}
+	}
+}
+
+//=============================================================================
+//===
+//=== Pivot points
+//===
+//=============================================================================
+//--- Floor trader pivots, from the previous bar's high, low and close. Every bar
+//--- has a previous one, so they are always available
+
+func calcPivots(list []*BarResult) {
+	for _, br := range list {
+		prev  := br.prevPoint
+		pivot := typicalPrice(prev)
+		rng   := prev.High - prev.Low
+
+		br.Pivot   = pivot
+		br.PivotR1 = 2*pivot - prev.Low
+		br.PivotS1 = 2*pivot - prev.High
+		br.PivotR2 = pivot + rng
+		br.PivotS2 = pivot - rng
+		br.PivotR3 = prev.High + 2*(pivot - prev.Low)
+		br.PivotS3 = prev.Low  - 2*(prev.High - pivot)
+	}
+}
+
+//=============================================================================
+//===
+//=== Stochastic
+//===
+//=============================================================================
+//--- %K is the position of the price within the high/low range of the last stochLen
+//--- bars, %D its simple average over StochDLen bars. A flat range gives 50
+
+func calcStochastic(list []*BarResult, stochLen int) {
+	for i := stochLen-1; i < len(list); i++ {
+		high, low := windowHighLow(list[i - stochLen +1 : i+1])
+
+		list[i].StochK = 50
+
+		if high != low {
+			list[i].StochK = (list[i].price - low) / (high - low) * 100
+		}
+	}
+
+	for i := stochLen + StochDLen -2; i < len(list); i++ {
+		sum := 0.0
+
+		for _, br := range list[i - StochDLen +1 : i+1] {
+			sum += br.StochK
+		}
+
+		list[i].StochD = sum / StochDLen
+	}
+}
+
+//=============================================================================
+//===
+//=== Williams %R
+//===
+//=============================================================================
+//--- Distance of the price from the high of the last willrLen bars, in [-100..0].
+//--- A flat range gives -50, like the stochastic
+
+func calcWilliamsR(list []*BarResult, willrLen int) {
+	for i := willrLen-1; i < len(list); i++ {
+		high, low := windowHighLow(list[i - willrLen +1 : i+1])
+
+		list[i].WilliamsR = -50
+
+		if high != low {
+			list[i].WilliamsR = (high - list[i].price) / (high - low) * -100
+		}
+	}
+}
+
+//=============================================================================
+//===
+//=== TRIX
+//===
+//=============================================================================
+//--- One bar change of a triple smoothed EMA of the close. Each EMA needs trixLen-1
+//--- bars of the previous one, so the first value is at 3*(trixLen-1)+1
+
+func calcTrix(list []*BarResult, trixLen int) {
+	ema1 := emaSeries(priceValues(list), trixLen, 0)
+	ema2 := emaSeries(ema1, trixLen, trixLen-1)
+	ema3 := emaSeries(ema2, trixLen, 2*(trixLen-1))
+
+	for i := 3*(trixLen-1) +1; i < len(list); i++ {
+		if ema3[i-1] != 0 {
+			list[i].Trix15 = (ema3[i] - ema3[i-1]) / ema3[i-1]
+		}
+	}
+}
+
+//=============================================================================
+//===
+//=== Linear regression
+//===
+//=============================================================================
+//--- Least-squares fit of the last regLen closes against the bar index. The slope
+//--- is in price per bar. R² is 0 when closes are flat, as the fit explains nothing
+
+func calcLinReg(list []*BarResult, regLen int) {
+	n := float64(regLen)
+
+	//--- x = 0..regLen-1, so its mean and deviation are constant
+
+	meanX := (n-1) / 2
+	sxx   := n * (n*n - 1) / 12
+
+	for i := regLen-1; i < len(list); i++ {
+		window := list[i - regLen +1 : i+1]
+		meanY  := 0.0
+
+		for _, br := range window {
+			meanY += br.price / n
+		}
+
+		sxy, syy := 0.0, 0.0
+
+		for k, br := range window {
+			dy  := br.price - meanY
+			sxy += (float64(k) - meanX) * dy
+			syy += dy * dy
+		}
+
+		list[i].RegSlope = sxy / sxx
+
+		if syy > 0 {
+			list[i].RegR2 = sxy * sxy / (sxx * syy)
+		}
+	}
+}
+
+//=============================================================================
+//===
+//=== Parabolic SAR
+//===
+//=============================================================================
+//--- Wilder's stop and reverse. The trend of the first bar follows the second price.
+//--- The stop moves towards the extreme point by an acceleration factor, which grows
+//--- by 'step' at each new extreme up to 'maxAf'. When the stop is crossed the trend
+//--- flips and the stop restarts from the last extreme. State carries from bar to
+//--- bar, so it must run in one forward pass
+
+func calcSar(list []*BarResult, start, step, maxAf float64) {
+	if len(list) < 2 {
+		return
+	}
+
+	up  := list[1].price >= list[0].price
+	af  := start
+	sar := list[0].point.High
+	ep  := list[1].point.Low
+
+	if up {
+		sar = list[0].point.Low
+		ep  = list[1].point.High
+	}
+
+	list[1].Sar = sar
+
+	for i := 2; i < len(list); i++ {
+		br  := list[i]
+		dp  := br.point
+		sar += af * (ep - sar)
+
+		//--- The stop can never be inside the previous two bars
+
+		if up {
+			sar = min(sar, list[i-1].point.Low, list[i-2].point.Low)
+
+			if dp.Low < sar {
+				up, sar, ep, af = false, ep, dp.Low, start
+			} else if dp.High > ep {
+				ep, af = dp.High, min(af + step, maxAf)
+			}
+		} else {
+			sar = max(sar, list[i-1].point.High, list[i-2].point.High)
+
+			if dp.High > sar {
+				up, sar, ep, af = true, ep, dp.High, start
+			} else if dp.Low < ep {
+				ep, af = dp.Low, min(af + step, maxAf)
+			}
+		}
+
+		br.Sar = sar
+	}
+}
+
+//=============================================================================
+//===
+//=== Ichimoku
+//===
+//=============================================================================
+//--- Lines are midpoints of the high/low range of their window. Each row holds the
+//--- values as they are plotted on its bar:
+//---   - Senkou A and B are shifted forward by kijunLen: they are the values computed
+//---     kijunLen bars earlier. The cloud projected past the last bar is not returned,
+//---     and it is 0 until kijunLen + senkouLen bars are available, even after the warm-up
+//---   - Chikou is shifted backward by kijunLen: it is the price kijunLen bars later.
+//---     It looks ahead, so it is 0 on the last kijunLen bars and must not be used as
+//---     a signal on its own row
+
+func calcIchimoku(list []*BarResult, tenkanLen, kijunLen, senkouLen int) {
+	midpoint := func(end int, length int) float64 {
+		high, low := windowHighLow(list[end - length +1 : end+1])
+		return (high + low) / 2
+	}
+
+	for i, br := range list {
+		if i >= tenkanLen-1 {
+			br.Tenkan = midpoint(i, tenkanLen)
+		}
+
+		if i >= kijunLen-1 {
+			br.Kijun = midpoint(i, kijunLen)
+		}
+
+		if j := i - kijunLen; j >= kijunLen-1 {
+			br.SenkouA = (list[j].Tenkan + list[j].Kijun) / 2
+		}
+
+		if j := i - kijunLen; j >= senkouLen-1 {
+			br.SenkouB = midpoint(j, senkouLen)
+		}
+
+		if j := i + kijunLen; j < len(list) {
+			br.Chikou = list[j].price
+		}
+	}
+}
+
+//=============================================================================
+//===
+//=== SQN percentile
+//===
+//=============================================================================
+//--- Rank of each SQN within the returned bars, from 0 (lowest) to 100 (highest).
+//--- Ties share their average rank and a single value sits in the middle
+
+func calcSqnPercentiles(list []*BarResult) {
+	n := len(list)
+	if n == 0 {
+		return
+	}
+
+	sorted := make([]float64, n)
+	for i, br := range list {
+		sorted[i] = br.Sqn100
+	}
+
+	sort.Float64s(sorted)
+
+	for _, br := range list {
+		if n == 1 {
+			br.SqnPercentile = 50
+			continue
+		}
+
+		lo   := sort.SearchFloat64s(sorted, br.Sqn100)
+		hi   := sort.SearchFloat64s(sorted, math.Nextafter(br.Sqn100, math.Inf(1)))
+		rank := float64(lo + hi -1) / 2
+
+		br.SqnPercentile = 100 * rank / float64(n-1)
+	}
+}
+
+//=============================================================================
+//===
+//=== SQN slope
+//===
+//=============================================================================
+//--- Least-squares slope of the last slopeLen SQN values against the bar index, in
+//--- SQN points per bar. Bars before start have no SQN, so the slope begins at
+//--- start + slopeLen -1
+
+func calcSqnSlope(list []*BarResult, start int, slopeLen int) {
+	x := make([]float64, slopeLen)
+	y := make([]float64, slopeLen)
+
+	for k := range x {
+		x[k] = float64(k)
+	}
+
+	for i := start + slopeLen -1; i < len(list); i++ {
+		for k, br := range list[i - slopeLen +1 : i+1] {
+			y[k] = br.Sqn100
+		}
+
+		list[i].SqnSlope, _ = calcRegressionSlope(x, y)
+	}
+}
+
+//=============================================================================
+//===
+//=== Series helpers
+//===
+//=============================================================================
+
+func priceValues(list []*BarResult) []float64 {
+	values := make([]float64, len(list))
+
+	for i, br := range list {
+		values[i] = br.price
+	}
+
+	return values
+}
+
+//=============================================================================
+
+func changeValues(list []*BarResult) []float64 {
+	values := make([]float64, len(list))
+
+	for i, br := range list {
+		values[i] = br.BarChangePerc
+	}
+
+	return values
+}
+
+//=============================================================================
+
+func statsValues(list []*BarResult) []float64 {
+	values := make([]float64, len(list))
+
+	for i, br := range list {
+		values[i] = br.statsReturn
+	}
+
+	return values
+}
+
+//=============================================================================
+//--- Values before 'start' are not valid. The first average is available at start+n-1
+
+func smaSeries(values []float64, n int, start int) []float64 {
+	res := make([]float64, len(values))
+	sum := 0.0
+
+	for i := start; i < len(values); i++ {
+		sum += values[i]
+
+		if i >= start+n {
+			sum -= values[i-n]
+		}
+
+		if i >= start+n-1 {
+			res[i] = sum / float64(n)
+		}
+	}
+
+	return res
+}
+
+//=============================================================================
+//--- The EMA is seeded by the SMA of the first n values, then it follows the
+//--- standard recursion with alpha = 2/(n+1)
+
+func emaSeries(values []float64, n int, start int) []float64 {
+	res   := smaSeries(values, n, start)
+	alpha := 2 / float64(n+1)
+
+	for i := start+n; i < len(values); i++ {
+		res[i] = res[i-1] + alpha * (values[i] - res[i-1])
+	}
+
+	return res
+}
+
+//=============================================================================
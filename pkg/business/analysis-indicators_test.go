@@ -0,0 +1,809 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+
+func TestRsi(t *testing.T) {
+	cases := []struct {
+		name  string
+		step  float64
+		check func(rsi float64) bool
+	}{
+		{ "all-up"  ,  1, func(rsi float64) bool { return rsi > 99 } },
+		{ "all-down", -1, func(rsi float64) bool { return rsi <  1 } },
+		{ "flat"    ,  0, func(rsi float64) bool { return rsi == 50 } },
+	}
+
+	for _, c := range cases {
+		points := buildDataPoints(buildCloses(30, 100, func(i int) float64 { return c.step }))
+		list   := createBarResults(points, defaultParams())
+
+		calcRsi(list, DefaultRsiLen)
+
+		for i, br := range list {
+			if i < DefaultRsiLen-1 {
+				if br.Rsi != 0 {
+					t.Fatalf("%v: RSI must not be set during warm-up. Got %v at %v", c.name, br.Rsi, i)
+				}
+			} else if !c.check(br.Rsi) {
+				t.Fatalf("%v: unexpected RSI %v at %v", c.name, br.Rsi, i)
+			}
+		}
+	}
+}
+
+//=============================================================================
+
+func TestMovingAverages(t *testing.T) {
+	points := buildDataPoints(buildCloses(10, 1, func(i int) float64 { return 1 }))
+	list   := createBarResults(points, defaultParams())
+
+	//--- Closes are 2,3,...,10
+
+	calcMovingAverages(list, 3)
+
+	if list[1].Sma != 0 || list[1].Ema != 0 {
+		t.Errorf("Averages must not be set during warm-up. Got sma=%v, ema=%v", list[1].Sma, list[1].Ema)
+	}
+
+	if list[2].Sma != 3 || list[2].Ema != 3 {
+		t.Errorf("EMA must be seeded by the SMA. Got sma=%v, ema=%v", list[2].Sma, list[2].Ema)
+	}
+
+	//--- alpha = 0.5 -> ema = 3 + 0.5*(5-3)
+
+	if list[3].Sma != 4 || list[3].Ema != 4 {
+		t.Errorf("Bad averages. Got sma=%v, ema=%v", list[3].Sma, list[3].Ema)
+	}
+}
+
+//=============================================================================
+
+func TestMacd(t *testing.T) {
+	points := buildDataPoints(buildCloses(60, 100, func(i int) float64 { return 1 }))
+	list   := createBarResults(points, defaultParams())
+
+	calcMacd(list, 3, 6, 4)
+
+	if list[4].MacdLine != 0 || list[5].MacdLine == 0 {
+		t.Errorf("MACD line must start when the slow EMA is ready. Got %v, %v", list[4].MacdLine, list[5].MacdLine)
+	}
+
+	if list[7].MacdSignal != 0 || list[8].MacdSignal == 0 {
+		t.Errorf("MACD signal must start after the signal warm-up. Got %v, %v", list[7].MacdSignal, list[8].MacdSignal)
+	}
+
+	//--- On a linear uptrend the MACD converges to (slow-fast)/2 * step
+
+	last := list[len(list)-1]
+	if math.Abs(last.MacdLine - 1.5) > 1e-6 || math.Abs(last.MacdHist) > 1e-6 {
+		t.Errorf("Bad MACD on a linear trend. Got line=%v, hist=%v", last.MacdLine, last.MacdHist)
+	}
+}
+
+//=============================================================================
+
+func TestBollinger(t *testing.T) {
+	points := buildDataPoints(buildCloses(60, 100, func(i int) float64 {
+		if i < 30 {
+			return float64(i%2)*2 -1
+		}
+		return float64(i%2)*10 -5
+	}))
+	list := createBarResults(points, defaultParams())
+
+	calcMovingAverages(list, 10)
+	calcBollinger(list, 10, 2)
+
+	for i := 9; i < len(list); i++ {
+		if math.Abs(list[i].BollMiddle - list[i].Sma) > 1e-9 {
+			t.Fatalf("Middle band must be the SMA. Got %v but SMA is %v", list[i].BollMiddle, list[i].Sma)
+		}
+	}
+
+	quiet    := list[20].BollUpper - list[20].BollLower
+	volatile := list[50].BollUpper - list[50].BollLower
+
+	if volatile <= quiet {
+		t.Errorf("Band width must grow with volatility. Got %v (quiet) and %v (volatile)", quiet, volatile)
+	}
+}
+
+//=============================================================================
+
+func TestAdx(t *testing.T) {
+	ap := defaultParams()
+
+	//--- Clean uptrend: +DI dominates and ADX keeps rising
+
+	trend := createBarResults(buildDataPoints(buildCloses(80, 100, func(i int) float64 { return 2 })), ap)
+	calcAdx(trend, DefaultAdxLen)
+
+	first := 2*DefaultAdxLen -2
+	if trend[first-1].Adx14 != 0 {
+		t.Errorf("ADX must not be set during warm-up. Got %v", trend[first-1].Adx14)
+	}
+
+	for i := first; i < len(trend); i++ {
+		br := trend[i]
+		if br.Adx14 < 50 || br.PlusDi <= br.MinusDi {
+			t.Fatalf("Strong uptrend expected at %d. Got ADX %v, +DI %v, -DI %v", i, br.Adx14, br.PlusDi, br.MinusDi)
+		}
+
+		if i > first && br.Adx14 < trend[i-1].Adx14 {
+			t.Fatalf("ADX must not decrease during a clean trend at %d", i)
+		}
+	}
+
+	//--- Choppy range: moves cancel out and ADX stays low
+
+	chop := createBarResults(buildDataPoints(buildCloses(80, 100, func(i int) float64 { return float64(2*(i%2) -1) * 2 })), ap)
+	calcAdx(chop, DefaultAdxLen)
+
+	if adx := chop[len(chop)-1].Adx14; adx > 20 {
+		t.Errorf("ADX must be low in a choppy range. Got %v", adx)
+	}
+}
+
+//=============================================================================
+
+func TestAdxr(t *testing.T) {
+	n    := DefaultAdxLen
+	list := make([]*BarResult, 80)
+
+	//--- Rising ADX with swings on top, each lasting a lag
+
+	for i := range list {
+		list[i] = &BarResult{}
+		if i >= 2*n -2 {
+			list[i].Adx14 = float64(i) + 8 * math.Sin(float64(i) * math.Pi / float64(n))
+		}
+	}
+
+	calcAdxr(list, n)
+
+	first := 3*n -2
+	if list[first-1].Adxr != 0 {
+		t.Errorf("ADXR must not be set before the lagged ADX is available. Got %v", list[first-1].Adxr)
+	}
+
+	//--- Total variation: the swings cancel out in the mean
+
+	moves, movesAdx := 0.0, 0.0
+
+	for i := first; i < len(list); i++ {
+		if trend := float64(i); list[i].Adxr >= trend {
+			t.Fatalf("ADXR must lag the rising ADX at %d. Got %v, trend %v", i, list[i].Adxr, trend)
+		}
+
+		if i > first {
+			moves    += math.Abs(list[i].Adxr  - list[i-1].Adxr)
+			movesAdx += math.Abs(list[i].Adx14 - list[i-1].Adx14)
+		}
+	}
+
+	if moves >= movesAdx {
+		t.Errorf("ADXR must be smoother than ADX. Got a variation of %v, ADX %v", moves, movesAdx)
+	}
+}
+
+//=============================================================================
+
+func TestVwap(t *testing.T) {
+	points := buildDataPoints(buildCloses(40, 100, func(i int) float64 { return float64(i%4) -1 }))
+
+	//--- Without volume there is no VWAP
+
+	list := createBarResults(points, defaultParams())
+	calcVwap(list, DefaultVwapLen)
+
+	for i, br := range list {
+		if br.Vwap != 0 {
+			t.Fatalf("VWAP must not be set without volume. Got %v at %v", br.Vwap, i)
+		}
+	}
+
+	//--- With a constant volume VWAP is the SMA of the typical price
+
+	for _, dp := range points {
+		dp.UpVolume   = 600
+		dp.DownVolume = 400
+	}
+
+	list = createBarResults(points, defaultParams())
+	calcVwap(list, DefaultVwapLen)
+
+	typical := make([]float64, len(list))
+	for i, br := range list {
+		typical[i] = typicalPrice(br.point)
+	}
+
+	sma := smaSeries(typical, DefaultVwapLen, 0)
+
+	for i := DefaultVwapLen-1; i < len(list); i++ {
+		if math.Abs(list[i].Vwap - sma[i]) > 1e-9 {
+			t.Fatalf("VWAP must match the typical price SMA at %v. Got %v, expected %v", i, list[i].Vwap, sma[i])
+		}
+	}
+}
+
+//=============================================================================
+
+func TestSqnPercentiles(t *testing.T) {
+	build := func(values ...float64) []*BarResult {
+		var list []*BarResult
+		for _, v := range values {
+			list = append(list, &BarResult{ Sqn100: v })
+		}
+		return list
+	}
+
+	list := build(0.5, -1.2, 2.3, 0.1, 1.4)
+	calcSqnPercentiles(list)
+
+	if list[1].SqnPercentile != 0 || list[2].SqnPercentile != 100 || list[0].SqnPercentile != 50 {
+		t.Errorf("Bad percentiles: min %v, max %v, median %v", list[1].SqnPercentile, list[2].SqnPercentile, list[0].SqnPercentile)
+	}
+
+	//--- Ties share the average rank
+
+	list = build(1, 2, 2, 3)
+	calcSqnPercentiles(list)
+
+	if list[1].SqnPercentile != 50 || list[2].SqnPercentile != 50 {
+		t.Errorf("Ties must share the same percentile. Got %v and %v", list[1].SqnPercentile, list[2].SqnPercentile)
+	}
+
+	//--- Degenerate cases
+
+	list = build(0.7)
+	calcSqnPercentiles(list)
+
+	if list[0].SqnPercentile != 50 {
+		t.Errorf("A single value must be at the middle. Got %v", list[0].SqnPercentile)
+	}
+
+	list = build(1, 1, 1)
+	calcSqnPercentiles(list)
+
+	for _, br := range list {
+		if br.SqnPercentile != 50 {
+			t.Errorf("Equal values must be at the middle. Got %v", br.SqnPercentile)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestKeltner(t *testing.T) {
+	points := buildDataPoints(buildCloses(60, 100, func(i int) float64 { return float64(i%4) -1 }))
+	list   := createBarResults(points, defaultParams())
+
+	calcKeltner(list, DefaultKeltLen, DefaultKeltK)
+	calcMovingAverages(list, DefaultKeltLen)
+
+	for i := DefaultKeltLen-1; i < len(list); i++ {
+		br := list[i]
+		if math.Abs(br.KeltMiddle - br.Ema) > 1e-9 {
+			t.Fatalf("Keltner middle must be the EMA at %v. Got %v, expected %v", i, br.KeltMiddle, br.Ema)
+		}
+
+		if math.Abs(br.KeltUpper - br.KeltMiddle - DefaultKeltK*br.Atr) > 1e-9 || math.Abs(br.KeltMiddle - br.KeltLower - DefaultKeltK*br.Atr) > 1e-9 {
+			t.Fatalf("Keltner bands must be k*ATR away from the middle at %v", i)
+		}
+	}
+
+	//--- Wider ranges give wider channels
+
+	for _, dp := range points {
+		dp.High += 3
+		dp.Low  -= 3
+	}
+
+	wide := createBarResults(points, defaultParams())
+	calcKeltner(wide, DefaultKeltLen, DefaultKeltK)
+
+	last := len(list) -1
+	if wide[last].KeltUpper - wide[last].KeltLower <= list[last].KeltUpper - list[last].KeltLower {
+		t.Errorf("Channel must widen with the ATR")
+	}
+}
+
+//=============================================================================
+
+func TestMfi(t *testing.T) {
+	points := buildDataPoints(buildCloses(40, 100, func(i int) float64 { return 1 }))
+	for _, dp := range points {
+		dp.UpVolume = 1000
+	}
+
+	//--- All flows are positive
+
+	list := createBarResults(points, defaultParams())
+	calcMfi(list, DefaultMfiLen)
+
+	for i := DefaultMfiLen-1; i < len(list); i++ {
+		if list[i].Mfi14 != 100 {
+			t.Fatalf("MFI must be 100 without negative flow at %v. Got %v", i, list[i].Mfi14)
+		}
+	}
+
+	if list[DefaultMfiLen-2].Mfi14 != 0 {
+		t.Errorf("MFI must not be set during warm-up")
+	}
+
+	//--- Balanced flows (same volume, same price moves up and down)
+
+	points = buildDataPoints(buildCloses(40, 100, func(i int) float64 { return float64(2*(i%2) -1) }))
+	for _, dp := range points {
+		dp.UpVolume = 1000
+	}
+
+	list = createBarResults(points, defaultParams())
+	calcMfi(list, DefaultMfiLen)
+
+	if mfi := list[len(list)-1].Mfi14; math.Abs(mfi - 50) > 1 {
+		t.Errorf("MFI must be around 50 with balanced flows. Got %v", mfi)
+	}
+}
+
+//=============================================================================
+
+func TestObv(t *testing.T) {
+	points := buildDataPoints([]float64{ 100, 101, 102, 103, 101 })
+	for i, dp := range points {
+		dp.UpVolume = 100 * (i+1)
+	}
+
+	//--- The first result is the seed, then up, up, down
+
+	list := createBarResults(points, defaultParams())
+	calcObv(list, 0)
+
+	expected := []float64{ 0, 300, 700, 200 }
+
+	for i, br := range list {
+		if br.Obv != expected[i] {
+			t.Errorf("Bad OBV at %d. Got %v, expected %v", i, br.Obv, expected[i])
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRocAndMomentum(t *testing.T) {
+	const step = 2.0
+
+	points := buildDataPoints(buildCloses(60, 100, func(i int) float64 { return step }))
+	list   := createBarResults(points, defaultParams())
+
+	calcRoc(list, DefaultRocLen)
+
+	for i, br := range list {
+		if i < DefaultRocLen {
+			if br.Roc12 != 0 || br.Momentum12 != 0 {
+				t.Fatalf("ROC and momentum must not be set during warm-up at %d", i)
+			}
+			continue
+		}
+
+		if br.Momentum12 != step * DefaultRocLen {
+			t.Fatalf("Bad momentum at %d. Got %v, expected %v", i, br.Momentum12, step * DefaultRocLen)
+		}
+
+		//--- On a linear series the ROC slowly decreases as prices grow
+
+		expected := step * DefaultRocLen / list[i - DefaultRocLen].Close
+		if math.Abs(br.Roc12 - expected) > 1e-12 {
+			t.Fatalf("Bad ROC at %d. Got %v, expected %v", i, br.Roc12, expected)
+		}
+
+		if i > DefaultRocLen && br.Roc12 >= list[i-1].Roc12 {
+			t.Fatalf("ROC must decrease on a linear series at %d", i)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestCci(t *testing.T) {
+	closes := make([]float64, 120)
+	for i := range closes {
+		closes[i] = 100 + 10*math.Sin(float64(i) * 2*math.Pi / 30)
+	}
+
+	list := createBarResults(buildDataPoints(closes), defaultParams())
+
+	calcCci(list, DefaultCciLen)
+
+	above, below := false, false
+
+	for i, br := range list {
+		if i < DefaultCciLen-1 && br.Cci20 != 0 {
+			t.Fatalf("CCI must not be set during warm-up at %d", i)
+		}
+
+		above = above || br.Cci20 >  100
+		below = below || br.Cci20 < -100
+	}
+
+	if !above || !below {
+		t.Errorf("CCI must cross both +100 and -100 on an oscillating series. Above: %v, below: %v", above, below)
+	}
+
+	//--- No deviation, no CCI
+
+	flat := createBarResults(buildDataPoints(buildCloses(30, 100, func(i int) float64 { return 0 })), defaultParams())
+
+	calcCci(flat, DefaultCciLen)
+
+	for i, br := range flat {
+		if br.Cci20 != 0 {
+			t.Fatalf("CCI must be 0 on a flat series. Got %v at %d", br.Cci20, i)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestPivots(t *testing.T) {
+	prev := &ds.DataPoint{ Time: startTime,                     High: 110, Low: 100, Close: 108 }
+	curr := &ds.DataPoint{ Time: startTime.Add(time.Hour * 24), High: 112, Low: 105, Close: 111 }
+
+	list := createBarResults([]*ds.DataPoint{ prev, curr }, defaultParams())
+
+	calcPivots(list)
+
+	//--- P = (110+100+108)/3 = 106, range = 10
+
+	br := list[0]
+	expected := []struct {
+		name  string
+		value float64
+		want  float64
+	}{
+		{ "pivot", br.Pivot,   106 },
+		{ "R1",    br.PivotR1, 112 },
+		{ "S1",    br.PivotS1, 102 },
+		{ "R2",    br.PivotR2, 116 },
+		{ "S2",    br.PivotS2,  96 },
+		{ "R3",    br.PivotR3, 122 },
+		{ "S3",    br.PivotS3,  92 },
+	}
+
+	for _, e := range expected {
+		if math.Abs(e.value - e.want) > 1e-9 {
+			t.Errorf("Bad %s. Got %v, expected %v", e.name, e.value, e.want)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestStochastic(t *testing.T) {
+	points := buildDataPoints(buildCloses(40, 100, func(i int) float64 { return 2 }))
+	list   := createBarResults(points, defaultParams())
+
+	calcStochastic(list, DefaultStochLen)
+
+	for i, br := range list {
+		if i < DefaultStochLen-1 {
+			if br.StochK != 0 {
+				t.Fatalf("%%K must not be set during warm-up at %d", i)
+			}
+			continue
+		}
+
+		//--- Each close is 1 below the window high, out of a range of 2*(len-1)+2
+
+		expected := 100 - 100 / float64(2*DefaultStochLen)
+		if math.Abs(br.StochK - expected) > 1e-9 {
+			t.Fatalf("%%K must be pinned near 100 in an uptrend. Got %v at %d, expected %v", br.StochK, i, expected)
+		}
+
+		if i >= DefaultStochLen + StochDLen -2 && math.Abs(br.StochD - expected) > 1e-9 {
+			t.Fatalf("Bad %%D at %d. Got %v, expected %v", i, br.StochD, expected)
+		}
+	}
+
+	//--- Flat window
+
+	flat := createBarResults(buildDataPoints(buildCloses(20, 100, func(i int) float64 { return 0 })), defaultParams())
+	for _, br := range flat {
+		br.point.High, br.point.Low = 100, 100
+	}
+
+	calcStochastic(flat, DefaultStochLen)
+
+	if last := flat[len(flat)-1]; last.StochK != 50 || last.StochD != 50 {
+		t.Errorf("A flat window must give 50. Got %%K=%v, %%D=%v", last.StochK, last.StochD)
+	}
+}
+
+//=============================================================================
+
+func TestWilliamsR(t *testing.T) {
+	closes := make([]float64, 80)
+	for i := range closes {
+		closes[i] = 100 + 10*math.Sin(float64(i) * 2*math.Pi / 25)
+	}
+
+	points := buildDataPoints(closes)
+	list   := createBarResults(points, defaultParams())
+
+	//--- Closes at the window's high give ~0: make the range come from closes only
+
+	for _, dp := range points {
+		dp.High, dp.Low = dp.Close, dp.Close
+	}
+
+	calcWilliamsR(list, DefaultWillrLen)
+
+	nearZero := false
+
+	for i, br := range list {
+		if i < DefaultWillrLen-1 {
+			if br.WilliamsR != 0 {
+				t.Fatalf("%%R must not be set during warm-up at %d", i)
+			}
+			continue
+		}
+
+		if br.WilliamsR < -100 || br.WilliamsR > 0 {
+			t.Fatalf("%%R must be in [-100..0]. Got %v at %d", br.WilliamsR, i)
+		}
+
+		high, _ := windowHighLow(list[i - DefaultWillrLen +1 : i+1])
+		if br.Close == high {
+			nearZero = nearZero || br.WilliamsR > -1e-9
+		}
+	}
+
+	if !nearZero {
+		t.Errorf("%%R must be 0 at the window's highs")
+	}
+}
+
+//=============================================================================
+
+func TestTrix(t *testing.T) {
+	first := 3*(DefaultTrixLen-1) +1
+
+	points := buildDataPoints(buildCloses(100, 100, func(i int) float64 { return 1 }))
+	list   := createBarResults(points, defaultParams())
+
+	calcTrix(list, DefaultTrixLen)
+
+	for i, br := range list {
+		if i < first {
+			if br.Trix15 != 0 {
+				t.Fatalf("TRIX must not be set during warm-up at %d", i)
+			}
+		} else if br.Trix15 <= 0 {
+			t.Fatalf("TRIX must be positive in an uptrend. Got %v at %d", br.Trix15, i)
+		}
+	}
+
+	//--- Flat series
+
+	flat := createBarResults(buildDataPoints(buildCloses(100, 100, func(i int) float64 { return 0 })), defaultParams())
+
+	calcTrix(flat, DefaultTrixLen)
+
+	for i, br := range flat {
+		if math.Abs(br.Trix15) > 1e-12 {
+			t.Fatalf("TRIX must be 0 on a flat series. Got %v at %d", br.Trix15, i)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestLinReg(t *testing.T) {
+	points := buildDataPoints(buildCloses(50, 100, func(i int) float64 { return 0.5 }))
+	list   := createBarResults(points, defaultParams())
+
+	calcLinReg(list, DefaultRegLen)
+
+	for i, br := range list {
+		if i < DefaultRegLen-1 {
+			if br.RegSlope != 0 || br.RegR2 != 0 {
+				t.Fatalf("Regression must not be set during warm-up at %d", i)
+			}
+			continue
+		}
+
+		if math.Abs(br.RegSlope - 0.5) > 1e-9 || math.Abs(br.RegR2 - 1) > 1e-9 {
+			t.Fatalf("A linear series must fit perfectly. Got slope=%v, R²=%v at %d", br.RegSlope, br.RegR2, i)
+		}
+	}
+
+	//--- Flat series
+
+	flat := createBarResults(buildDataPoints(buildCloses(50, 100, func(i int) float64 { return 0 })), defaultParams())
+
+	calcLinReg(flat, DefaultRegLen)
+
+	if last := flat[len(flat)-1]; math.Abs(last.RegSlope) > 1e-12 || last.RegR2 != 0 {
+		t.Errorf("A flat series must have no slope. Got slope=%v, R²=%v", last.RegSlope, last.RegR2)
+	}
+}
+
+//=============================================================================
+
+func TestSar(t *testing.T) {
+	//--- A clean uptrend followed by a sharp reversal
+
+	closes := buildCloses(60, 100, func(i int) float64 {
+		if i >= 40 {
+			return -3
+		}
+		return 1
+	})
+
+	list := createBarResults(buildDataPoints(closes), defaultParams())
+	calcSar(list, DefaultSarStart, DefaultSarStep, DefaultSarMax)
+
+	for i, br := range list[1:40] {
+		if br.Sar >= br.point.Low {
+			t.Fatalf("SAR must stay below the price in the uptrend. Got %v (low %v) at %d", br.Sar, br.point.Low, i+1)
+		}
+	}
+
+	flip := -1
+
+	for i := 40; i < len(list); i++ {
+		if list[i].Sar > list[i].point.High {
+			flip = i
+			break
+		}
+	}
+
+	if flip == -1 || flip > 43 {
+		t.Fatalf("SAR must flip above the price soon after the reversal. Got %d", flip)
+	}
+
+	//--- After the flip the stop restarts from the uptrend's extreme
+
+	if list[flip].Sar != list[39].point.High {
+		t.Errorf("SAR must restart from the extreme point. Expected %v, got %v", list[39].point.High, list[flip].Sar)
+	}
+
+	for i := flip; i < len(list); i++ {
+		if list[i].Sar <= list[i].point.High {
+			t.Fatalf("SAR must stay above the price in the downtrend. Got %v (high %v) at %d", list[i].Sar, list[i].point.High, i)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestIchimoku(t *testing.T) {
+	//--- Down for 100 bars, then up
+
+	closes := buildCloses(200, 300, func(i int) float64 {
+		if i < 100 {
+			return -1
+		}
+		return 1
+	})
+
+	list := createBarResults(buildDataPoints(closes), defaultParams())
+	calcIchimoku(list, DefaultIchiTenkan, DefaultIchiKijun, DefaultIchiSenkou)
+
+	//--- Tenkan is below Kijun in the downtrend and crosses above it after the reversal
+
+	cross := -1
+
+	for i := DefaultIchiKijun; i < len(list); i++ {
+		above    := list[i].Tenkan > list[i].Kijun
+		wasAbove  := list[i-1].Tenkan > list[i-1].Kijun
+
+		if i < 99 && above {
+			t.Fatalf("Tenkan must be below Kijun in the downtrend at %d. Got %v and %v", i, list[i].Tenkan, list[i].Kijun)
+		}
+
+		if above && !wasAbove {
+			cross = i
+			break
+		}
+	}
+
+	if cross < 99 || cross > 99 + DefaultIchiKijun {
+		t.Fatalf("Tenkan must cross above Kijun soon after the reversal. Got %d", cross)
+	}
+
+	//--- Shifted lines
+
+	for i, br := range list {
+		if j := i - DefaultIchiKijun; j >= DefaultIchiKijun-1 {
+			if exp := (list[j].Tenkan + list[j].Kijun) / 2; br.SenkouA != exp {
+				t.Fatalf("Senkou A must be shifted forward at %d. Got %v, expected %v", i, br.SenkouA, exp)
+			}
+		} else if br.SenkouA != 0 {
+			t.Fatalf("Senkou A must not be set at %d", i)
+		}
+
+		if j := i + DefaultIchiKijun; j < len(list) {
+			if br.Chikou != list[j].Close {
+				t.Fatalf("Chikou must be shifted backward at %d. Got %v, expected %v", i, br.Chikou, list[j].Close)
+			}
+		} else if br.Chikou != 0 {
+			t.Fatalf("Chikou must not be set on the last bars at %d", i)
+		}
+	}
+
+	if first := DefaultIchiKijun + DefaultIchiSenkou -1; list[first-1].SenkouB != 0 || list[first].SenkouB == 0 {
+		t.Errorf("Senkou B must start at %d", first)
+	}
+}
+
+//=============================================================================
+
+func TestCmf(t *testing.T) {
+	//--- Closes near the highs: buying pressure
+
+	points := buildDataPoints(buildCloses(40, 100, func(i int) float64 { return float64(i%3) -1 }))
+	for _, dp := range points {
+		dp.High     = dp.Close + 0.5
+		dp.Low      = dp.Close - 2
+		dp.UpVolume = 1000
+	}
+
+	list := createBarResults(points, defaultParams())
+	calcCmf(list, DefaultCmfLen)
+
+	//--- Multiplier is ((c-l) - (h-c)) / (h-l) = (2 - 0.5) / 2.5 = 0.6 on every bar
+
+	for i := DefaultCmfLen-1; i < len(list); i++ {
+		if math.Abs(list[i].Cmf20 - 0.6) > 1e-9 {
+			t.Fatalf("Bad CMF at %d. Got %v, expected 0.6", i, list[i].Cmf20)
+		}
+	}
+
+	if list[DefaultCmfLen-2].Cmf20 != 0 {
+		t.Errorf("CMF must not be set during warm-up")
+	}
+
+	//--- Bars with no range and no volume
+
+	for _, dp := range points {
+		dp.High, dp.Low = dp.Close, dp.Close
+	}
+
+	calcCmf(list, DefaultCmfLen)
+
+	if cmf := list[len(list)-1].Cmf20; cmf != 0 {
+		t.Errorf("Bars with no range must add no flow. Got %v", cmf)
+	}
+
+	for _, dp := range points {
+		dp.High, dp.Low = dp.Close + 1, dp.Close - 1
+		dp.UpVolume     = 0
+	}
+
+	calcCmf(list, DefaultCmfLen)
+
+	if cmf := list[len(list)-1].Cmf20; cmf != 0 || math.IsNaN(cmf) {
+		t.Errorf("No volume must give 0. Got %v", cmf)
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,151 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/core/req"
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+
+const (
+	//--- Warm-up periods fetched for the latest result. Recursive indicators (EMAs,
+	//--- Wilder's smoothing, SAR) converge over a few warm-ups
+	LatestHistoryFactor = 3
+
+	//--- Times the history is doubled when it has too few bars (holidays, short sessions)
+	LatestMaxAttempts = 4
+)
+
+//=============================================================================
+//--- Returns the most recent complete bar result, fetching only the history needed
+//--- to warm up the indicators before the end of the range (now when not given).
+//--- Window based indicators match the full analysis, recursive ones (EMA, MACD, RSI,
+//--- ATR, Keltner, ADX, TRIX, SAR) converge to it within the reported precision.
+//--- Two fields depend on where the fetched history starts and are approximate:
+//--- OBV, which accumulates from the first bar, and the SQN percentile, which ranks
+//--- the SQN within the fetched bars
+
+func LatestResult(c *auth.Context, spec *DataProductAnalysisSpec) (*BarResult, error) {
+	ap, err := NewAnalysisParams(spec)
+	if err != nil {
+		return nil, req.NewBadRequestError(err.Error())
+	}
+
+	base := *spec
+	base.Timeframe = strconv.Itoa(ap.fetchTimeframe())
+
+	params, err := NewQueryParams(&base.QuerySpec)
+	if err != nil {
+		return nil, req.NewBadRequestError(err.Error())
+	}
+
+	to := time.Now()
+	if params.To != nil {
+		to = *params.To
+	}
+
+	base.Timeframe      = spec.Timeframe
+	base.DaysBack       = ""
+	base.To             = to.In(params.TargetLoc).Format(time.DateTime)
+	base.Tail           = "1"
+	base.Offset         = ""
+	base.IncludePartial = ""
+	base.ComparePrior   = ""
+	base.Previous       = nil
+
+	days := historyDays(ap, spec.Config.TradingSession, (ap.warmupBars() +1) * LatestHistoryFactor)
+
+	for attempt := 0; attempt < LatestMaxAttempts; attempt++ {
+		base.From = to.AddDate(0, 0, -days).In(params.TargetLoc).Format(time.DateTime)
+
+		res, err := AnalyzeProduct(c, &base)
+		if err == nil {
+			if len(res.BarResults) == 0 {
+				return nil, ErrNoData
+			}
+
+			return res.BarResults[0], nil
+		}
+
+		if !errors.Is(err, ErrInsufficientHistory) {
+			return nil, err
+		}
+
+		days *= 2
+	}
+
+	return nil, req.NewBadRequestError("not enough history to compute a complete result")
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- Calendar days covering the given bars. Daily and weekly bars assume 5 trading
+//--- days a week. Intraday bars are counted on the minutes traded in a week: the ones
+//--- of the product's session, narrowed by the analysis session when bars are filtered.
+//--- Holidays and missing bars are covered by an extra week
+
+func historyDays(ap *AnalysisParams, session *types.TradingSession, bars int) int {
+	minutes := bars * ap.Timeframe
+
+	if ap.Timeframe >= DefaultTimeframe {
+		return minutes / DefaultTimeframe * 7 / 5 +7
+	}
+
+	weekMinutes := weeklySessionMinutes(ap, session)
+
+	return (minutes * 7 + weekMinutes -1) / weekMinutes +7
+}
+
+//=============================================================================
+//--- Minutes traded in a week. Without a session, markets trade round the clock
+//--- from Monday to Friday
+
+func weeklySessionMinutes(ap *AnalysisParams, session *types.TradingSession) int {
+	minutes := 0
+
+	if session != nil {
+		for _, slot := range session.Slots {
+			length := slot.Close.AsMinutes() - slot.Open.AsMinutes()
+			if length <= 0 {
+				length += DefaultTimeframe
+			}
+
+			minutes += length
+		}
+	}
+
+	if minutes == 0 {
+		minutes = 5 * DefaultTimeframe
+	}
+
+	if ap.hasSession() {
+		length := ap.SessionEnd - ap.SessionStart
+		if length <= 0 {
+			length += DefaultTimeframe
+		}
+
+		minutes = min(minutes, 5 * length)
+	}
+
+	return minutes
+}
+
+//=============================================================================
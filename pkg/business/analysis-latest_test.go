@@ -0,0 +1,145 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+
+func TestLatestResult(t *testing.T) {
+	points := buildDataPoints(buildCloses(600, 100, func(i int) float64 { return math.Sin(float64(i) / 5) }))
+
+	for i, dp := range points {
+		dp.UpVolume   = 100 + i % 7 * 10
+		dp.DownVolume =  50 + i % 5 * 10
+	}
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			To      : "2026-01-01 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		Source: NewSliceDataSource(points),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	full, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	latest, err := LatestResult(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	last := full.BarResults[len(full.BarResults)-1]
+
+	if !latest.Time.Equal(last.Time) || latest.Close != last.Close || latest.Partial {
+		t.Fatalf("Bad latest bar. Got %v (%v), expected %v (%v)", latest.Time, latest.Close, last.Time, last.Close)
+	}
+
+	//--- Window based indicators match exactly
+
+	if latest.Sqn100 != last.Sqn100 || latest.SqnSlope != last.SqnSlope || latest.Direction != last.Direction ||
+		latest.Sma != last.Sma || latest.BollUpper != last.BollUpper || latest.DonchianHigh != last.DonchianHigh ||
+		latest.Vwap != last.Vwap || latest.Mfi14 != last.Mfi14 || latest.Cmf20 != last.Cmf20 || latest.Cci20 != last.Cci20 ||
+		latest.StochD != last.StochD || latest.RegSlope != last.RegSlope || latest.SenkouB != last.SenkouB ||
+		latest.RollingSharpe != last.RollingSharpe || latest.AnomalyScore != last.AnomalyScore {
+		t.Errorf("Window based indicators differ from the full analysis. Got %+v, expected %+v", latest, last)
+	}
+
+	//--- Recursive ones have converged within the reported precision
+
+	if latest.Ema != last.Ema || latest.MacdLine != last.MacdLine || latest.MacdSignal != last.MacdSignal ||
+		latest.Rsi != last.Rsi || latest.Atr != last.Atr || latest.AtrPerc != last.AtrPerc || latest.KeltUpper != last.KeltUpper ||
+		latest.Adx14 != last.Adx14 || latest.Adxr != last.Adxr || latest.Trix15 != last.Trix15 || latest.Sar != last.Sar ||
+		latest.Volatility != last.Volatility {
+		t.Errorf("Recursive indicators must converge. Got %+v, expected %+v", latest, last)
+	}
+
+	//--- OBV and the SQN percentile depend on the fetched history
+
+	if latest.Obv == last.Obv {
+		t.Errorf("OBV must accumulate from the fetched history. Got %v", latest.Obv)
+	}
+
+	if latest.SqnPercentile < 0 || latest.SqnPercentile > 100 {
+		t.Errorf("Bad SQN percentile. Got %v", latest.SqnPercentile)
+	}
+
+	//--- Not enough history
+
+	spec.Source = NewSliceDataSource(points[len(points)-50:])
+
+	if _, err = LatestResult(c, spec); err == nil {
+		t.Errorf("An error is expected when no complete result can be computed")
+	}
+}
+
+//=============================================================================
+
+func TestHistoryDays(t *testing.T) {
+	ap := defaultParams()
+	ap.Timeframe = 60
+
+	//--- 23 hours a day, 5 days a week
+
+	if days := historyDays(ap, buildQueryConfig().TradingSession, 1380); days != 12*7 +7 {
+		t.Errorf("Bad days for a round the clock session. Got %d", days)
+	}
+
+	//--- Regular trading hours: 6.5 hours a day
+
+	rth, err := types.NewTradingSession(`{ "slots": [
+		{ "day":1, "open": 930, "close": 1600, "end": true },
+		{ "day":2, "open": 930, "close": 1600, "end": true },
+		{ "day":3, "open": 930, "close": 1600, "end": true },
+		{ "day":4, "open": 930, "close": 1600, "end": true },
+		{ "day":5, "open": 930, "close": 1600, "end": true }
+	]}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if days := historyDays(ap, rth, 65); days != 2*7 +7 {
+		t.Errorf("Bad days for regular trading hours. Got %d", days)
+	}
+
+	//--- The analysis session narrows the product's one
+
+	ap.SessionStart, ap.SessionEnd = 9*60 +30, 16*60
+
+	if days := historyDays(ap, buildQueryConfig().TradingSession, 65); days != 2*7 +7 {
+		t.Errorf("Bad days for a filtered session. Got %d", days)
+	}
+
+	//--- Daily bars
+
+	ap = defaultParams()
+
+	if days := historyDays(ap, rth, 100); days != 100*7/5 +7 {
+		t.Errorf("Bad days for daily bars. Got %d", days)
+	}
+}
+
+//=============================================================================
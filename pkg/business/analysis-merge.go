@@ -0,0 +1,164 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+//--- Joins the analyses of consecutive windows of the same product into one. Bars
+//--- are concatenated by time: on overlaps the bar of the earlier window is kept.
+//--- Windows must be contiguous (the next one starts at most the day after the
+//--- previous one ends). The summary values depend on the whole data series, so
+//--- they are not available on the merged response
+
+func MergeResponses(responses ...*DataProductAnalysisResponse) (*DataProductAnalysisResponse, error) {
+	if len(responses) == 0 {
+		return nil, errors.New("no responses to merge")
+	}
+
+	list := slices.Clone(responses)
+
+	for _, r := range list[1:] {
+		if r.Symbol != list[0].Symbol || r.Id != list[0].Id {
+			return nil, errors.New("cannot merge responses of different products ("+ list[0].Symbol +" and "+ r.Symbol +")")
+		}
+
+		if r.Timeframe != list[0].Timeframe || r.SchemaVersion != list[0].SchemaVersion {
+			return nil, errors.New("cannot merge responses with different timeframes or schema versions")
+		}
+	}
+
+	slices.SortStableFunc(list, func(a, b *DataProductAnalysisResponse) int {
+		fromA, _ := responseRange(a)
+		fromB, _ := responseRange(b)
+		return int(fromA - fromB)
+	})
+
+	from, to := responseRange(list[0])
+
+	var bars []*BarResult
+	var gaps []*BarGap
+
+	for i, r := range list {
+		currFrom, currTo := responseRange(r)
+
+		if i > 0 && currFrom > to.AddDays(1) {
+			return nil, errors.New("responses are not contiguous (gap between "+ to.String() +" and "+ currFrom.String() +")")
+		}
+
+		for _, br := range ascendingBars(r) {
+			if len(bars) == 0 || br.Time.After(bars[len(bars)-1].Time) {
+				bars = append(bars, br)
+			}
+		}
+
+		gaps = append(gaps, r.Gaps...)
+		to   = max(to, currTo)
+	}
+
+	//--- Parameters are taken from the first window
+
+	merged := *list[0]
+	merged.RequestedFrom = from
+	merged.RequestedTo   = to
+	merged.Bars          = len(bars)
+	merged.TotalBars     = merged.WarmupBars + len(bars)
+	merged.RawPoints     = 0
+	merged.Overflow      = false
+	merged.Tail          = 0
+	merged.Offset        = 0
+	merged.Error         = ""
+	merged.Gaps          = gaps
+	merged.BarResults    = bars
+	merged.Notes         = []string{ "Merged from "+ strconv.Itoa(len(list)) +" responses: summary values are not available" }
+	merged.reused        = 0
+
+	merged.From, merged.To = barsRange(bars)
+
+	clearSummary(&merged)
+
+	if merged.Order == OrderDesc {
+		slices.Reverse(merged.BarResults)
+	}
+
+	merged.Checksum = calcChecksum(&merged)
+
+	return &merged, nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- Requested range or, when open, the range covered by the bars
+
+func responseRange(r *DataProductAnalysisResponse) (types.Date, types.Date) {
+	from, to    := r.RequestedFrom, r.RequestedTo
+	first, last    := barsRange(ascendingBars(r))
+
+	if from.IsNil() {
+		from = first
+	}
+
+	if to.IsNil() {
+		to = last
+	}
+
+	return from, to
+}
+
+//=============================================================================
+//--- Dates of the first and last bar. Bars must be sorted oldest first
+
+func barsRange(bars []*BarResult) (types.Date, types.Date) {
+	n := len(bars)
+	if n == 0 {
+		return 0, 0
+	}
+
+	return types.ToDate(&bars[0].Time), types.ToDate(&bars[n-1].Time)
+}
+
+//=============================================================================
+
+func ascendingBars(r *DataProductAnalysisResponse) []*BarResult {
+	if r.Order != OrderDesc {
+		return r.BarResults
+	}
+
+	bars := slices.Clone(r.BarResults)
+	slices.Reverse(bars)
+
+	return bars
+}
+
+//=============================================================================
+
+func clearSummary(r *DataProductAnalysisResponse) {
+	r.Sharpe, r.Sortino, r.HistVol, r.MaxDrawdown = 0, 0, 0, 0
+	r.DrawdownPeak, r.DrawdownTrough              = nil, nil
+	r.UpDays, r.DownDays, r.FlatDays              = 0, 0, 0
+	r.AvgUpPerc, r.AvgDownPerc                    = 0, 0
+	r.Skewness, r.Kurtosis                        = 0, 0
+	r.Var95, r.Var99, r.Cvar95, r.Cvar99          = 0, 0, 0, 0
+	r.RegimeChanges                               = nil
+	r.PeriodDeltas                                = nil
+	r.AutoCorr                                    = nil
+}
+
+//=============================================================================
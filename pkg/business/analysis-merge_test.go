@@ -0,0 +1,124 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+
+func buildMonthResponse(symbol string, from time.Time, to time.Time) *DataProductAnalysisResponse {
+	res := &DataProductAnalysisResponse{
+		SchemaVersion: AnalysisSchemaVersion,
+		Symbol       : symbol,
+		From         : types.ToDate(&from),
+		To           : types.ToDate(&to),
+		Timeframe    : DefaultTimeframe,
+		Sharpe       : 1.5,
+	}
+
+	for d := from; !d.After(to); d = d.AddDate(0, 0, 1) {
+		res.BarResults = append(res.BarResults, &BarResult{ Time: d, Close: float64(d.Day()) })
+	}
+
+	res.Bars = len(res.BarResults)
+
+	return res
+}
+
+//=============================================================================
+
+func TestMergeResponses(t *testing.T) {
+	jan := buildMonthResponse("ES", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+	feb := buildMonthResponse("ES", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC))
+
+	//--- An overlapping bar, that must be taken from January
+
+	feb.BarResults = append([]*BarResult{{ Time: jan.BarResults[30].Time, Close: -1 }}, feb.BarResults...)
+
+	res, err := MergeResponses(feb, jan)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.From != types.NewDate(2024, 1, 1) || res.To != types.NewDate(2024, 2, 29) {
+		t.Errorf("Bad range. Got %v..%v", res.From, res.To)
+	}
+
+	if res.Bars != 60 || len(res.BarResults) != 60 {
+		t.Fatalf("Bad bars. Got %d, expected 60", res.Bars)
+	}
+
+	for i := 1; i < len(res.BarResults); i++ {
+		if !res.BarResults[i].Time.After(res.BarResults[i-1].Time) {
+			t.Fatalf("Bars must be sorted and unique at %d", i)
+		}
+	}
+
+	if res.BarResults[30].Close != 31 {
+		t.Errorf("On overlaps the earlier window must win. Got %v", res.BarResults[30].Close)
+	}
+
+	if res.Sharpe != 0 || len(res.Notes) == 0 {
+		t.Errorf("Summary values must be cleared and explained. Got sharpe=%v, notes=%v", res.Sharpe, res.Notes)
+	}
+
+	//--- Errors
+
+	nq := buildMonthResponse("NQ", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC))
+	if _, err = MergeResponses(jan, nq); err == nil {
+		t.Errorf("Different symbols must not be merged")
+	}
+
+	mar := buildMonthResponse("ES", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC))
+	if _, err = MergeResponses(jan, mar); err == nil {
+		t.Errorf("Non contiguous windows must not be merged")
+	}
+
+	if _, err = MergeResponses(); err == nil {
+		t.Errorf("No responses must be an error")
+	}
+}
+
+//=============================================================================
+
+func TestMergeDescResponses(t *testing.T) {
+	jan := buildMonthResponse("ES", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC))
+	feb := buildMonthResponse("ES", time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC))
+
+	for _, r := range []*DataProductAnalysisResponse{ jan, feb } {
+		r.Order = OrderDesc
+		r.From, r.To = 0, 0
+		slices.Reverse(r.BarResults)
+	}
+
+	res, err := MergeResponses(feb, jan)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(res.BarResults) != 60 {
+		t.Fatalf("Bad bars. Got %d, expected 60", len(res.BarResults))
+	}
+
+	for i := 1; i < len(res.BarResults); i++ {
+		if !res.BarResults[i].Time.Before(res.BarResults[i-1].Time) {
+			t.Fatalf("Bars must be sorted newest first at %d", i)
+		}
+	}
+}
+
+//=============================================================================
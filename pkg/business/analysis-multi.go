@@ -0,0 +1,168 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/core/req"
+	"github.com/algotiqa/data-collector/pkg/core"
+	"github.com/algotiqa/data-collector/pkg/ds"
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+//--- Runs the analysis on each timeframe, returning the responses keyed by the
+//--- given timeframes. Data points are fetched once on the finest timeframe and
+//--- aggregated up for the other ones
+
+func AnalyzeProductMulti(c *auth.Context, spec *DataProductAnalysisSpec, timeframes []string) (map[string]*DataProductAnalysisResponse, error) {
+	if len(timeframes) == 0 {
+		return nil, req.NewBadRequestError("Missing timeframes")
+	}
+
+	finest := 0
+
+	for _, tf := range timeframes {
+		value, err := parseAnalysisTimeframe(tf)
+		if err != nil {
+			return nil, req.NewBadRequestError("Bad 'timeframe': "+ tf +" ("+ err.Error() +")")
+		}
+
+		ap := AnalysisParams{ Timeframe: value }
+
+		if finest == 0 || ap.fetchTimeframe() < finest {
+			finest = ap.fetchTimeframe()
+		}
+	}
+
+	base := *spec
+	base.Timeframe = strconv.Itoa(finest)
+
+	params, err := NewQueryParams(&base.QuerySpec)
+	if err != nil {
+		return nil, req.NewBadRequestError(err.Error())
+	}
+
+	//--- A range given as days back depends on the current time: it is fixed here,
+	//--- so that all the analyses read the same data points
+
+	if base.DaysBack != "" && params.From != nil && params.To != nil {
+		base.From     = params.From.In(params.TargetLoc).Format(time.DateTime)
+		base.To       = params.To.In(params.TargetLoc).Format(time.DateTime)
+		base.DaysBack = ""
+
+		if params, err = NewQueryParams(&base.QuerySpec); err != nil {
+			return nil, req.NewBadRequestError(err.Error())
+		}
+	}
+
+	dataPoints, err := spec.dataSource().Fetch(requestContext(c), params, spec.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &sharedSource{
+		dataPoints: dataPoints,
+		timeframe : finest,
+		params    : params,
+		session   : spec.Config.TradingSession,
+		fallback  : spec.dataSource(),
+	}
+
+	result := map[string]*DataProductAnalysisResponse{}
+
+	for _, tf := range timeframes {
+		s := base
+		s.Timeframe = tf
+		s.Source    = source
+
+		res, err := AnalyzeProduct(c, &s)
+		if err != nil {
+			return nil, err
+		}
+
+		result[tf] = res
+	}
+
+	return result, nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- Serves the data points fetched once for all the timeframes, aggregating them
+//--- when a coarser timeframe is requested. Other ranges (like the prior period)
+//--- are read from the original source
+
+type sharedSource struct {
+	dataPoints []*ds.DataPoint
+	timeframe  int
+	params     *QueryParams
+	session    *types.TradingSession
+	fallback   DataSource
+}
+
+//=============================================================================
+
+func (s *sharedSource) Fetch(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+	if !sameTime(params.From, s.params.From) || !sameTime(params.To, s.params.To) {
+		return s.fallback.Fetch(ctx, params, config)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	list := cloneDataPoints(s.dataPoints)
+
+	if params.Timeframe == s.timeframe {
+		return list, nil
+	}
+
+	var da ds.DataAggregator
+
+	if params.Timeframe == DefaultTimeframe {
+		da = ds.NewDailyAggregator(s.session)
+	} else {
+		da = ds.NewStandardAggregator(s.session, s.timeframe, params.Timeframe)
+	}
+
+	da.SetCloseFunc(params.CloseFunc)
+
+	//--- Aggregators work in the product's timezone
+
+	for _, dp := range list {
+		dp.Time = dp.Time.In(params.ProductLoc)
+		da.Add(dp)
+	}
+
+	da.Flush()
+
+	return da.ToTimezone(params.TargetLoc).DataPoints(), nil
+}
+
+//=============================================================================
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
+//=============================================================================
@@ -0,0 +1,106 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/data-collector/pkg/core"
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+
+func TestAnalyzeProductMulti(t *testing.T) {
+	points  := buildDataPoints(buildCloses(800, 100, func(i int) float64 { return float64(i%5) -1.5 }))
+	slice   := NewSliceDataSource(points)
+	fetches := 0
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			To      : "2026-03-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		SqnLen: "30",
+		Source: DataSourceFunc(func(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+			fetches++
+			return slice.Fetch(ctx, params, config)
+		}),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	result, err := AnalyzeProductMulti(c, spec, []string{ "1440", "10080" })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if fetches != 1 {
+		t.Errorf("Data points must be fetched once. Got %d fetches", fetches)
+	}
+
+	daily, weekly := result["1440"], result["10080"]
+
+	if daily == nil || weekly == nil || daily.Timeframe != DefaultTimeframe || weekly.Timeframe != WeeklyTimeframe {
+		t.Fatalf("Bad responses: %v", result)
+	}
+
+	//--- Weeks hold 7 consecutive days of the test data
+
+	if weekly.TotalBars < len(points)/7 || weekly.TotalBars > len(points)/7 +1 {
+		t.Errorf("Bad number of weekly bars. Got %d for %d days", weekly.TotalBars, len(points))
+	}
+
+	//--- Each weekly bar closes with the last day of its week. The last one is still open
+
+	closes := map[string]float64{}
+	for _, br := range daily.BarResults {
+		closes[br.Time.Format("2006-01-02")] = br.Close
+	}
+
+	for i, br := range weekly.BarResults {
+		if i < len(weekly.BarResults)-1 && br.Time.Weekday() != time.Sunday {
+			t.Fatalf("Weekly bars must end on the last day of the week. Got %v", br.Time)
+		}
+
+		if c, ok := closes[br.Time.Format("2006-01-02")]; ok && c != br.Close {
+			t.Fatalf("Weekly close must be the daily close at %v. Got %v, expected %v", br.Time, br.Close, c)
+		}
+	}
+
+	//--- Same result as a weekly analysis on its own
+
+	single := *spec
+	single.Timeframe = "10080"
+	single.Source    = slice
+
+	res, err := AnalyzeProduct(c, &single)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.Checksum != weekly.Checksum {
+		t.Errorf("The weekly response must match the single analysis")
+	}
+
+	if _, err = AnalyzeProductMulti(c, spec, []string{ "1440", "7" }); err == nil {
+		t.Errorf("An unknown timeframe must be rejected")
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,148 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"math"
+	"slices"
+
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+
+const (
+	OutlierModeClamp = "clamp"
+	OutlierModeDrop  = "drop"
+)
+
+//--- Scales the median absolute deviation to the standard deviation of a normal distribution
+
+const madToSigma = 1.4826
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- Finds the bars whose close moves more than 'sigma' standard deviations from
+//--- the previous (kept) close. The deviation is estimated with the median absolute
+//--- deviation of the returns, so that the outliers themselves don't inflate it.
+//--- Outliers are dropped or clamped into the allowed band. The original data
+//--- points are left untouched. Returns the filtered list and the outliers found
+
+func filterOutliers(dataPoints []*ds.DataPoint, sigma float64, mode string) ([]*ds.DataPoint, int) {
+	if sigma == 0 || len(dataPoints) < 3 {
+		return dataPoints, 0
+	}
+
+	median, dev := returnsMedianAndDeviation(dataPoints)
+	if dev == 0 {
+		return dataPoints, 0
+	}
+
+	maxMove := sigma * dev
+	list    := []*ds.DataPoint{ dataPoints[0] }
+	count   := 0
+
+	for _, dp := range dataPoints[1:] {
+		prevClose := list[len(list)-1].Close
+
+		if prevClose == 0 || math.Abs(dp.Close/prevClose -1 - median) <= maxMove {
+			list = append(list, dp)
+			continue
+		}
+
+		count++
+
+		if mode == OutlierModeClamp {
+			low  := prevClose * (1 + median - maxMove)
+			high := prevClose * (1 + median + maxMove)
+
+			clamped := *dp
+			clamped.Open  = min(max(dp.Open,  low), high)
+			clamped.High  = min(max(dp.High,  low), high)
+			clamped.Low   = min(max(dp.Low,   low), high)
+			clamped.Close = min(max(dp.Close, low), high)
+
+			list = append(list, &clamped)
+		}
+	}
+
+	return list, count
+}
+
+//=============================================================================
+
+func returnsMedianAndDeviation(dataPoints []*ds.DataPoint) (float64, float64) {
+	var returns []float64
+
+	for i := 1; i < len(dataPoints); i++ {
+		if prevClose := dataPoints[i-1].Close; prevClose != 0 {
+			returns = append(returns, dataPoints[i].Close/prevClose -1)
+		}
+	}
+
+	if len(returns) == 0 {
+		return 0, 0
+	}
+
+	median := medianValue(returns)
+
+	deviations := make([]float64, len(returns))
+	for i, r := range returns {
+		deviations[i] = math.Abs(r - median)
+	}
+
+	return median, medianValue(deviations) * madToSigma
+}
+
+//=============================================================================
+//--- Sets the statistics return of the bars, clipping the changes below the 'pct'
+//--- percentile and above the 100-pct one to the values at those percentiles.
+//--- With no winsorization the change is used as it is
+
+func winsorizeReturns(list []*BarResult, pct float64) {
+	for _, br := range list {
+		br.statsReturn = br.BarChangePerc
+	}
+
+	if pct == 0 || len(list) == 0 {
+		return
+	}
+
+	sorted := changeValues(list)
+	slices.Sort(sorted)
+
+	k    := int(math.Round(pct / 100 * float64(len(sorted)-1)))
+	low  := sorted[k]
+	high := sorted[len(sorted)-1 -k]
+
+	for _, br := range list {
+		br.statsReturn = min(max(br.BarChangePerc, low), high)
+	}
+}
+
+//=============================================================================
+
+func medianValue(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	n := len(sorted)
+	if n % 2 == 1 {
+		return sorted[n/2]
+	}
+
+	return (sorted[n/2 -1] + sorted[n/2]) / 2
+}
+
+//=============================================================================
@@ -0,0 +1,99 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"testing"
+)
+
+//=============================================================================
+
+func TestFilterOutliers(t *testing.T) {
+	points := buildDataPoints(buildCloses(100, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	//--- A bad print 10 times the neighbors
+
+	points[50].Close *= 10
+	points[50].High  *= 10
+
+	//--- Off by default
+
+	list, count := filterOutliers(points, 0, OutlierModeClamp)
+	if count != 0 || len(list) != len(points) || list[50].Close != points[50].Close {
+		t.Fatalf("The filter must be off by default")
+	}
+
+	//--- Clamped
+
+	list, count = filterOutliers(points, 5, OutlierModeClamp)
+	if count != 1 || len(list) != len(points) {
+		t.Fatalf("Only the spike must be clamped. Got %d outliers, %d points", count, len(list))
+	}
+
+	if list[50].Close > points[49].Close * 1.1 || list[50].High < list[50].Close {
+		t.Errorf("The spike must be clamped near the previous close. Got close=%v, high=%v", list[50].Close, list[50].High)
+	}
+
+	if points[50].Close < 900 {
+		t.Errorf("The original data points must be left untouched")
+	}
+
+	//--- Dropped
+
+	list, count = filterOutliers(points, 5, OutlierModeDrop)
+	if count != 1 || len(list) != len(points) -1 || list[50] != points[51] {
+		t.Errorf("Only the spike must be dropped. Got %d outliers, %d points", count, len(list))
+	}
+}
+
+//=============================================================================
+
+func TestWinsorizeReturns(t *testing.T) {
+	points := buildDataPoints(buildCloses(200, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	//--- One extreme move, reverted the next day
+
+	points[100].Close *= 1.5
+
+	summary := func(ap *AnalysisParams) (*DataProductAnalysisResponse, []*BarResult) {
+		list := createBarResults(points, ap)
+		res  := &DataProductAnalysisResponse{}
+		calcSummary(res, points, list, ap)
+		return res, list
+	}
+
+	raw, rawList := summary(defaultParams())
+
+	ap := defaultParams()
+	ap.Winsorize = 1
+	clipped, list := summary(ap)
+
+	if clipped.HistVol >= raw.HistVol / 2 {
+		t.Errorf("Winsorization must reduce the outlier's influence on the volatility. Got %v, raw %v", clipped.HistVol, raw.HistVol)
+	}
+
+	//--- Up and down averages are computed on the raw changes
+
+	if clipped.AvgUpPerc != raw.AvgUpPerc || clipped.AvgDownPerc != raw.AvgDownPerc {
+		t.Errorf("Up and down averages must not be winsorized. Got %v/%v, raw %v/%v",
+			clipped.AvgUpPerc, clipped.AvgDownPerc, raw.AvgUpPerc, raw.AvgDownPerc)
+	}
+
+	//--- Reported changes stay raw
+
+	for i := range list {
+		if list[i].BarChangePerc != rawList[i].BarChangePerc {
+			t.Fatalf("The bar change must stay raw at %d. Got %v, expected %v", i, list[i].BarChangePerc, rawList[i].BarChangePerc)
+		}
+	}
+}
+
+//=============================================================================
@@ -0,0 +1,911 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+)
+
+//=============================================================================
+
+const (
+	DefaultTimeframe = 1440
+	WeeklyTimeframe  = 10080
+
+	DefaultSqnLen = 100
+	DefaultAtrLen = 20
+	DefaultRsiLen = 14
+	DefaultMaLen  = 50
+
+	DefaultMacdFast   = 12
+	DefaultMacdSlow   = 26
+	DefaultMacdSignal = 9
+
+	DefaultBollLen = 20
+	DefaultBollK   = 2.0
+
+	DefaultKeltLen = 20
+	DefaultKeltK   = 2.0
+
+	DefaultDonchLen = 20
+
+	DefaultAdxLen  = 14
+	DefaultVwapLen = 20
+	DefaultMfiLen  = 14
+	DefaultCmfLen  = 20
+
+	DefaultRocLen  = 12
+	DefaultCciLen  = 20
+	DefaultTrixLen = 15
+	DefaultRegLen  = 20
+
+	DefaultStochLen = 14
+	StochDLen       = 3
+	DefaultWillrLen = 14
+
+	DefaultSarStart = 0.02
+	DefaultSarStep  = 0.02
+	DefaultSarMax   = 0.2
+
+	DefaultIchiTenkan = 9
+	DefaultIchiKijun  = 26
+	DefaultIchiSenkou = 52
+
+	DefaultMinRegimeLen = 3
+	DefaultBetaLen      = 60
+	DefaultAutoCorrLags = 5
+	DefaultSharpeLen    = 60
+	DefaultSqnSlopeLen  = 10
+
+	TradingDaysPerYear = 252
+	WeeksPerYear       = 52
+)
+
+const (
+	AtrMethodSimple = "simple"
+	AtrMethodWilder = "wilder"
+)
+
+const (
+	SqnMethodSimple = "simple"
+	SqnMethodEwma   = "ewma"
+
+	DefaultSqnDecay = 0.97
+)
+
+const (
+	StdDevModePopulation = "population"
+	StdDevModeSample     = "sample"
+)
+
+const (
+	ReturnModeSimple = "simple"
+	ReturnModeLog    = "log"
+)
+
+const (
+	PriceFieldClose    = "close"
+	PriceFieldOpen     = "open"
+	PriceFieldTypical  = "typical"
+	PriceFieldMedian   = "median"
+	PriceFieldWeighted = "weighted"
+)
+
+const (
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+)
+
+const (
+	ZeroBaselineZero = "zero"
+	ZeroBaselineSkip = "skip"
+	ZeroBaselineFlag = "flag"
+)
+
+//--- Timeframes (in minutes) the analysis can run on. Weekly bars are built from the daily ones
+
+var AnalysisTimeframes = []int{ 5, 15, 30, 60, 120, 240, 1440, 10080 }
+
+//=============================================================================
+
+type DataProductAnalysisSpec struct {
+	QuerySpec
+	SqnLen       string
+	SqnMethod    string
+	SqnDecay     string
+	StdDevMode   string
+	AtrLen       string
+	AtrMethod    string
+	RsiLen       string
+	MaLen        string
+	MacdFast     string
+	MacdSlow     string
+	MacdSignal   string
+	BollLen      string
+	BollK        string
+	KeltLen      string
+	KeltK        string
+	DonchLen     string
+	AdxLen       string
+	VwapLen      string
+	MfiLen       string
+	CmfLen       string
+	RocLen       string
+	CciLen       string
+	StochLen     string
+	WillrLen     string
+	TrixLen      string
+	RegLen       string
+	SarStart     string
+	SarStep      string
+	SarMax       string
+	IchiTenkan   string
+	IchiKijun    string
+	IchiSenkou   string
+	DetectGaps   string
+	Calendar     string
+	RiskFreeRate string
+	ReturnMode   string
+	PriceField   string
+	MinRegimeLen string
+	BetaLen      string
+	OutlierSigma string
+	OutlierMode  string
+	AutoCorrLags string
+	SharpeLen    string
+	SqnSlopeLen  string
+
+	//--- Percentage of returns clipped in each tail before the statistics (0 = none).
+	//--- The bar changes are still reported raw
+	Winsorize string
+
+	//--- Periods used to annualize the statistics, derived from the timeframe when empty
+	PeriodsPerYear string
+
+	//--- Also returns the warm-up bars, with indicators computed on the available window
+	IncludePartial string
+
+	//--- Fetches the warm-up bars before 'from', so that the whole requested range is
+	//--- returned. Only the bars in the range are returned
+	ExtendHistory string
+
+	//--- Leaves the bars with no range (locked markets) out of the ATR average
+	AtrSkipLocked string
+
+	//--- Only returns the last Tail bar results, before the Offset most recent ones.
+	//--- Indicators still use the whole history
+	Tail   string
+	Offset string
+
+	//--- Order of the returned bar results. Indicators are always computed oldest first
+	Order string
+
+	//--- Also analyzes the preceding window of the same length, reporting the deltas
+	ComparePrior string
+
+	//--- Replaces NaN and Inf values in the bar results (0 by default)
+	NonFiniteValue string
+
+	//--- Sorts the data points and drops the duplicated times, instead of failing
+	FixOrder string
+
+	//--- Fills the gaps (as detected with 'detectGaps') with flat bars at the previous close
+	GapFill string
+
+	//--- Regular trading hours (hh:mm, in the spec's timezone) of intraday bars: the
+	//--- other bars are dropped. No filter when empty
+	SessionStart string
+	SessionEnd   string
+
+	//--- SQN distance past a direction threshold required to change direction (0 = none)
+	DirectionMargin string
+
+	//--- Caps the reported bar change at ± this percentage (0 = none), to keep extreme
+	//--- moves from flattening the charts. The raw change is reported too
+	ClampPercChange string
+
+	//--- How bars whose previous price is zero are handled: 'zero' (the default) reports
+	//--- no change, 'skip' drops them and 'flag' also marks them as zero baseline
+	ZeroBaseline string
+
+	//--- Splits and dividends used to back-adjust prices
+	Actions []CorporateAction
+
+	//--- EMA window applied to the returns fed to SQN (0 = none). Only the SQN input
+	//--- is smoothed: the reported bar change stays raw
+	ReturnSmoothing string
+
+	//--- How days without a benchmark bar are handled by the relative strength ('skip' by default)
+	RelStrengthFill string
+
+	//--- Comma separated indicators to compute (all when empty). The fields of the
+	//--- other ones are left at zero
+	Indicators string
+
+	//--- Product used to compute the rolling beta and the relative strength
+	Benchmark *DataProductAnalysisSpec
+
+	//--- Where data points are read from. The datastore is used when nil
+	Source DataSource `json:"-"`
+
+	//--- Called on the bar results once all the indicators are computed, before
+	//--- values are rounded. An error aborts the analysis
+	PostProcess func(list []*BarResult) error `json:"-"`
+
+	//--- Notified while the analysis goes on. Can be nil
+	Progress ProgressFunc `json:"-"`
+
+	//--- Result of a previous analysis whose bars can be reused when extending the range
+	Previous *DataProductAnalysisResponse `json:"-"`
+}
+
+//=============================================================================
+
+type AnalysisParams struct {
+	Timeframe       int
+	SqnLen          int
+	SqnMethod       string
+	SqnDecay        float64
+	StdDevMode      string
+	AtrLen          int
+	AtrMethod       string
+	RsiLen          int
+	MaLen           int
+	MacdFast        int
+	MacdSlow        int
+	MacdSignal      int
+	BollLen         int
+	BollK           float64
+	KeltLen         int
+	KeltK           float64
+	DonchLen        int
+	AdxLen          int
+	VwapLen         int
+	MfiLen          int
+	CmfLen          int
+	RocLen          int
+	CciLen          int
+	StochLen        int
+	WillrLen        int
+	TrixLen         int
+	RegLen          int
+	SarStart        float64
+	SarStep         float64
+	SarMax          float64
+	IchiTenkan      int
+	IchiKijun       int
+	IchiSenkou      int
+	DetectGaps      bool
+	Calendar        *TradingCalendar
+	RiskFreeRate    float64
+	ReturnMode      string
+	PriceField      string
+	MinRegimeLen    int
+	BetaLen         int
+	OutlierSigma    float64
+	OutlierMode     string
+	AutoCorrLags    int
+	Winsorize       float64
+	SharpeLen       int
+	SqnSlopeLen     int
+	IncludePartial  bool
+	AtrSkipLocked   bool
+	PeriodsPerYear  float64
+	Tail            int
+	Offset          int
+	Order           string
+	ComparePrior    bool
+	NonFiniteValue  float64
+	RelStrengthFill string
+	ReturnSmoothing int
+	FixOrder        bool
+	Indicators      IndicatorSet
+	ZeroBaseline    string
+	DirectionMargin float64
+	GapFill         bool
+	ExtendHistory   bool
+	ClampPercChange float64
+	SessionStart    int
+	SessionEnd      int
+	Progress        ProgressFunc
+
+	//--- Start of the requested range when the history is extended before it: the
+	//--- summary only covers the bars from there. Set by AnalyzeProduct
+	summaryFrom *time.Time
+}
+
+//=============================================================================
+
+func NewAnalysisParams(spec *DataProductAnalysisSpec) (*AnalysisParams, error) {
+	verr := &ValidationError{}
+
+	timeframe, err := parseAnalysisTimeframe(spec.Timeframe)
+	verr.add("timeframe", spec.Timeframe, err)
+
+	sqnLen, err := parseLength(spec.SqnLen, DefaultSqnLen, 10, 500)
+	verr.add("sqnLen", spec.SqnLen, err)
+
+	sqnMethod, err := parseSqnMethod(spec.SqnMethod)
+	verr.add("sqnMethod", spec.SqnMethod, err)
+
+	sqnDecay, err := parseFactor(spec.SqnDecay, DefaultSqnDecay, 0.5, 0.999)
+	verr.add("sqnDecay", spec.SqnDecay, err)
+
+	stdDevMode, err := parseStdDevMode(spec.StdDevMode)
+	verr.add("stdDevMode", spec.StdDevMode, err)
+
+	atrLen, err := parseLength(spec.AtrLen, DefaultAtrLen, 5, 50)
+	verr.add("atrLen", spec.AtrLen, err)
+
+	atrMethod, err := parseAtrMethod(spec.AtrMethod)
+	verr.add("atrMethod", spec.AtrMethod, err)
+
+	rsiLen, err := parseLength(spec.RsiLen, DefaultRsiLen, 2, 100)
+	verr.add("rsiLen", spec.RsiLen, err)
+
+	maLen, err := parseLength(spec.MaLen, DefaultMaLen, 2, 500)
+	verr.add("maLen", spec.MaLen, err)
+
+	macdFast, err := parseLength(spec.MacdFast, DefaultMacdFast, 2, 100)
+	verr.add("macdFast", spec.MacdFast, err)
+
+	macdSlow, err := parseLength(spec.MacdSlow, DefaultMacdSlow, 2, 200)
+	verr.add("macdSlow", spec.MacdSlow, err)
+
+	if macdFast >= macdSlow && macdSlow > 0 {
+		verr.add("macdFast", strconv.Itoa(macdFast), errors.New("must be lower than 'macdSlow'"))
+	}
+
+	macdSignal, err := parseLength(spec.MacdSignal, DefaultMacdSignal, 2, 100)
+	verr.add("macdSignal", spec.MacdSignal, err)
+
+	bollLen, err := parseLength(spec.BollLen, DefaultBollLen, 2, 200)
+	verr.add("bollLen", spec.BollLen, err)
+
+	bollK, err := parseFactor(spec.BollK, DefaultBollK, 0.1, 10)
+	verr.add("bollK", spec.BollK, err)
+
+	keltLen, err := parseLength(spec.KeltLen, DefaultKeltLen, 2, 200)
+	verr.add("keltLen", spec.KeltLen, err)
+
+	keltK, err := parseFactor(spec.KeltK, DefaultKeltK, 0.1, 10)
+	verr.add("keltK", spec.KeltK, err)
+
+	donchLen, err := parseLength(spec.DonchLen, DefaultDonchLen, 2, 500)
+	verr.add("donchLen", spec.DonchLen, err)
+
+	adxLen, err := parseLength(spec.AdxLen, DefaultAdxLen, 2, 100)
+	verr.add("adxLen", spec.AdxLen, err)
+
+	vwapLen, err := parseLength(spec.VwapLen, DefaultVwapLen, 2, 500)
+	verr.add("vwapLen", spec.VwapLen, err)
+
+	mfiLen, err := parseLength(spec.MfiLen, DefaultMfiLen, 2, 100)
+	verr.add("mfiLen", spec.MfiLen, err)
+
+	cmfLen, err := parseLength(spec.CmfLen, DefaultCmfLen, 2, 200)
+	verr.add("cmfLen", spec.CmfLen, err)
+
+	rocLen, err := parseLength(spec.RocLen, DefaultRocLen, 1, 200)
+	verr.add("rocLen", spec.RocLen, err)
+
+	cciLen, err := parseLength(spec.CciLen, DefaultCciLen, 2, 200)
+	verr.add("cciLen", spec.CciLen, err)
+
+	stochLen, err := parseLength(spec.StochLen, DefaultStochLen, 2, 200)
+	verr.add("stochLen", spec.StochLen, err)
+
+	willrLen, err := parseLength(spec.WillrLen, DefaultWillrLen, 2, 200)
+	verr.add("willrLen", spec.WillrLen, err)
+
+	trixLen, err := parseLength(spec.TrixLen, DefaultTrixLen, 2, 100)
+	verr.add("trixLen", spec.TrixLen, err)
+
+	regLen, err := parseLength(spec.RegLen, DefaultRegLen, 2, 500)
+	verr.add("regLen", spec.RegLen, err)
+
+	sarStart, err := parseFactor(spec.SarStart, DefaultSarStart, 0.001, 1)
+	verr.add("sarStart", spec.SarStart, err)
+
+	sarStep, err := parseFactor(spec.SarStep, DefaultSarStep, 0.001, 1)
+	verr.add("sarStep", spec.SarStep, err)
+
+	sarMax, err := parseFactor(spec.SarMax, DefaultSarMax, 0.001, 1)
+	verr.add("sarMax", spec.SarMax, err)
+
+	if sarStart > sarMax && sarMax > 0 {
+		verr.add("sarStart", spec.SarStart, errors.New("must not be greater than 'sarMax'"))
+	}
+
+	ichiTenkan, err := parseLength(spec.IchiTenkan, DefaultIchiTenkan, 2, 100)
+	verr.add("ichiTenkan", spec.IchiTenkan, err)
+
+	ichiKijun, err := parseLength(spec.IchiKijun, DefaultIchiKijun, 2, 200)
+	verr.add("ichiKijun", spec.IchiKijun, err)
+
+	ichiSenkou, err := parseLength(spec.IchiSenkou, DefaultIchiSenkou, 2, 400)
+	verr.add("ichiSenkou", spec.IchiSenkou, err)
+
+	if ichiTenkan >= ichiKijun && ichiKijun > 0 {
+		verr.add("ichiTenkan", strconv.Itoa(ichiTenkan), errors.New("must be lower than 'ichiKijun'"))
+	}
+
+	detectGaps, err := parseFlag(spec.DetectGaps)
+	verr.add("detectGaps", spec.DetectGaps, err)
+
+	calendar, err := NewTradingCalendar(spec.Calendar)
+	verr.add("calendar", spec.Calendar, err)
+
+	riskFreeRate, err := parseFactor(spec.RiskFreeRate, 0, 0, 20)
+	verr.add("riskFreeRate", spec.RiskFreeRate, err)
+
+	periodsPerYear, err := parseFactor(spec.PeriodsPerYear, 0, 0, 1000000)
+	verr.add("periodsPerYear", spec.PeriodsPerYear, err)
+
+	returnMode, err := parseReturnMode(spec.ReturnMode)
+	verr.add("returnMode", spec.ReturnMode, err)
+
+	priceField, err := parsePriceField(spec.PriceField)
+	verr.add("priceField", spec.PriceField, err)
+
+	minRegimeLen, err := parseLength(spec.MinRegimeLen, DefaultMinRegimeLen, 1, 100)
+	verr.add("minRegimeLen", spec.MinRegimeLen, err)
+
+	betaLen, err := parseLength(spec.BetaLen, DefaultBetaLen, 10, 500)
+	verr.add("betaLen", spec.BetaLen, err)
+
+	outlierSigma, err := parseFactor(spec.OutlierSigma, 0, 0, 100)
+	verr.add("outlierSigma", spec.OutlierSigma, err)
+
+	outlierMode, err := parseOutlierMode(spec.OutlierMode)
+	verr.add("outlierMode", spec.OutlierMode, err)
+
+	autoCorrLags, err := parseLength(spec.AutoCorrLags, DefaultAutoCorrLags, 0, 100)
+	verr.add("autoCorrLags", spec.AutoCorrLags, err)
+
+	sharpeLen, err := parseLength(spec.SharpeLen, DefaultSharpeLen, 10, 500)
+	verr.add("sharpeLen", spec.SharpeLen, err)
+
+	sqnSlopeLen, err := parseLength(spec.SqnSlopeLen, DefaultSqnSlopeLen, 2, 100)
+	verr.add("sqnSlopeLen", spec.SqnSlopeLen, err)
+
+	winsorize, err := parseFactor(spec.Winsorize, 0, 0, 25)
+	verr.add("winsorize", spec.Winsorize, err)
+
+	includePartial, err := parseFlag(spec.IncludePartial)
+	verr.add("includePartial", spec.IncludePartial, err)
+
+	atrSkipLocked, err := parseFlag(spec.AtrSkipLocked)
+	verr.add("atrSkipLocked", spec.AtrSkipLocked, err)
+
+	tail, err := parseLength(spec.Tail, 0, 0, HardLimit)
+	verr.add("tail", spec.Tail, err)
+
+	offset, err := parseLength(spec.Offset, 0, 0, HardLimit)
+	verr.add("offset", spec.Offset, err)
+
+	order, err := parseOrder(spec.Order)
+	verr.add("order", spec.Order, err)
+
+	comparePrior, err := parseFlag(spec.ComparePrior)
+	verr.add("comparePrior", spec.ComparePrior, err)
+
+	nonFiniteValue, err := parseFactor(spec.NonFiniteValue, 0, -1000000, 1000000)
+	verr.add("nonFiniteValue", spec.NonFiniteValue, err)
+
+	relStrengthFill, err := parseRelStrengthFill(spec.RelStrengthFill)
+	verr.add("relStrengthFill", spec.RelStrengthFill, err)
+
+	returnSmoothing, err := parseLength(spec.ReturnSmoothing, 0, 0, 100)
+	verr.add("returnSmoothing", spec.ReturnSmoothing, err)
+
+	fixOrder, err := parseFlag(spec.FixOrder)
+	verr.add("fixOrder", spec.FixOrder, err)
+
+	indicators, err := parseIndicators(spec.Indicators)
+	verr.add("indicators", spec.Indicators, err)
+
+	zeroBaseline, err := parseZeroBaseline(spec.ZeroBaseline)
+	verr.add("zeroBaseline", spec.ZeroBaseline, err)
+
+	directionMargin, err := parseFactor(spec.DirectionMargin, 0, 0, 2)
+	verr.add("directionMargin", spec.DirectionMargin, err)
+
+	clampPercChange, err := parseFactor(spec.ClampPercChange, 0, 0, 1000)
+	verr.add("clampPercChange", spec.ClampPercChange, err)
+
+	gapFill, err := parseFlag(spec.GapFill)
+	verr.add("gapFill", spec.GapFill, err)
+
+	extendHistory, err := parseFlag(spec.ExtendHistory)
+	verr.add("extendHistory", spec.ExtendHistory, err)
+
+	sessionStart, err := parseSessionTime(spec.SessionStart)
+	verr.add("sessionStart", spec.SessionStart, err)
+
+	sessionEnd, err := parseSessionTime(spec.SessionEnd)
+	verr.add("sessionEnd", spec.SessionEnd, err)
+
+	if (spec.SessionStart == "") != (spec.SessionEnd == "") {
+		verr.add("sessionEnd", spec.SessionEnd, errors.New("session start and end must be given together"))
+	} else if spec.SessionStart != "" && sessionStart == sessionEnd {
+		verr.add("sessionEnd", spec.SessionEnd, errors.New("must differ from the session start"))
+	} else if spec.SessionStart != "" && timeframe >= DefaultTimeframe {
+		verr.add("sessionStart", spec.SessionStart, errors.New("only allowed with intraday timeframes"))
+	}
+
+	if verr.hasErrors() {
+		return nil, verr
+	}
+
+	return &AnalysisParams{
+		Timeframe : timeframe,
+		SqnLen    : sqnLen,
+		SqnMethod : sqnMethod,
+		SqnDecay  : sqnDecay,
+		StdDevMode: stdDevMode,
+		AtrLen    : atrLen,
+		AtrMethod : atrMethod,
+		RsiLen    : rsiLen,
+		MaLen     : maLen,
+		MacdFast  : macdFast,
+		MacdSlow  : macdSlow,
+		MacdSignal: macdSignal,
+		BollLen   : bollLen,
+		BollK     : bollK,
+		KeltLen   : keltLen,
+		KeltK     : keltK,
+		DonchLen  : donchLen,
+		AdxLen    : adxLen,
+		VwapLen   : vwapLen,
+		MfiLen    : mfiLen,
+		CmfLen    : cmfLen,
+		RocLen    : rocLen,
+		CciLen    : cciLen,
+		StochLen  : stochLen,
+		WillrLen  : willrLen,
+		TrixLen   : trixLen,
+		RegLen    : regLen,
+		SarStart  : sarStart,
+		SarStep   : sarStep,
+		SarMax    : sarMax,
+		IchiTenkan: ichiTenkan,
+		IchiKijun : ichiKijun,
+		IchiSenkou: ichiSenkou,
+		DetectGaps: detectGaps,
+		Calendar  : calendar,
+
+		//--- The rate is given as an annual percentage
+		RiskFreeRate: riskFreeRate / 100,
+		ReturnMode  : returnMode,
+		PriceField  : priceField,
+		MinRegimeLen: minRegimeLen,
+		BetaLen     : betaLen,
+		OutlierSigma: outlierSigma,
+		OutlierMode : outlierMode,
+		AutoCorrLags: autoCorrLags,
+		Winsorize   : winsorize,
+		SharpeLen   : sharpeLen,
+		SqnSlopeLen : sqnSlopeLen,
+
+		IncludePartial : includePartial,
+		AtrSkipLocked  : atrSkipLocked,
+		PeriodsPerYear : periodsPerYear,
+		Tail           : tail,
+		Offset         : offset,
+		Order          : order,
+		ComparePrior   : comparePrior,
+		NonFiniteValue : nonFiniteValue,
+		RelStrengthFill: relStrengthFill,
+		ReturnSmoothing: returnSmoothing,
+		FixOrder       : fixOrder,
+		Indicators     : indicators,
+		ZeroBaseline   : zeroBaseline,
+		DirectionMargin: directionMargin,
+		GapFill        : gapFill,
+		ExtendHistory  : extendHistory,
+		ClampPercChange: clampPercChange,
+		SessionStart   : sessionStart,
+		SessionEnd     : sessionEnd,
+		Progress       : spec.Progress,
+	}, nil
+}
+
+//=============================================================================
+//--- Timeframe of the fetched data points. Weekly bars are aggregated afterwards
+
+func (ap *AnalysisParams) fetchTimeframe() int {
+	if ap.Timeframe == WeeklyTimeframe {
+		return DefaultTimeframe
+	}
+
+	return ap.Timeframe
+}
+
+//=============================================================================
+//--- Params built by hand, with no selection, compute all the indicators
+
+func (ap *AnalysisParams) computes(flag IndicatorSet) bool {
+	return ap.Indicators == 0 || ap.Indicators.Has(flag)
+}
+
+//=============================================================================
+//--- Number of bars required before all the selected indicators are available. The
+//--- Ichimoku cloud is left out: with its forward shift it would need far more history.
+//--- The volatility regime is computed over the SQN window, the SQN slope over the
+//--- last SqnSlopeLen SQN values. ADXR needs the ADX of AdxLen bars before and SAR is
+//--- seeded on the first bar
+
+func (ap *AnalysisParams) warmupBars() int {
+	windows := []struct {
+		flag IndicatorSet
+		bars int
+	}{
+		{ IndicatorSqn,   ap.SqnLen + ap.SqnSlopeLen -1  },
+		{ IndicatorAtr,   ap.SqnLen                      },
+		{ IndicatorRsi,   ap.RsiLen                      },
+		{ IndicatorMa,    ap.MaLen                       },
+		{ IndicatorMacd,  ap.MacdSlow + ap.MacdSignal -1 },
+		{ IndicatorBoll,  ap.BollLen                     },
+		{ IndicatorKelt,  ap.KeltLen                     },
+		{ IndicatorDonch, ap.DonchLen                    },
+		{ IndicatorAdx,   3*ap.AdxLen -1                 },
+		{ IndicatorVwap,  ap.VwapLen                     },
+		{ IndicatorMfi,   ap.MfiLen                      },
+		{ IndicatorCmf,   ap.CmfLen                      },
+		{ IndicatorRoc,   ap.RocLen +1                   },
+		{ IndicatorCci,   ap.CciLen                      },
+		{ IndicatorStoch, ap.StochLen + StochDLen -1     },
+		{ IndicatorWillr, ap.WillrLen                    },
+		{ IndicatorTrix,  3*ap.TrixLen -1                },
+		{ IndicatorReg,   ap.RegLen                      },
+		{ IndicatorSar,   2                              },
+		{ IndicatorIchi,  ap.IchiKijun                   },
+	}
+
+	//--- The rolling Sharpe is always computed
+
+	warmup := max(1, ap.SharpeLen)
+
+	for _, w := range windows {
+		if ap.computes(w.flag) {
+			warmup = max(warmup, w.bars)
+		}
+	}
+
+	return warmup
+}
+
+//=============================================================================
+//--- Index of the first bar returned by the analysis: warm-up bars are skipped
+//--- unless partial results have been requested
+
+func (ap *AnalysisParams) firstBar() int {
+	if ap.IncludePartial {
+		return 0
+	}
+
+	return ap.warmupBars() -1
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func parseAnalysisTimeframe(value string) (int, error) {
+	if value == "" {
+		return DefaultTimeframe, nil
+	}
+
+	tf, err := strconv.Atoi(value)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if !slices.Contains(AnalysisTimeframes, tf) {
+		return 0, errors.New("allowed values are 5, 15, 30, 60, 120, 240, 1440 and 10080")
+	}
+
+	return tf, nil
+}
+
+//=============================================================================
+
+func parseLength(value string, defValue, minValue, maxValue int) (int, error) {
+	if value == "" {
+		return defValue, nil
+	}
+
+	length, err := strconv.Atoi(value)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if length < minValue || length > maxValue {
+		return 0, errors.New("allowed range is ["+ strconv.Itoa(minValue) +".."+ strconv.Itoa(maxValue) +"]")
+	}
+
+	return length, nil
+}
+
+//=============================================================================
+
+func parseFactor(value string, defValue, minValue, maxValue float64) (float64, error) {
+	if value == "" {
+		return defValue, nil
+	}
+
+	factor, err := strconv.ParseFloat(value, 64)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if math.IsNaN(factor) || math.IsInf(factor, 0) {
+		return 0, errors.New("must be a finite number")
+	}
+
+	if factor < minValue || factor > maxValue {
+		return 0, errors.New("allowed range is ["+ formatFloat(minValue) +".."+ formatFloat(maxValue) +"]")
+	}
+
+	return factor, nil
+}
+
+//=============================================================================
+
+func parseFlag(value string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+
+	return strconv.ParseBool(value)
+}
+
+//=============================================================================
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+//=============================================================================
+
+func parseAtrMethod(value string) (string, error) {
+	if value == "" {
+		return AtrMethodSimple, nil
+	}
+
+	if value != AtrMethodSimple && value != AtrMethodWilder {
+		return "", errors.New("allowed values are '"+ AtrMethodSimple +"' and '"+ AtrMethodWilder +"'")
+	}
+
+	return value, nil
+}
+
+//=============================================================================
+
+func parseReturnMode(value string) (string, error) {
+	if value == "" {
+		return ReturnModeSimple, nil
+	}
+
+	if value != ReturnModeSimple && value != ReturnModeLog {
+		return "", errors.New("allowed values are '"+ ReturnModeSimple +"' and '"+ ReturnModeLog +"'")
+	}
+
+	return value, nil
+}
+
+//=============================================================================
+
+func parseOutlierMode(value string) (string, error) {
+	if value == "" {
+		return OutlierModeClamp, nil
+	}
+
+	if value != OutlierModeClamp && value != OutlierModeDrop {
+		return "", errors.New("allowed values are '"+ OutlierModeClamp +"' and '"+ OutlierModeDrop +"'")
+	}
+
+	return value, nil
+}
+
+//=============================================================================
+
+func parseSqnMethod(value string) (string, error) {
+	if value == "" {
+		return SqnMethodSimple, nil
+	}
+
+	if value != SqnMethodSimple && value != SqnMethodEwma {
+		return "", errors.New("allowed values are '"+ SqnMethodSimple +"' and '"+ SqnMethodEwma +"'")
+	}
+
+	return value, nil
+}
+
+//=============================================================================
+
+func parseStdDevMode(value string) (string, error) {
+	if value == "" {
+		return StdDevModePopulation, nil
+	}
+
+	if value != StdDevModePopulation && value != StdDevModeSample {
+		return "", errors.New("allowed values are '"+ StdDevModePopulation +"' and '"+ StdDevModeSample +"'")
+	}
+
+	return value, nil
+}
+
+//=============================================================================
+
+func parsePriceField(value string) (string, error) {
+	switch value {
+	case "":
+		return PriceFieldClose, nil
+	case PriceFieldClose, PriceFieldOpen, PriceFieldTypical, PriceFieldMedian, PriceFieldWeighted:
+		return value, nil
+	}
+
+	return "", errors.New("allowed values are '"+ PriceFieldClose +"', '"+ PriceFieldOpen +"', '"+ PriceFieldTypical +"', '"+ PriceFieldMedian +"' and '"+ PriceFieldWeighted +"'")
+}
+
+//=============================================================================
+
+func parseOrder(value string) (string, error) {
+	if value == "" {
+		return OrderAsc, nil
+	}
+
+	if value != OrderAsc && value != OrderDesc {
+		return "", errors.New("allowed values are '"+ OrderAsc +"' and '"+ OrderDesc +"'")
+	}
+
+	return value, nil
+}
+
+//=============================================================================
+
+func parseRelStrengthFill(value string) (string, error) {
+	if value == "" {
+		return RelStrengthFillSkip, nil
+	}
+
+	if value != RelStrengthFillSkip && value != RelStrengthFillForward {
+		return "", errors.New("allowed values are '"+ RelStrengthFillSkip +"' and '"+ RelStrengthFillForward +"'")
+	}
+
+	return value, nil
+}
+
+//=============================================================================
+
+func parseZeroBaseline(value string) (string, error) {
+	switch value {
+	case "":
+		return ZeroBaselineZero, nil
+	case ZeroBaselineZero, ZeroBaselineSkip, ZeroBaselineFlag:
+		return value, nil
+	}
+
+	return "", errors.New("allowed values are '"+ ZeroBaselineZero +"', '"+ ZeroBaselineSkip +"' and '"+ ZeroBaselineFlag +"'")
+}
+
+//=============================================================================
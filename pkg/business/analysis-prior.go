@@ -0,0 +1,120 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"context"
+	"time"
+
+	"github.com/algotiqa/data-collector/pkg/ds"
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+
+type PeriodStats struct {
+	Return      float64 `json:"return"`
+	HistVol     float64 `json:"histVol"`
+	MaxDrawdown float64 `json:"maxDrawdown"`
+}
+
+//=============================================================================
+//--- Stats of the analyzed window and of the preceding one with the same length.
+//--- The delta is current minus prior
+
+type PeriodDeltas struct {
+	PriorFrom   types.Date  `json:"priorFrom"`
+	PriorTo     types.Date  `json:"priorTo"`
+	Current     PeriodStats `json:"current"`
+	Prior       PeriodStats `json:"prior"`
+	Delta       PeriodStats `json:"delta"`
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- The prior window ends just before the requested one starts. Its data points are
+//--- fetched, adjusted and reduced like the analyzed ones. The extended history, if
+//--- any, only feeds the indicators and is left out of the current stats
+
+func addPeriodDeltas(ctx context.Context, res *DataProductAnalysisResponse, spec *DataProductAnalysisSpec, params *QueryParams,
+					from *time.Time, dataPoints []*ds.DataPoint, ap *AnalysisParams) error {
+	if from == nil || params.To == nil {
+		res.Notes = append(res.Notes, "The prior period comparison needs a closed range")
+		return nil
+	}
+
+	priorTo   := from.Add(-time.Second)
+	priorFrom := from.Add(-params.To.Sub(*from))
+
+	prior := *spec
+	prior.From     = priorFrom.In(params.TargetLoc).Format(time.DateTime)
+	prior.To       = priorTo.In(params.TargetLoc).Format(time.DateTime)
+	prior.DaysBack = ""
+
+	priorPoints, _, err := fetchSpecDataPoints(ctx, &prior)
+	if err != nil {
+		return err
+	}
+
+	priorPoints, _ = filterOutliers(priorPoints, ap.OutlierSigma, ap.OutlierMode)
+	priorPoints, _ = reduceDataPoints(priorPoints, params.Reduction)
+
+	current := calcPeriodStats(dataPointsFrom(dataPoints, *from), ap)
+	before  := calcPeriodStats(priorPoints, ap)
+
+	res.PeriodDeltas = &PeriodDeltas{
+		PriorFrom  : types.ToDate(&priorFrom),
+		PriorTo    : types.ToDate(&priorTo),
+		Current    : current,
+		Prior      : before,
+		Delta      : PeriodStats{
+			Return     : current.Return      - before.Return,
+			HistVol    : current.HistVol     - before.HistVol,
+			MaxDrawdown: current.MaxDrawdown - before.MaxDrawdown,
+		},
+	}
+
+	if len(priorPoints) < 2 {
+		res.Notes = append(res.Notes, "The prior period has not enough data to be compared")
+	}
+
+	return nil
+}
+
+//=============================================================================
+
+func calcPeriodStats(dataPoints []*ds.DataPoint, ap *AnalysisParams) PeriodStats {
+	var stats PeriodStats
+
+	if len(dataPoints) < 2 {
+		return stats
+	}
+
+	returns := make([]float64, len(dataPoints) -1)
+
+	for i := 1; i < len(dataPoints); i++ {
+		returns[i-1] = calcReturn(priceOf(dataPoints[i], ap.PriceField), priceOf(dataPoints[i-1], ap.PriceField), ap.ReturnMode)
+	}
+
+	stats.Return  = calcReturn(priceOf(dataPoints[len(dataPoints)-1], ap.PriceField), priceOf(dataPoints[0], ap.PriceField), ap.ReturnMode)
+	stats.HistVol = calcHistVol(returns, annualizationPeriods(dataPoints, ap))
+
+	if dd := calcMaxDrawdown(dataPoints); dd != nil {
+		stats.MaxDrawdown = dd.Value
+	}
+
+	return stats
+}
+
+//=============================================================================
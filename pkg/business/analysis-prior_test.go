@@ -0,0 +1,97 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+
+func TestPeriodDeltas(t *testing.T) {
+	//--- Flat in the first half of 2024, up in the second one
+
+	points := buildDataPoints(buildCloses(366, 100, func(i int) float64 {
+		if i < 182 {
+			return 0
+		}
+		return float64(i%3) * 0.5
+	}))
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-07-01 00:00:00",
+			To      : "2024-12-30 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		ComparePrior: "true",
+		Source      : NewSliceDataSource(points),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	pd := res.PeriodDeltas
+	if pd == nil {
+		t.Fatalf("Period deltas must be computed")
+	}
+
+	if pd.PriorFrom != types.NewDate(2024, 1, 1) || pd.PriorTo != types.NewDate(2024, 6, 30) {
+		t.Errorf("Bad prior range. Got %v..%v", pd.PriorFrom, pd.PriorTo)
+	}
+
+	if pd.Prior.Return != 0 || pd.Prior.HistVol != 0 || pd.Prior.MaxDrawdown != 0 {
+		t.Errorf("Prior period must be flat. Got %+v", pd.Prior)
+	}
+
+	if pd.Current.Return <= 0 || pd.Delta.Return != pd.Current.Return {
+		t.Errorf("Return delta must be positive. Got %+v", pd.Delta)
+	}
+
+	if pd.Delta.HistVol <= 0 {
+		t.Errorf("Volatility delta must be positive. Got %+v", pd.Delta)
+	}
+
+	//--- The extended history must not move the compared windows
+
+	spec.ExtendHistory = "true"
+
+	ext, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if ext.PeriodDeltas == nil || *ext.PeriodDeltas != *pd {
+		t.Errorf("Period deltas must not depend on the extended history. Got %+v, expected %+v", ext.PeriodDeltas, pd)
+	}
+
+	spec.ExtendHistory = ""
+
+	//--- Only on request
+
+	spec.ComparePrior = ""
+
+	if res, err = AnalyzeProduct(c, spec); err != nil || res.PeriodDeltas != nil {
+		t.Errorf("Period deltas must not be computed by default. Got %+v (err %v)", res.PeriodDeltas, err)
+	}
+}
+
+//=============================================================================
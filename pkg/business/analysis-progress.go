@@ -0,0 +1,82 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"sync"
+)
+
+//=============================================================================
+
+const (
+	ProgressFetch      = "fetch"
+	ProgressIndicators = "indicators"
+	ProgressSummary    = "summary"
+	ProgressDone       = "done"
+)
+
+//--- Percentages reached at the end of each stage
+
+const (
+	progressFetchEnd      = 10.0
+	progressIndicatorsEnd = 90.0
+	progressSummaryEnd    = 95.0
+)
+
+//=============================================================================
+//--- Receives the analysis stage and the overall percentage, which never decreases.
+//--- Indicator passes can run concurrently, but calls are never concurrent
+
+type ProgressFunc func(stage string, pct float64)
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (p ProgressFunc) report(stage string, pct float64) {
+	if p != nil {
+		p(stage, pct)
+	}
+}
+
+//=============================================================================
+//--- Wraps the passes so that each completed one reports its progress
+
+func (p ProgressFunc) trackPasses(passes []func() error) []func() error {
+	if p == nil {
+		return passes
+	}
+
+	var mutex sync.Mutex
+	done  := 0
+	total := float64(len(passes))
+
+	tracked := make([]func() error, len(passes))
+
+	for i, pass := range passes {
+		tracked[i] = func() error {
+			err := pass()
+
+			mutex.Lock()
+			done++
+			p(ProgressIndicators, progressFetchEnd + (progressIndicatorsEnd - progressFetchEnd) * float64(done) / total)
+			mutex.Unlock()
+
+			return err
+		}
+	}
+
+	return tracked
+}
+
+//=============================================================================
@@ -0,0 +1,151 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/algotiqa/data-collector/pkg/core"
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+
+const (
+	ResamplePeriodWeekly  = "weekly"
+	ResamplePeriodMonthly = "monthly"
+)
+
+//=============================================================================
+//--- Rolls the bar results up into weeks (ISO) or calendar months. Bars aggregate as:
+//---   - time and close: last bar
+//---   - open: first bar. High and low: max and min
+//---   - volume: sum
+//---   - typical and median price: computed on the aggregated bar
+//---   - bar change: raw changes compounded (summed for log returns), then clamped
+//---   - true range, ATR and ATR%: mean over the period
+//---   - locked and synthetic: all bars locked or synthetic. Partial: any bar partial
+//---   - any other indicator: value at the end of the period
+//--- Summary values refer to the original bars and are kept
+
+func (r *DataProductAnalysisResponse) Resample(period string) (*DataProductAnalysisResponse, error) {
+	if period != ResamplePeriodWeekly && period != ResamplePeriodMonthly {
+		return nil, errors.New("allowed periods are '"+ ResamplePeriodWeekly +"' and '"+ ResamplePeriodMonthly +"'")
+	}
+
+	var bars  []*BarResult
+	var group []*BarResult
+
+	for _, br := range ascendingBars(r) {
+		if len(group) > 0 && periodKey(group[0], period) != periodKey(br, period) {
+			bars  = append(bars, resampleBars(group, r.ReturnMode, r.ChangeClamp))
+			group = nil
+		}
+
+		group = append(group, br)
+	}
+
+	if len(group) > 0 {
+		bars = append(bars, resampleBars(group, r.ReturnMode, r.ChangeClamp))
+	}
+
+	res := *r
+	res.Bars       = len(bars)
+	res.Tail       = 0
+	res.Offset     = 0
+	res.BarResults = bars
+	res.Resampled  = period
+	res.Notes      = append(slices.Clone(r.Notes), "Resampled to "+ period +" bars: indicators are the values at the end of each period")
+	res.reused     = 0
+
+	if res.Order == OrderDesc {
+		slices.Reverse(res.BarResults)
+	}
+
+	res.Checksum = calcChecksum(&res)
+
+	return &res, nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func periodKey(br *BarResult, period string) int {
+	if period == ResamplePeriodWeekly {
+		year, week := br.Time.ISOWeek()
+		return year*100 + week
+	}
+
+	return int(types.ToDate(&br.Time)) / 100
+}
+
+//=============================================================================
+//--- Bars are already normalized: percentages are in the 0..100 range
+
+func resampleBars(group []*BarResult, returnMode string, clamp float64) *BarResult {
+	first := group[0]
+	res   := *group[len(group)-1]
+
+	res.Open      = first.Open
+	res.Volume    = 0
+	res.Locked    = true
+	res.Synthetic = true
+
+	growth := 1.0
+	change := 0.0
+	trueRange, percTrueRange, atr, atrPerc := 0.0, 0.0, 0.0, 0.0
+
+	for _, br := range group {
+		res.High      = max(res.High, br.High)
+		res.Low       = min(res.Low,  br.Low)
+		res.Volume   += br.Volume
+		res.Locked    = res.Locked    && br.Locked
+		res.Synthetic = res.Synthetic && br.Synthetic
+		res.Partial   = res.Partial   || br.Partial
+
+		growth *= 1 + br.RawChangePerc / 100
+		change += br.RawChangePerc
+
+		trueRange     += br.TrueRange
+		percTrueRange += br.PercTrueRange
+		atr           += br.Atr
+		atrPerc       += br.AtrPerc
+	}
+
+	n := float64(len(group))
+
+	res.TypicalPrice  = core.Trunc4d((res.High + res.Low + res.Close) / 3)
+	res.MedianPrice   = core.Trunc4d((res.High + res.Low) / 2)
+	res.TrueRange     = trueRange / n
+	res.PercTrueRange = core.Trunc2d(percTrueRange / n)
+	res.Atr           = core.Trunc4d(atr / n)
+	res.AtrPerc       = core.Trunc2d(atrPerc / n)
+
+	if returnMode == ReturnModeLog {
+		res.RawChangePerc = core.Trunc2d(change)
+	} else {
+		res.RawChangePerc = core.Trunc2d((growth - 1) * 100)
+	}
+
+	res.BarChangePerc = res.RawChangePerc
+
+	if clamp > 0 {
+		res.BarChangePerc = min(max(res.RawChangePerc, -clamp), clamp)
+	}
+
+	return &res
+}
+
+//=============================================================================
@@ -0,0 +1,102 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestResampleWeekly(t *testing.T) {
+	//--- April 2024, weekdays only: Monday 1st to Tuesday 30th
+
+	res := &DataProductAnalysisResponse{ Timeframe: DefaultTimeframe, ReturnMode: ReturnModeSimple }
+
+	for d := 1; d <= 30; d++ {
+		day := time.Date(2024, 4, d, 16, 0, 0, 0, time.UTC)
+		if day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			continue
+		}
+
+		c := float64(100 + d)
+		res.BarResults = append(res.BarResults, &BarResult{
+			Time         : day,
+			Open         : c - 0.5,
+			High         : c + 1,
+			Low          : c - 1,
+			Close        : c,
+			Volume       : 10,
+			BarChangePerc: 1,
+			RawChangePerc: 1,
+			AtrPerc      : float64(d % 5),
+			Rsi          : float64(d),
+		})
+	}
+
+	res.Bars = len(res.BarResults)
+
+	weekly, err := res.Resample(ResamplePeriodWeekly)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if weekly.Bars != 5 || len(weekly.BarResults) != 5 || weekly.Resampled != ResamplePeriodWeekly {
+		t.Fatalf("Bad number of weeks. Got %d", len(weekly.BarResults))
+	}
+
+	//--- Second week: 8th to 12th
+
+	w := weekly.BarResults[1]
+
+	if !w.Time.Equal(time.Date(2024, 4, 12, 16, 0, 0, 0, time.UTC)) || w.Open != 107.5 || w.Close != 112 || w.High != 113 || w.Low != 107 {
+		t.Errorf("Bad weekly OHLC. Got %v o=%v h=%v l=%v c=%v", w.Time, w.Open, w.High, w.Low, w.Close)
+	}
+
+	if w.Volume != 50 || w.Rsi != 12 {
+		t.Errorf("Volume must be summed and indicators taken at the end of the week. Got volume=%v, rsi=%v", w.Volume, w.Rsi)
+	}
+
+	//--- ATR% of the 8th..12th is 3,4,0,1,2
+
+	if w.AtrPerc != 2 {
+		t.Errorf("ATR%% must be averaged. Got %v", w.AtrPerc)
+	}
+
+	if exp := (math.Pow(1.01, 5) -1) * 100; math.Abs(w.BarChangePerc - exp) > 0.01 {
+		t.Errorf("Changes must be compounded. Got %v, expected %v", w.BarChangePerc, exp)
+	}
+
+	//--- The last week is partial (29th and 30th)
+
+	if last := weekly.BarResults[4]; last.Volume != 20 || last.Close != 130 {
+		t.Errorf("Bad last week. Got volume=%v, close=%v", last.Volume, last.Close)
+	}
+
+	//--- Monthly and errors
+
+	monthly, err := res.Resample(ResamplePeriodMonthly)
+	if err != nil || len(monthly.BarResults) != 1 || monthly.BarResults[0].Open != 100.5 {
+		t.Errorf("A month of data must give one bar. Got %v, %v", monthly, err)
+	}
+
+	if _, err = res.Resample("yearly"); err == nil {
+		t.Errorf("An unknown period must be rejected")
+	}
+
+	if len(res.BarResults) != 22 || res.BarResults[0].Volume != 10 {
+		t.Errorf("The original response must be left untouched")
+	}
+}
+
+//=============================================================================
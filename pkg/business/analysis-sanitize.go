@@ -0,0 +1,50 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"math"
+	"reflect"
+)
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- Replaces NaN and Inf values, which JSON cannot represent, in all the float
+//--- fields of the bar results. Fields are found by reflection, so that new
+//--- indicators are covered too. Returns the number of replaced values
+
+func sanitizeBarResults(list []*BarResult, value float64) int {
+	count := 0
+
+	for _, br := range list {
+		v := reflect.ValueOf(br).Elem()
+
+		for i := 0; i < v.NumField(); i++ {
+			f := v.Field(i)
+
+			if f.Kind() != reflect.Float64 || !f.CanSet() {
+				continue
+			}
+
+			if x := f.Float(); math.IsNaN(x) || math.IsInf(x, 0) {
+				f.SetFloat(value)
+				count++
+			}
+		}
+	}
+
+	return count
+}
+
+//=============================================================================
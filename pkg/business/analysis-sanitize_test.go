@@ -0,0 +1,86 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+
+	"github.com/algotiqa/core/auth"
+)
+
+//=============================================================================
+
+func TestSanitizeBarResults(t *testing.T) {
+	zero := 0.0
+
+	list := []*BarResult{
+		{ Close: 100, Rsi: zero / zero, Cmf20: 1 / zero },
+		{ Close: 101, Beta: -1 / zero },
+	}
+
+	if n := sanitizeBarResults(list, -1); n != 3 {
+		t.Errorf("Bad count. Got %d, expected 3", n)
+	}
+
+	if list[0].Rsi != -1 || list[0].Cmf20 != -1 || list[1].Beta != -1 || list[0].Close != 100 {
+		t.Errorf("Non finite values must be replaced by the sentinel. Got %+v and %+v", list[0], list[1])
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisSanitized(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		Source     : NewSliceDataSource(points),
+		PostProcess: func(list []*BarResult) error {
+			//--- Volume is 0 on all bars
+
+			for _, br := range list {
+				br.Beta = br.Close / float64(br.Volume)
+			}
+			return nil
+		},
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.Sanitized != len(res.BarResults) {
+		t.Errorf("Bad sanitized count. Got %d, expected %d", res.Sanitized, len(res.BarResults))
+	}
+
+	for _, br := range res.BarResults {
+		if math.IsInf(br.Beta, 0) || math.IsNaN(br.Beta) || br.Beta != 0 {
+			t.Fatalf("Values must be finite. Got %v", br.Beta)
+		}
+	}
+
+	if _, err = NewAnalysisParams(&DataProductAnalysisSpec{ NonFiniteValue: "NaN" }); err == nil {
+		t.Errorf("A non finite sentinel must be rejected")
+	}
+}
+
+//=============================================================================
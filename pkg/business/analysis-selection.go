@@ -0,0 +1,110 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"errors"
+	"slices"
+	"strings"
+)
+
+//=============================================================================
+//--- Indicators computed on the bars. ATR also covers the volatility regime
+
+type IndicatorSet uint32
+
+const (
+	IndicatorSqn IndicatorSet = 1 << iota
+	IndicatorAtr
+	IndicatorRsi
+	IndicatorMa
+	IndicatorMacd
+	IndicatorBoll
+	IndicatorKelt
+	IndicatorDonch
+	IndicatorAdx
+	IndicatorVwap
+	IndicatorMfi
+	IndicatorCmf
+	IndicatorRoc
+	IndicatorCci
+	IndicatorPivots
+	IndicatorStoch
+	IndicatorWillr
+	IndicatorTrix
+	IndicatorReg
+	IndicatorSar
+	IndicatorIchi
+	IndicatorObv
+
+	AllIndicators = IndicatorObv << 1 - 1
+)
+
+//--- Names used in the spec, in the order of the flags
+
+var IndicatorNames = []string{
+	"sqn", "atr", "rsi", "ma", "macd", "boll", "kelt", "donch", "adx", "vwap", "mfi",
+	"cmf", "roc", "cci", "pivots", "stoch", "willr", "trix", "reg", "sar", "ichi", "obv",
+}
+
+//=============================================================================
+
+func (s IndicatorSet) Has(flag IndicatorSet) bool {
+	return s & flag != 0
+}
+
+//=============================================================================
+//--- Comma separated names of the selected indicators
+
+func (s IndicatorSet) String() string {
+	var names []string
+
+	for i, name := range IndicatorNames {
+		if s.Has(1 << i) {
+			names = append(names, name)
+		}
+	}
+
+	return strings.Join(names, ",")
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- All indicators are computed when the value is empty. The Keltner channel is
+//--- built on the ATR, so selecting it selects the ATR too
+
+func parseIndicators(value string) (IndicatorSet, error) {
+	if value == "" {
+		return AllIndicators, nil
+	}
+
+	var set IndicatorSet
+
+	for _, name := range strings.Split(value, ",") {
+		i := slices.Index(IndicatorNames, strings.TrimSpace(name))
+		if i == -1 {
+			return 0, errors.New("unknown indicator '"+ name +"'. Allowed values are: "+ strings.Join(IndicatorNames, ", "))
+		}
+
+		set |= 1 << i
+	}
+
+	if set.Has(IndicatorKelt) {
+		set |= IndicatorAtr
+	}
+
+	return set, nil
+}
+
+//=============================================================================
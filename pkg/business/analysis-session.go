@@ -0,0 +1,95 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+//--- Bars are filtered when the session has a length. Its bounds can't be equal
+//--- in the spec, so params built by hand don't filter
+
+func (ap *AnalysisParams) hasSession() bool {
+	return ap.SessionStart != ap.SessionEnd
+}
+
+//=============================================================================
+//--- Session as 'hh:mm-hh:mm', empty when bars are not filtered
+
+func (ap *AnalysisParams) session() string {
+	if !ap.hasSession() {
+		return ""
+	}
+
+	return formatSessionTime(ap.SessionStart) +"-"+ formatSessionTime(ap.SessionEnd)
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+//--- Keeps the bars ending within the session, in the given location. Bars are
+//--- timestamped at their end, so the one ending at the open is left out. A session
+//--- ending before its start spans midnight. Returns the bars left and the ones dropped
+
+func filterSession(dataPoints []*ds.DataPoint, ap *AnalysisParams, loc *time.Location) ([]*ds.DataPoint, int) {
+	if !ap.hasSession() {
+		return dataPoints, 0
+	}
+
+	var list []*ds.DataPoint
+
+	for _, dp := range dataPoints {
+		t := dp.Time.In(loc)
+		m := t.Hour()*60 + t.Minute()
+
+		inside := m > ap.SessionStart && m <= ap.SessionEnd
+		if ap.SessionStart > ap.SessionEnd {
+			inside = m > ap.SessionStart || m <= ap.SessionEnd
+		}
+
+		if inside {
+			list = append(list, dp)
+		}
+	}
+
+	return list, len(dataPoints) - len(list)
+}
+
+//=============================================================================
+//--- Returns the minutes since midnight of a 'hh:mm' time (0 when empty)
+
+func parseSessionTime(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, errors.New("expected format is hh:mm")
+	}
+
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+//=============================================================================
+
+func formatSessionTime(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes / 60, minutes % 60)
+}
+
+//=============================================================================
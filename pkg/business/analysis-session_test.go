@@ -0,0 +1,100 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+
+func TestSessionFilter(t *testing.T) {
+	const days = 30
+
+	//--- Hourly bars around the clock, timestamped at their end
+
+	var points []*ds.DataPoint
+
+	for i := 0; i < days*24; i++ {
+		c := 100 + float64(i%5)
+		points = append(points, &ds.DataPoint{
+			Time : time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC).Add(time.Hour * time.Duration(i)),
+			Open : c,
+			High : c + 1,
+			Low  : c - 1,
+			Close: c,
+		})
+	}
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id       : 1,
+			Timezone : "UTC",
+			Timeframe: "60",
+			Config   : buildQueryConfig(),
+		},
+		SessionStart: "09:30",
+		SessionEnd  : "16:00",
+		Source      : NewSliceDataSource(points),
+	}
+
+	res, err := AnalyzeProduct(&auth.Context{ Log: slog.New(slog.DiscardHandler) }, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	//--- The bars ending from 10:00 to 16:00 are left
+
+	if exp := days * (24 - 7); res.OffSession != exp {
+		t.Errorf("Bad number of bars out of session. Got %d, expected %d", res.OffSession, exp)
+	}
+
+	if res.TotalBars != days*7 || res.Session != "09:30-16:00" {
+		t.Errorf("Bad session bars. Got %d in '%s', expected %d", res.TotalBars, res.Session, days*7)
+	}
+
+	for _, br := range res.BarResults {
+		if h := br.Time.Hour(); h < 10 || h > 16 {
+			t.Fatalf("Bars out of session must be removed. Got %v", br.Time)
+		}
+	}
+
+	//--- Both bounds are required, on intraday bars only
+
+	for _, s := range []*DataProductAnalysisSpec{
+		{ SessionStart: "09:30" },
+		{ SessionStart: "09:30", SessionEnd: "9.30pm" },
+		{ SessionStart: "09:30", SessionEnd: "09:30" },
+		{ SessionStart: "09:30", SessionEnd: "16:00", QuerySpec: QuerySpec{ Timeframe: "1440" } },
+	} {
+		if s.Timeframe == "" {
+			s.Timeframe = "60"
+		}
+
+		if _, err = NewAnalysisParams(s); err == nil {
+			t.Errorf("Bad session must be rejected: %s-%s (%s)", s.SessionStart, s.SessionEnd, s.Timeframe)
+		}
+	}
+
+	//--- By default nothing is filtered
+
+	ap := defaultParams()
+	if list, n := filterSession(points, ap, time.UTC); n != 0 || len(list) != len(points) {
+		t.Errorf("No filter expected by default. Got %d bars dropped", n)
+	}
+}
+
+//=============================================================================
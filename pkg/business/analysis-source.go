@@ -0,0 +1,100 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"context"
+
+	"github.com/algotiqa/data-collector/pkg/core"
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+//--- Provides the data points to analyze
+
+type DataSource interface {
+	Fetch(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error)
+}
+
+//=============================================================================
+//--- Adapts a plain function to the DataSource interface
+
+type DataSourceFunc func(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error)
+
+func (f DataSourceFunc) Fetch(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+	return f(ctx, params, config)
+}
+
+//=============================================================================
+//--- Reads from the datastore, going through the analysis cache when enabled.
+//--- Used when the spec has no source
+
+var DatastoreSource DataSource = DataSourceFunc(getCachedDataPoints)
+
+//=============================================================================
+//--- In-memory source, mainly used in tests. Data points must be sorted by time
+
+type SliceDataSource struct {
+	DataPoints []*ds.DataPoint
+}
+
+//=============================================================================
+
+func NewSliceDataSource(dataPoints []*ds.DataPoint) *SliceDataSource {
+	return &SliceDataSource{
+		DataPoints: dataPoints,
+	}
+}
+
+//=============================================================================
+//--- Returns a copy of the data points in the requested range, like the datastore does
+
+func (s *SliceDataSource) Fetch(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var list []*ds.DataPoint
+
+	for _, dp := range s.DataPoints {
+		if params.From != nil && dp.Time.Before(*params.From) {
+			continue
+		}
+
+		if params.To != nil && dp.Time.After(*params.To) {
+			break
+		}
+
+		list = append(list, dp)
+
+		if params.Limit > 0 && len(list) >= params.Limit {
+			break
+		}
+	}
+
+	return cloneDataPoints(list), nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (spec *DataProductAnalysisSpec) dataSource() DataSource {
+	if spec.Source != nil {
+		return spec.Source
+	}
+
+	return DatastoreSource
+}
+
+//=============================================================================
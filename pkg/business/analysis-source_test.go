@@ -0,0 +1,60 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"context"
+	"testing"
+)
+
+//=============================================================================
+
+func TestSliceDataSource(t *testing.T) {
+	points := buildDataPoints(buildCloses(10, 100, func(i int) float64 { return 1 }))
+	source := NewSliceDataSource(points)
+
+	from := points[2].Time
+	to   := points[6].Time
+
+	list, err := source.Fetch(context.Background(), &QueryParams{ From: &from, To: &to }, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(list) != 5 || !list[0].Time.Equal(from) || !list[4].Time.Equal(to) {
+		t.Fatalf("Bad range. Got %d points", len(list))
+	}
+
+	//--- Returned points are copies
+
+	list[0].Close = 0
+	if points[2].Close == 0 {
+		t.Errorf("Source data points must not be changed by callers")
+	}
+
+	//--- Limit and open range
+
+	list, _ = source.Fetch(context.Background(), &QueryParams{ Limit: 3 }, nil)
+	if len(list) != 3 || !list[0].Time.Equal(points[0].Time) {
+		t.Errorf("Bad limited fetch. Got %d points", len(list))
+	}
+
+	//--- A cancelled request is not served
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err = source.Fetch(ctx, &QueryParams{ To: &to }, nil); err == nil {
+		t.Errorf("A cancelled context must return an error")
+	}
+}
+
+//=============================================================================
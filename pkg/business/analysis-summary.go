@@ -0,0 +1,586 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"github.com/algotiqa/data-collector/pkg/ds"
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+
+type BarGap struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Missing int       `json:"missing"`
+}
+
+//=============================================================================
+//--- Summary values are calculated over all bars, including the ones consumed by
+//--- the indicators' warm-up. When the history is extended before the requested
+//--- range, the bars fetched only for the warm-up are left out
+
+func calcSummary(res *DataProductAnalysisResponse, dataPoints []*ds.DataPoint, list []*BarResult, ap *AnalysisParams) {
+	returns := statsValues(list)
+	periods := annualizationPeriods(dataPoints, ap)
+
+	//--- The rolling Sharpe is a per bar indicator: its window can use the extra history
+
+	calcRollingSharpe(list, returns, ap.SharpeLen, ap.RiskFreeRate / periods, periods)
+
+	barResults := res.BarResults
+
+	if from := ap.summaryFrom; from != nil {
+		dataPoints = dataPointsFrom(dataPoints, *from)
+		list       = barResultsFrom(list, *from)
+		barResults = barResultsFrom(barResults, *from)
+		returns    = statsValues(list)
+	}
+
+	res.RiskFreeRate   = ap.RiskFreeRate
+	res.PeriodsPerYear = periods
+	res.SharpeLength   = ap.SharpeLen
+	res.Sharpe, res.Sortino = calcRiskAdjustedRatios(returns, ap.RiskFreeRate / periods, periods)
+	res.HistVol = calcHistVol(returns, periods)
+
+	mean, variance := meanAndVariance(returns)
+	res.AutoCorr = calcAutoCorr(returns, mean, variance, ap.AutoCorrLags)
+
+	//--- Up and down days describe the reported changes, which are never winsorized
+	calcUpDownDays(res, changeValues(list))
+
+	var ok bool
+	res.Skewness, res.Kurtosis, ok = calcSkewKurt(returns)
+	if !ok {
+		res.Notes = append(res.Notes, "Skewness and kurtosis need at least 4 returns")
+	}
+
+	res.Var95, res.Cvar95 = calcVar(returns, 0.95)
+	res.Var99, res.Cvar99 = calcVar(returns, 0.99)
+
+	dd := calcMaxDrawdown(dataPoints)
+	if dd != nil {
+		res.MaxDrawdown    = dd.Value
+		res.DrawdownPeak   = &dd.Peak
+		res.DrawdownTrough = &dd.Trough
+	}
+
+	res.RegimeChanges = calcRegimeChanges(barResults, ap.MinRegimeLen)
+
+	if ap.DetectGaps {
+		res.Gaps = detectGaps(dataPoints, ap.Timeframe, ap.Calendar)
+	}
+}
+
+//=============================================================================
+//--- Data points at or after 'from'
+
+func dataPointsFrom(dataPoints []*ds.DataPoint, from time.Time) []*ds.DataPoint {
+	n := 0
+	for n < len(dataPoints) && dataPoints[n].Time.Before(from) {
+		n++
+	}
+
+	return dataPoints[n:]
+}
+
+//=============================================================================
+//--- Bar results at or after 'from'
+
+func barResultsFrom(list []*BarResult, from time.Time) []*BarResult {
+	n := 0
+	for n < len(list) && list[n].Time.Before(from) {
+		n++
+	}
+
+	return list[n:]
+}
+
+//=============================================================================
+//===
+//=== Annualization
+//===
+//=============================================================================
+//--- Periods used by all annualized statistics: the ones given in the spec or,
+//--- by default, the ones of the timeframe
+
+func annualizationPeriods(dataPoints []*ds.DataPoint, ap *AnalysisParams) float64 {
+	if ap.PeriodsPerYear > 0 {
+		return ap.PeriodsPerYear
+	}
+
+	return periodsPerYear(dataPoints, ap.Timeframe)
+}
+
+//=============================================================================
+//--- Daily bars use the trading days. For intraday bars we also need the number of
+//--- bars in a session, which is measured on the data itself
+
+func periodsPerYear(dataPoints []*ds.DataPoint, timeframe int) float64 {
+	if timeframe == WeeklyTimeframe {
+		return WeeksPerYear
+	}
+
+	if timeframe >= 1440 || len(dataPoints) == 0 {
+		return TradingDaysPerYear
+	}
+
+	days := map[string]bool{}
+
+	for _, dp := range dataPoints {
+		days[dp.Time.Format(time.DateOnly)] = true
+	}
+
+	return TradingDaysPerYear * float64(len(dataPoints)) / float64(len(days))
+}
+
+//=============================================================================
+//===
+//=== Up and down days
+//===
+//=============================================================================
+//--- Counts bars by the sign of their change. Averages are magnitudes, so the
+//--- down one is positive too
+
+func calcUpDownDays(res *DataProductAnalysisResponse, returns []float64) {
+	sumUp, sumDown := 0.0, 0.0
+
+	for _, r := range returns {
+		if r > 0 {
+			res.UpDays++
+			sumUp += r
+		} else if r < 0 {
+			res.DownDays++
+			sumDown -= r
+		} else {
+			res.FlatDays++
+		}
+	}
+
+	if res.UpDays > 0 {
+		res.AvgUpPerc = sumUp / float64(res.UpDays)
+	}
+
+	if res.DownDays > 0 {
+		res.AvgDownPerc = sumDown / float64(res.DownDays)
+	}
+}
+
+//=============================================================================
+//===
+//=== Risk adjusted ratios
+//===
+//=============================================================================
+//--- Sharpe uses the standard deviation of all returns while Sortino penalizes
+//--- only the returns below the risk free rate
+
+func calcRiskAdjustedRatios(returns []float64, riskFree float64, periods float64) (float64, float64) {
+	if len(returns) == 0 {
+		return 0, 0
+	}
+
+	n := float64(len(returns))
+
+	sum := 0.0
+	for _, r := range returns {
+		sum += r - riskFree
+	}
+
+	mean := sum / n
+
+	sumSq   := 0.0
+	sumDown := 0.0
+
+	for _, r := range returns {
+		diff   := r - riskFree - mean
+		sumSq  += diff * diff
+
+		if excess := r - riskFree; excess < 0 {
+			sumDown += excess * excess
+		}
+	}
+
+	stdDev  := math.Sqrt(sumSq   / n)
+	downDev := math.Sqrt(sumDown / n)
+
+	sharpe, sortino := 0.0, 0.0
+
+	if stdDev != 0 {
+		sharpe = mean / stdDev * math.Sqrt(periods)
+	}
+
+	if downDev != 0 {
+		sortino = mean / downDev * math.Sqrt(periods)
+	}
+
+	return sharpe, sortino
+}
+
+//=============================================================================
+//--- Sharpe ratio of each bar over the last sharpeLen returns. Bars before the
+//--- window fills are left at zero
+
+func calcRollingSharpe(list []*BarResult, returns []float64, sharpeLen int, riskFree float64, periods float64) {
+	for i := sharpeLen -1; i < len(list); i++ {
+		list[i].RollingSharpe, _ = calcRiskAdjustedRatios(returns[i - sharpeLen +1 : i+1], riskFree, periods)
+	}
+}
+
+//=============================================================================
+//--- Population standard deviation of the returns, annualized
+
+func calcHistVol(returns []float64, periods float64) float64 {
+	_, variance := meanAndVariance(returns)
+
+	return math.Sqrt(variance) * math.Sqrt(periods)
+}
+
+//=============================================================================
+//--- Population mean and variance
+
+func meanAndVariance(values []float64) (float64, float64) {
+	n := float64(len(values))
+	if n == 0 {
+		return 0, 0
+	}
+
+	mean := 0.0
+	for _, v := range values {
+		mean += v / n
+	}
+
+	sumSq := 0.0
+	for _, v := range values {
+		sumSq += (v - mean) * (v - mean)
+	}
+
+	return mean, sumSq / n
+}
+
+//=============================================================================
+//===
+//=== Distribution shape
+//===
+//=============================================================================
+//--- Sample (bias-corrected) skewness and excess kurtosis of the returns. The
+//--- corrections need at least 4 values: with fewer, or with no dispersion, both are 0
+
+func calcSkewKurt(returns []float64) (float64, float64, bool) {
+	n := float64(len(returns))
+	if n < 4 {
+		return 0, 0, false
+	}
+
+	mean, m2 := meanAndVariance(returns)
+	if m2 == 0 {
+		return 0, 0, true
+	}
+
+	m3, m4 := 0.0, 0.0
+
+	for _, r := range returns {
+		d  := r - mean
+		m3 += d*d*d / n
+		m4 += d*d*d*d / n
+	}
+
+	g1 := m3 / math.Pow(m2, 1.5)
+	g2 := m4 / (m2*m2) - 3
+
+	skew := g1 * math.Sqrt(n*(n-1)) / (n-2)
+	kurt := (n-1) / ((n-2)*(n-3)) * ((n+1)*g2 + 6)
+
+	return skew, kurt, true
+}
+
+//=============================================================================
+//--- Autocorrelation of the returns at lags 1..maxLag, using the mean and variance
+//--- of the whole series. Positive values hint at momentum, negative ones at mean
+//--- reversion. Lags are limited by the series length; nil without dispersion
+
+func calcAutoCorr(returns []float64, mean float64, variance float64, maxLag int) []float64 {
+	n := len(returns)
+	if variance == 0 || maxLag == 0 {
+		return nil
+	}
+
+	var res []float64
+
+	for lag := 1; lag <= maxLag && lag < n; lag++ {
+		sum := 0.0
+
+		for i := lag; i < n; i++ {
+			sum += (returns[i] - mean) * (returns[i-lag] - mean)
+		}
+
+		res = append(res, sum / (float64(n) * variance))
+	}
+
+	return res
+}
+
+//=============================================================================
+//===
+//=== Value at Risk
+//===
+//=============================================================================
+//--- Historical VaR and expected shortfall (CVaR) at the given confidence level, as
+//--- positive fractions for losses. The tail holds the worst ceil(n * (1-level))
+//--- returns: VaR is the best of them, CVaR their mean. Both are 0 when the sample
+//--- is too small for the tail to hold at least one return (20 for 95%, 100 for 99%)
+
+func calcVar(returns []float64, level float64) (float64, float64) {
+	if len(returns) < minVarReturns(level) {
+		return 0, 0
+	}
+
+	sorted := slices.Clone(returns)
+	slices.Sort(sorted)
+
+	tail := int(math.Ceil(float64(len(sorted)) * (1 - level) - 1e-9))
+	sum  := 0.0
+
+	for _, r := range sorted[:tail] {
+		sum += r
+	}
+
+	return -sorted[tail-1], -sum / float64(tail)
+}
+
+//=============================================================================
+
+func minVarReturns(level float64) int {
+	return int(math.Ceil(1 / (1 - level) - 1e-9))
+}
+
+//=============================================================================
+//===
+//=== Drawdown
+//===
+//=============================================================================
+
+type Drawdown struct {
+	Value  float64
+	Peak   time.Time
+	Trough time.Time
+}
+
+//=============================================================================
+//--- Largest peak-to-trough decline of the close, as a fraction of the peak.
+//--- Returns nil when prices never go below a previous peak
+
+func calcMaxDrawdown(dataPoints []*ds.DataPoint) *Drawdown {
+	var res *Drawdown
+	var peak *ds.DataPoint
+
+	for _, dp := range dataPoints {
+		if peak == nil || dp.Close > peak.Close {
+			peak = dp
+			continue
+		}
+
+		if peak.Close <= 0 {
+			continue
+		}
+
+		dd := (peak.Close - dp.Close) / peak.Close
+
+		if dd > 0 && (res == nil || dd > res.Value) {
+			res = &Drawdown{
+				Value : dd,
+				Peak  : peak.Time,
+				Trough: dp.Time,
+			}
+		}
+	}
+
+	return res
+}
+
+//=============================================================================
+//===
+//=== Regimes
+//===
+//=============================================================================
+
+type RegimeSegment struct {
+	From      time.Time `json:"from"`
+	To        time.Time `json:"to"`
+	Direction int       `json:"direction"`
+	Bars      int       `json:"bars"`
+}
+
+//=============================================================================
+//--- Collapses consecutive bars with the same direction. Runs shorter than minLen
+//--- are considered noise and extend the current segment
+
+func calcRegimeChanges(list []*BarResult, minLen int) []*RegimeSegment {
+	var segments []*RegimeSegment
+
+	for i := 0; i < len(list); {
+		//--- Find the run of bars with the same direction
+
+		j := i
+		for j+1 < len(list) && list[j+1].Direction == list[i].Direction {
+			j++
+		}
+
+		bars := j - i +1
+		last := len(segments) -1
+
+		if last >= 0 && (bars < minLen || segments[last].Direction == list[i].Direction) {
+			segments[last].To    = list[j].Time
+			segments[last].Bars += bars
+		} else {
+			segments = append(segments, &RegimeSegment{
+				From     : list[i].Time,
+				To       : list[j].Time,
+				Direction: list[i].Direction,
+				Bars     : bars,
+			})
+		}
+
+		i = j +1
+	}
+
+	return segments
+}
+
+//=============================================================================
+//===
+//=== Gaps
+//===
+//=============================================================================
+//--- A gap is reported when bars are missing between two consecutive ones. 'From'
+//--- is the last bar before the gap, 'To' the first after. Daily bars only count the
+//--- trading days in between (Monday to Friday without a calendar), weekly bars the
+//--- ISO weeks with at least a trading day. Intraday bars only count the ones missing
+//--- within the same day, so that the overnight and weekend breaks are not reported
+
+func detectGaps(dataPoints []*ds.DataPoint, timeframe int, calendar *TradingCalendar) []*BarGap {
+	var gaps []*BarGap
+
+	for i := 1; i < len(dataPoints); i++ {
+		prev := dataPoints[i-1].Time
+		curr := dataPoints[i  ].Time
+
+		if missing := len(missingBarTimes(prev, curr, timeframe, calendar)); missing > 0 {
+			gaps = append(gaps, &BarGap{
+				From   : prev,
+				To     : curr,
+				Missing: missing,
+			})
+		}
+	}
+
+	return gaps
+}
+
+//=============================================================================
+//--- Fills the gaps reported by detectGaps with flat bars at the previous close,
+//--- so that they are not reported anymore. Returns the filled bars too
+
+func fillGaps(dataPoints []*ds.DataPoint, timeframe int, calendar *TradingCalendar) ([]*ds.DataPoint, map[*ds.DataPoint]bool) {
+	if len(dataPoints) == 0 {
+		return dataPoints, nil
+	}
+
+	res    := []*ds.DataPoint{ dataPoints[0] }
+	filled := map[*ds.DataPoint]bool{}
+
+	for i := 1; i < len(dataPoints); i++ {
+		prev := dataPoints[i-1]
+
+		for _, t := range missingBarTimes(prev.Time, dataPoints[i].Time, timeframe, calendar) {
+			dp := &ds.DataPoint{
+				Time        : t,
+				Open        : prev.Close,
+				High        : prev.Close,
+				Low         : prev.Close,
+				Close       : prev.Close,
+				OpenInterest: prev.OpenInterest,
+			}
+
+			res = append(res, dp)
+			filled[dp] = true
+		}
+
+		res = append(res, dataPoints[i])
+	}
+
+	return res, filled
+}
+
+//=============================================================================
+//--- Times of the bars missing between prev and curr, as reported by detectGaps.
+//--- Filled days keep the time of the previous bar
+
+func missingBarTimes(prev time.Time, curr time.Time, timeframe int, calendar *TradingCalendar) []time.Time {
+	var times []time.Time
+
+	//--- A week is missing when it has at least a trading day
+
+	if timeframe == WeeklyTimeframe {
+		if calendar == nil {
+			calendar = weekdayCalendar
+		}
+
+		py, pw := prev.ISOWeek()
+		cy, cw := curr.ISOWeek()
+
+		for t := prev.AddDate(0, 0, 7); t.Before(curr); t = t.AddDate(0, 0, 7) {
+			if y, w := t.ISOWeek(); y == cy && w == cw || y == py && w == pw {
+				break
+			}
+
+			if calendar.hasTradingDayInWeek(t) {
+				times = append(times, t)
+			}
+		}
+
+		return times
+	}
+
+	if timeframe == DefaultTimeframe {
+		if calendar == nil {
+			calendar = weekdayCalendar
+		}
+
+		last := types.ToDate(&curr)
+
+		for t := prev.AddDate(0, 0, 1); types.ToDate(&t) < last; t = t.AddDate(0, 0, 1) {
+			if calendar.IsTradingDay(t) {
+				times = append(times, t)
+			}
+		}
+
+		return times
+	}
+
+	if types.ToDate(&prev) != types.ToDate(&curr) {
+		return nil
+	}
+
+	tf    := time.Duration(timeframe) * time.Minute
+	steps := int(math.Round(float64(curr.Sub(prev)) / float64(tf)))
+
+	for k := 1; k < steps; k++ {
+		times = append(times, prev.Add(time.Duration(k) * tf))
+	}
+
+	return times
+}
+
+//=============================================================================
@@ -0,0 +1,553 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/data-collector/pkg/ds"
+)
+
+//=============================================================================
+
+func buildDailyPoints(days ...string) []*ds.DataPoint {
+	var list []*ds.DataPoint
+
+	for _, d := range days {
+		t, err := time.Parse(time.DateOnly, d)
+		if err != nil {
+			panic(err)
+		}
+
+		list = append(list, &ds.DataPoint{ Time: t.Add(16 * time.Hour), Open: 100, High: 101, Low: 99, Close: 100 })
+	}
+
+	return list
+}
+
+//=============================================================================
+
+func TestGapsSingleDay(t *testing.T) {
+	//--- Tue, Wed, Fri (Thursday is missing)
+
+	gaps := detectGaps(buildDailyPoints("2024-03-05", "2024-03-06", "2024-03-08"), 1440, nil)
+
+	if len(gaps) != 1 || gaps[0].Missing != 1 || gaps[0].From.Day() != 6 || gaps[0].To.Day() != 8 {
+		t.Fatalf("Expected a 1 day gap between 6 and 8. Got %+v", gaps)
+	}
+}
+
+//=============================================================================
+
+func TestGapsWeekendAndHoliday(t *testing.T) {
+	//--- Thu, Fri, Tue (weekend + Monday holiday): without a calendar only Monday is missing
+
+	gaps := detectGaps(buildDailyPoints("2024-05-23", "2024-05-24", "2024-05-28"), 1440, nil)
+
+	if len(gaps) != 1 || gaps[0].Missing != 1 {
+		t.Fatalf("Expected a 1 day gap. Got %+v", gaps)
+	}
+
+	//--- Fri -> Mon is just the weekend
+
+	if gaps = detectGaps(buildDailyPoints("2024-03-08", "2024-03-11"), 1440, nil); len(gaps) != 0 {
+		t.Errorf("Weekends must not be reported as gaps. Got %+v", gaps)
+	}
+
+	//--- Intraday: the overnight break is not a gap, a missing hour within the day is
+
+	day    := time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC)
+	hourly := []*ds.DataPoint{
+		{ Time: day.Add(21 * time.Hour) },
+		{ Time: day.Add(22 * time.Hour) },
+		{ Time: day.Add(33 * time.Hour) },
+		{ Time: day.Add(35 * time.Hour) },
+	}
+
+	if gaps = detectGaps(hourly, 60, nil); len(gaps) != 1 || gaps[0].Missing != 1 || gaps[0].From.Hour() != 9 {
+		t.Errorf("Expected only the hour missing at 10:00. Got %+v", gaps)
+	}
+}
+
+//=============================================================================
+
+func TestGapsWeekly(t *testing.T) {
+	//--- Fridays: the week of March 15 is missing
+
+	gaps := detectGaps(buildDailyPoints("2024-03-01", "2024-03-08", "2024-03-22"), WeeklyTimeframe, nil)
+
+	if len(gaps) != 1 || gaps[0].Missing != 1 || gaps[0].From.Day() != 8 || gaps[0].To.Day() != 22 {
+		t.Fatalf("Expected a 1 week gap between 8 and 22. Got %+v", gaps)
+	}
+
+	//--- A week without trading days is not a gap
+
+	closed, err := NewTradingCalendar("2024-03-11,2024-03-12,2024-03-13,2024-03-14,2024-03-15")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if gaps = detectGaps(buildDailyPoints("2024-03-08", "2024-03-22"), WeeklyTimeframe, closed); len(gaps) != 0 {
+		t.Errorf("Weeks without trading days must not be reported. Got %+v", gaps)
+	}
+
+	//--- Bars of consecutive weeks on different weekdays
+
+	if gaps = detectGaps(buildDailyPoints("2024-03-08", "2024-03-11"), WeeklyTimeframe, nil); len(gaps) != 0 {
+		t.Errorf("Consecutive weeks must not be reported. Got %+v", gaps)
+	}
+}
+
+//=============================================================================
+
+func TestGapsWithCalendar(t *testing.T) {
+	weekdays, err := NewTradingCalendar("12345")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	//--- Thu, Fri, Mon, Tue, Thu (Wednesday is missing)
+
+	points := buildDailyPoints("2024-03-07", "2024-03-08", "2024-03-11", "2024-03-12", "2024-03-14")
+	gaps   := detectGaps(points, 1440, weekdays)
+
+	if len(gaps) != 1 || gaps[0].Missing != 1 || gaps[0].From.Day() != 12 || gaps[0].To.Day() != 14 {
+		t.Fatalf("Expected only the missing Wednesday. Got %+v", gaps)
+	}
+
+	//--- Memorial day is a holiday: Fri -> Tue is not a gap
+
+	holidays, err := NewTradingCalendar("2024-05-27")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if gaps = detectGaps(buildDailyPoints("2024-05-24", "2024-05-28"), 1440, holidays); len(gaps) != 0 {
+		t.Errorf("Holidays must not be reported as gaps. Got %+v", gaps)
+	}
+
+	for _, bad := range []string{ "1238", "2024-13-01", "mon" } {
+		if _, err = NewTradingCalendar(bad); err == nil {
+			t.Errorf("Calendar %q must be rejected", bad)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestGapFill(t *testing.T) {
+	weekdays, err := NewTradingCalendar("12345")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	//--- Tue, Wed, Fri (Thursday is missing)
+
+	points := buildDailyPoints("2024-03-05", "2024-03-06", "2024-03-08")
+	points[1].Close = 102
+
+	list, filled := fillGaps(points, 1440, weekdays)
+
+	if len(list) != 4 || len(filled) != 1 || !filled[list[2]] {
+		t.Fatalf("Expected a synthetic bar for the missing day. Got %d bars, %d filled", len(list), len(filled))
+	}
+
+	if dp := list[2]; dp.Time.Day() != 7 || dp.Time.Hour() != 16 || dp.Open != 102 || dp.High != 102 || dp.Low != 102 || dp.Close != 102 {
+		t.Errorf("Synthetic bars must be flat at the previous close. Got %v", dp)
+	}
+
+	if gaps := detectGaps(list, 1440, weekdays); len(gaps) != 0 {
+		t.Errorf("Filled gaps must not be reported. Got %+v", gaps)
+	}
+
+	//--- With the calendar, weekends are not filled
+
+	if list, _ = fillGaps(buildDailyPoints("2024-03-08", "2024-03-11"), 1440, weekdays); len(list) != 2 {
+		t.Errorf("Non trading days must not be filled. Got %d bars", len(list))
+	}
+
+	//--- Through the analysis: the synthetic bar has no range and no change
+
+	daily   := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+	missing := daily[200].Time
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		DetectGaps: "true",
+		GapFill   : "true",
+		Source    : NewSliceDataSource(append(daily[:200:200], daily[201:]...)),
+	}
+
+	res, err := AnalyzeProduct(&auth.Context{ Log: slog.New(slog.DiscardHandler) }, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.Filled != 1 || len(res.Gaps) != 0 {
+		t.Errorf("Expected 1 filled bar and no gaps. Got %d filled, %d gaps", res.Filled, len(res.Gaps))
+	}
+
+	for _, br := range res.BarResults {
+		if br.Synthetic != br.Time.Equal(missing) {
+			t.Errorf("Only the missing day must be synthetic. Got %v at %v", br.Synthetic, br.Time)
+		}
+
+		if br.Synthetic && (br.BarChangePerc != 0 || br.TrueRange != 0 || br.Close != daily[199].Close) {
+			t.Errorf("Bad synthetic bar. Got change %v, range %v, close %v", br.BarChangePerc, br.TrueRange, br.Close)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRiskAdjustedRatios(t *testing.T) {
+	//--- mean = 0.01, stdDev = 0.02, downside deviation = sqrt(2 * 0.01^2 / 4) = 0.01/sqrt(2)
+
+	returns := []float64{ 0.03, -0.01, 0.03, -0.01 }
+
+	sharpe, sortino := calcRiskAdjustedRatios(returns, 0, 1)
+
+	if math.Abs(sharpe - 0.5) > 1e-9 || math.Abs(sortino - math.Sqrt2) > 1e-9 {
+		t.Errorf("Bad ratios. Expected 0.5 and sqrt(2) but got %v and %v", sharpe, sortino)
+	}
+
+	//--- Annualization and risk free rate
+
+	sharpe, _ = calcRiskAdjustedRatios(returns, 0.01, 4)
+
+	if math.Abs(sharpe) > 1e-9 {
+		t.Errorf("Returns equal to the risk free rate must have zero Sharpe. Got %v", sharpe)
+	}
+
+	sharpe, sortino = calcRiskAdjustedRatios(returns, 0, 4)
+
+	if math.Abs(sharpe - 1) > 1e-9 || math.Abs(sortino - 2*math.Sqrt2) > 1e-9 {
+		t.Errorf("Bad annualized ratios. Expected 1 and 2*sqrt(2) but got %v and %v", sharpe, sortino)
+	}
+}
+
+//=============================================================================
+
+func TestRollingSharpe(t *testing.T) {
+	//--- Choppy, then steady gains
+
+	points := buildDataPoints(buildCloses(200, 100, func(i int) float64 {
+		if i < 100 {
+			return float64(i%3) -1
+		}
+		return 1 + float64(i%2) / 2
+	}))
+
+	list := createBarResults(points, defaultParams())
+	calcRollingSharpe(list, statsValues(list), 20, 0, TradingDaysPerYear)
+
+	for _, br := range list[:19] {
+		if br.RollingSharpe != 0 {
+			t.Fatalf("No value expected before the window fills. Got %v", br.RollingSharpe)
+		}
+	}
+
+	if math.Abs(list[90].RollingSharpe) > 1 {
+		t.Errorf("Choppy bars must have a low Sharpe. Got %v", list[90].RollingSharpe)
+	}
+
+	//--- Rising as the window moves into the gains
+
+	for i := 100; i < 120; i += 5 {
+		if list[i+5].RollingSharpe <= list[i].RollingSharpe {
+			t.Errorf("Sharpe must rise during steady gains. Got %v at %d, then %v", list[i].RollingSharpe, i, list[i+5].RollingSharpe)
+		}
+	}
+
+	if list[150].RollingSharpe < 10 {
+		t.Errorf("Steady gains must have a high Sharpe. Got %v", list[150].RollingSharpe)
+	}
+}
+
+//=============================================================================
+
+func TestMaxDrawdown(t *testing.T) {
+	uptrend := buildDataPoints(buildCloses(20, 100, func(i int) float64 { return 1 }))
+
+	if dd := calcMaxDrawdown(uptrend); dd != nil {
+		t.Errorf("A monotonic uptrend has no drawdown. Got %+v", dd)
+	}
+
+	//--- 100 -> 120 -> 90 -> 130
+
+	vShape := buildDataPoints([]float64{ 100, 110, 120, 105, 90, 100, 130 })
+	dd     := calcMaxDrawdown(vShape)
+
+	if dd == nil || math.Abs(dd.Value - 0.25) > 1e-9 || !dd.Peak.Equal(vShape[2].Time) || !dd.Trough.Equal(vShape[4].Time) {
+		t.Errorf("Bad drawdown for a V shaped series. Got %+v", dd)
+	}
+}
+
+//=============================================================================
+
+func TestRegimeChanges(t *testing.T) {
+	build := func(directions ...int) []*BarResult {
+		var list []*BarResult
+		for i, d := range directions {
+			list = append(list, &BarResult{ Time: startTime.AddDate(0, 0, i), Direction: d })
+		}
+		return list
+	}
+
+	//--- Stable regime
+
+	list     := build(1, 1, 1, 1, 1, 1)
+	segments := calcRegimeChanges(list, DefaultMinRegimeLen)
+
+	if len(segments) != 1 || segments[0].Bars != 6 || segments[0].Direction != DirectionBull ||
+			!segments[0].From.Equal(list[0].Time) || !segments[0].To.Equal(list[5].Time) {
+		t.Errorf("A stable regime must give a single segment. Got %d segments", len(segments))
+	}
+
+	//--- Alternating regimes with a flicker
+
+	list     = build(1, 1, 1, -1, 1, 1, -1, -1, -1, -1, 0, 0, 0)
+	segments = calcRegimeChanges(list, DefaultMinRegimeLen)
+
+	expected := []struct{ direction, bars int }{
+		{ DirectionBull,    6 },
+		{ DirectionBear,    4 },
+		{ DirectionNeutral, 3 },
+	}
+
+	if len(segments) != len(expected) {
+		t.Fatalf("Bad number of segments. Got %d, expected %d", len(segments), len(expected))
+	}
+
+	for i, e := range expected {
+		if segments[i].Direction != e.direction || segments[i].Bars != e.bars {
+			t.Errorf("Bad segment %d. Got %+v, expected %+v", i, segments[i], e)
+		}
+	}
+
+	//--- Without a minimum length every change is reported
+
+	if segments = calcRegimeChanges(list, 1); len(segments) != 5 {
+		t.Errorf("Every change must be reported. Got %d segments", len(segments))
+	}
+}
+
+//=============================================================================
+
+func TestHistVol(t *testing.T) {
+	//--- Alternating +1%/-1% returns have a standard deviation of 1%
+
+	var returns []float64
+	for i := 0; i < 100; i++ {
+		returns = append(returns, 0.01 * float64(1 - 2*(i%2)))
+	}
+
+	if vol := calcHistVol(returns, TradingDaysPerYear); math.Abs(vol - 0.01*math.Sqrt(TradingDaysPerYear)) > 1e-12 {
+		t.Errorf("Bad daily volatility. Got %v", vol)
+	}
+
+	//--- Intraday bars have more periods per year: here 2 bars a day
+
+	points := buildDailyPoints("2024-01-02", "2024-01-02", "2024-01-03", "2024-01-03")
+	hourly := periodsPerYear(points, 60)
+
+	if vol := calcHistVol(returns, hourly); math.Abs(vol - 0.01*math.Sqrt(2*TradingDaysPerYear)) > 1e-12 {
+		t.Errorf("Bad intraday volatility. Got %v", vol)
+	}
+
+	if vol := calcHistVol(nil, TradingDaysPerYear); vol != 0 {
+		t.Errorf("No returns must give no volatility. Got %v", vol)
+	}
+}
+
+//=============================================================================
+
+func TestUpDownDays(t *testing.T) {
+	//--- Closes: 100, 102, 101, 101, 104, 100
+
+	points := buildDataPoints([]float64{ 100, 102, 101, 101, 104, 100 })
+	list   := createBarResults(points, defaultParams())
+
+	res := &DataProductAnalysisResponse{}
+	calcUpDownDays(res, changeValues(list))
+
+	if res.UpDays != 2 || res.DownDays != 2 || res.FlatDays != 1 {
+		t.Fatalf("Bad counts. Got up=%d, down=%d, flat=%d", res.UpDays, res.DownDays, res.FlatDays)
+	}
+
+	avgUp   := (2.0/100 + 3.0/101) / 2
+	avgDown := (1.0/102 + 4.0/104) / 2
+
+	if math.Abs(res.AvgUpPerc - avgUp) > 1e-12 || math.Abs(res.AvgDownPerc - avgDown) > 1e-12 {
+		t.Errorf("Bad averages. Got up=%v, down=%v, expected %v and %v", res.AvgUpPerc, res.AvgDownPerc, avgUp, avgDown)
+	}
+}
+
+//=============================================================================
+
+func TestSkewKurt(t *testing.T) {
+	//--- Symmetric returns
+
+	symmetric := []float64{ -0.02, -0.01, 0, 0.01, 0.02, -0.02, -0.01, 0, 0.01, 0.02 }
+
+	skew, _, ok := calcSkewKurt(symmetric)
+	if !ok || math.Abs(skew) > 1e-12 {
+		t.Errorf("A symmetric distribution must have no skew. Got %v", skew)
+	}
+
+	//--- Many small losses and a few big gains
+
+	rightSkewed := []float64{ -0.01, -0.01, -0.01, -0.01, -0.01, -0.01, -0.01, 0.05, -0.01, 0.08 }
+
+	skew, kurt, ok := calcSkewKurt(rightSkewed)
+	if !ok || skew <= 1 {
+		t.Errorf("Returns must be right-skewed. Got %v", skew)
+	}
+
+	if kurt <= 0 {
+		t.Errorf("Fat tails must give a positive excess kurtosis. Got %v", kurt)
+	}
+
+	//--- Degenerate cases
+
+	if skew, kurt, ok = calcSkewKurt([]float64{ 0.01, 0.02, 0.03 }); ok || skew != 0 || kurt != 0 {
+		t.Errorf("Fewer than 4 returns must give 0 and be flagged. Got %v, %v, %v", skew, kurt, ok)
+	}
+
+	ap     := defaultParams()
+	points := buildDataPoints([]float64{ 100, 101, 102 })
+	res    := &DataProductAnalysisResponse{}
+
+	calcSummary(res, points, createBarResults(points, ap), ap)
+
+	if len(res.Notes) != 1 {
+		t.Errorf("A note must explain the missing moments. Got %v", res.Notes)
+	}
+}
+
+//=============================================================================
+
+func TestAutoCorr(t *testing.T) {
+	ap := defaultParams()
+
+	summary := func(closes []float64) *DataProductAnalysisResponse {
+		points := buildDataPoints(closes)
+		res    := &DataProductAnalysisResponse{}
+		calcSummary(res, points, createBarResults(points, ap), ap)
+		return res
+	}
+
+	//--- Trending with slowly changing momentum
+
+	trending := summary(buildCloses(200, 100, func(i int) float64 { return 1 + float64(i/20) }))
+
+	if len(trending.AutoCorr) != DefaultAutoCorrLags {
+		t.Fatalf("Bad number of lags. Got %d, expected %d", len(trending.AutoCorr), DefaultAutoCorrLags)
+	}
+
+	if trending.AutoCorr[0] <= 0.5 {
+		t.Errorf("A trending series must have a positive lag-1 autocorrelation. Got %v", trending.AutoCorr[0])
+	}
+
+	//--- Alternating up and down moves
+
+	alternating := summary(buildCloses(200, 100, func(i int) float64 { return float64(i%2)*4 -2 }))
+
+	if alternating.AutoCorr[0] >= -0.5 || alternating.AutoCorr[1] <= 0.5 {
+		t.Errorf("An alternating series must have a negative lag-1 and a positive lag-2 autocorrelation. Got %v", alternating.AutoCorr)
+	}
+
+	//--- Lags are limited by the series length, and a flat series has none
+
+	if ac := calcAutoCorr([]float64{ 1, -1, 1 }, 1.0/3, 8.0/9, 5); len(ac) != 2 {
+		t.Errorf("Lags must be shorter than the series. Got %v", ac)
+	}
+
+	if ac := calcAutoCorr([]float64{ 1, 1, 1 }, 1, 0, 5); ac != nil {
+		t.Errorf("A flat series must have no autocorrelation. Got %v", ac)
+	}
+}
+
+//=============================================================================
+
+func TestValueAtRisk(t *testing.T) {
+	//--- Returns from -5% to +4.9% in 0.1% steps, shuffled
+
+	var returns []float64
+	for i := 0; i < 100; i++ {
+		returns = append(returns, float64((i*37) % 100 -50) / 1000)
+	}
+
+	//--- 95%: the 5 worst returns are -5%..-4.6%
+
+	v, cv := calcVar(returns, 0.95)
+	if math.Abs(v - 0.046) > 1e-12 || math.Abs(cv - 0.048) > 1e-12 {
+		t.Errorf("Bad 95%% values. Got VaR=%v CVaR=%v, expected 0.046 and 0.048", v, cv)
+	}
+
+	//--- 99%: only the worst return
+
+	v, cv = calcVar(returns, 0.99)
+	if math.Abs(v - 0.05) > 1e-12 || math.Abs(cv - 0.05) > 1e-12 {
+		t.Errorf("Bad 99%% values. Got VaR=%v CVaR=%v, expected 0.05", v, cv)
+	}
+
+	//--- Small samples
+
+	if v, cv = calcVar(returns[:20], 0.99); v != 0 || cv != 0 {
+		t.Errorf("Too few returns for the 99%% tail must give 0. Got %v and %v", v, cv)
+	}
+
+	if v, _ = calcVar(returns[:20], 0.95); v == 0 {
+		t.Errorf("20 returns are enough for the 95%% tail")
+	}
+}
+
+func TestPeriodsPerYear(t *testing.T) {
+	points := buildDataPoints(buildCloses(120, 100, func(i int) float64 { return float64(i%4) -1 }))
+
+	ap   := defaultParams()
+	list := createBarResults(points, ap)
+
+	daily := &DataProductAnalysisResponse{}
+	calcSummary(daily, points, list, ap)
+
+	if daily.PeriodsPerYear != TradingDaysPerYear {
+		t.Fatalf("Daily bars must default to the trading days. Got %v", daily.PeriodsPerYear)
+	}
+
+	ap.PeriodsPerYear = 365
+
+	crypto := &DataProductAnalysisResponse{}
+	calcSummary(crypto, points, list, ap)
+
+	ratio := math.Sqrt(365.0 / TradingDaysPerYear)
+
+	if math.Abs(crypto.Sharpe - daily.Sharpe * ratio) > 1e-9 {
+		t.Errorf("Sharpe must scale with the periods. Got %v, expected %v", crypto.Sharpe, daily.Sharpe * ratio)
+	}
+
+	if math.Abs(crypto.HistVol - daily.HistVol * ratio) > 1e-9 {
+		t.Errorf("Volatility must scale with the periods. Got %v, expected %v", crypto.HistVol, daily.HistVol * ratio)
+	}
+}
+
+//=============================================================================
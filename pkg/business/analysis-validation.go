@@ -0,0 +1,185 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/algotiqa/core/req"
+)
+
+//=============================================================================
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+//=============================================================================
+//--- Collects all problems of a spec, so that they can be reported at once
+
+type ValidationError struct {
+	Fields []*FieldError `json:"fields"`
+}
+
+//=============================================================================
+
+func (ve *ValidationError) Error() string {
+	var list []string
+
+	for _, fe := range ve.Fields {
+		list = append(list, "Bad '"+ fe.Field +"': "+ fe.Value +" ("+ fe.Message +")")
+	}
+
+	return strings.Join(list, "; ")
+}
+
+//=============================================================================
+//--- Also unwraps to a bad request, to be properly returned by the service
+
+func (ve *ValidationError) Unwrap() error {
+	return req.NewBadRequestError(ve.Error())
+}
+
+//=============================================================================
+//--- Keeps a validation error as it is, turning any other one into a bad request
+
+func asBadRequest(err error) error {
+	var ve *ValidationError
+	if errors.As(err, &ve) {
+		return err
+	}
+
+	return req.NewBadRequestError(err.Error())
+}
+
+//=============================================================================
+//--- Checks the spec without fetching any data
+
+func ValidateSpec(spec *DataProductAnalysisSpec) error {
+	verr := &ValidationError{}
+
+	ap, err := NewAnalysisParams(spec)
+	if err != nil {
+		var pe *ValidationError
+		if !errors.As(err, &pe) {
+			return err
+		}
+
+		verr.Fields = append(verr.Fields, pe.Fields...)
+	}
+
+	if spec.Config == nil || spec.Config.DataConfig == nil || spec.Config.DataConfig.Symbol == "" {
+		verr.add("symbol", "", errors.New("symbol is missing"))
+	}
+
+	daysBack, err := parseBackDays(spec.DaysBack)
+	verr.add("daysBack", spec.DaysBack, err)
+
+	_, err = parseReduction(spec.Reduction)
+	verr.add("reduction", spec.Reduction, err)
+
+	loc, err := specLocation(spec)
+	verr.add("timezone", spec.Timezone, err)
+
+	from, err := parseTime(spec.From, loc)
+	verr.add("from", spec.From, err)
+
+	to, err := parseTime(spec.To, loc)
+	verr.add("to", spec.To, err)
+
+	if from != nil && from.After(time.Now()) {
+		verr.add("from", spec.From, errors.New("range is in the future"))
+	}
+
+	if from != nil && to != nil && !from.Before(*to) {
+		verr.add("from", spec.From, errors.New("must be before 'to'"))
+	}
+
+	//--- Daily bars need enough trading days for the warm-up, unless it is fetched before
+	//--- the range. Intraday ones always have more bars
+
+	if ap != nil && ap.Timeframe == DefaultTimeframe && !ap.ExtendHistory {
+		available := 0
+		field     := ""
+		value     := ""
+
+		if from != nil && to != nil {
+			available, field, value = expectedTradingDays(from, to, loc), "from", spec.From
+		} else if from == nil && to == nil && daysBack > 0 {
+			available, field, value = daysBack * 5 / 7, "daysBack", spec.DaysBack
+		}
+
+		if field != "" && available -1 < ap.warmupBars() {
+			verr.add(field, value, errors.New("range is too short for the indicators' warm-up ("+ strconv.Itoa(ap.warmupBars() +1) +" bars required)"))
+		}
+	}
+
+	if verr.hasErrors() {
+		return verr
+	}
+
+	return nil
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func (ve *ValidationError) add(field string, value string, err error) {
+	if err != nil {
+		ve.Fields = append(ve.Fields, &FieldError{
+			Field  : field,
+			Value  : value,
+			Message: err.Error(),
+		})
+	}
+}
+
+//=============================================================================
+
+func (ve *ValidationError) hasErrors() bool {
+	return len(ve.Fields) > 0
+}
+
+//=============================================================================
+//--- Timezone the range is expressed in. Falls back to UTC when it cannot be
+//--- resolved, so that the range can still be checked
+
+func specLocation(spec *DataProductAnalysisSpec) (*time.Location, error) {
+	if spec.Config == nil || spec.Config.DataProduct == nil {
+		if spec.Timezone == "" || spec.Timezone == "exchange" {
+			return time.UTC, nil
+		}
+
+		loc, err := time.LoadLocation(spec.Timezone)
+		if err != nil {
+			return time.UTC, err
+		}
+
+		return loc, nil
+	}
+
+	loc, err := getLocation(spec.Timezone, spec.Config)
+	if err != nil {
+		return time.UTC, err
+	}
+
+	return loc, nil
+}
+
+//=============================================================================
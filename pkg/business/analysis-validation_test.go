@@ -0,0 +1,116 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+//=============================================================================
+
+func TestValidateSpec(t *testing.T) {
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			From  : "2023-01-02 00:00:00",
+			To    : "2024-12-31 00:00:00",
+			Config: buildQueryConfig(),
+		},
+	}
+
+	if err := ValidateSpec(spec); err != nil {
+		t.Fatalf("Spec must be valid. Got %v", err)
+	}
+
+	//--- All problems are reported at once
+
+	spec.SqnLen    = "5"
+	spec.AtrMethod = "exp"
+	spec.Timeframe = "7"
+	spec.From      = "2025-01-02 00:00:00"
+	spec.Config.DataConfig.Symbol = ""
+
+	err := ValidateSpec(spec)
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected a validation error. Got %v", err)
+	}
+
+	var fields []string
+	for _, fe := range verr.Fields {
+		fields = append(fields, fe.Field)
+	}
+
+	for _, f := range []string{ "timeframe", "sqnLen", "atrMethod", "symbol", "from" } {
+		if !slices.Contains(fields, f) {
+			t.Errorf("Field %q must be reported. Got %v", f, fields)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestValidateSpecWarmup(t *testing.T) {
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			DaysBack: "60",
+			Config  : buildQueryConfig(),
+		},
+	}
+
+	err := ValidateSpec(spec)
+
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 1 || verr.Fields[0].Field != "daysBack" {
+		t.Fatalf("A range shorter than the warm-up must be rejected. Got %v", err)
+	}
+
+	spec.DaysBack = "365"
+	if err = ValidateSpec(spec); err != nil {
+		t.Errorf("A year of data must be enough. Got %v", err)
+	}
+
+	//--- Intraday bars are not checked against the days
+
+	spec.DaysBack  = "60"
+	spec.Timeframe = "60"
+	if err = ValidateSpec(spec); err != nil {
+		t.Errorf("Intraday ranges must not be rejected. Got %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestValidateSpecTimezone(t *testing.T) {
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			From    : "2023-01-02 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "Europe/Rome",
+			Config  : buildQueryConfig(),
+		},
+	}
+
+	if err := ValidateSpec(spec); err != nil {
+		t.Fatalf("Spec must be valid. Got %v", err)
+	}
+
+	spec.Timezone = "Mars/Olympus"
+
+	var verr *ValidationError
+	if err := ValidateSpec(spec); !errors.As(err, &verr) || verr.Fields[0].Field != "timezone" {
+		t.Errorf("An unknown timezone must be reported. Got %v", err)
+	}
+}
+
+//=============================================================================
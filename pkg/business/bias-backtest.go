@@ -11,6 +11,7 @@
 package business
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -111,7 +112,7 @@ func RunBacktest(c *auth.Context, bbr *BiasBacktestResponse) error {
 
 	loc, _ := time.LoadLocation(bbr.config.DataProduct.Timezone)
 	da  := ds.NewSimpleAggregator(ds.NewQuantizer15mTo30m())
-	err := ds.GetDataPoints(nil, nil, bbr.config.DataConfig, loc, da, 0)
+	err := ds.GetDataPoints(context.Background(), nil, nil, bbr.config.DataConfig, loc, da, 0)
 
 	if err != nil {
 		c.Log.Error("RunBacktest: Could not retrieve data points", "error", err.Error())
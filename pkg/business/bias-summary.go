@@ -11,6 +11,7 @@
 package business
 
 import (
+	"context"
 	"time"
 
 	"github.com/algotiqa/core/auth"
@@ -145,7 +146,7 @@ func GetBiasSummaryData(c *auth.Context, spec *QuerySpec, bsr *BiasSummaryRespon
 	params.Reduction  = 0
 	params.Limit      = 0
 
-	dataPoints, err := getDataPoints(params, spec.Config)
+	dataPoints, err := getDataPoints(context.Background(), params, spec.Config)
 	if err != nil {
 		return err
 	}
@@ -11,6 +11,7 @@
 package business
 
 import (
+	"context"
 	"log/slog"
 	"math"
 	"time"
@@ -103,7 +104,7 @@ func GetDataInstrumentDataById(c *auth.Context, spec *QuerySpec) (*DataInstrumen
 	var dataPoints []*ds.DataPoint
 
 	start := time.Now()
-	dataPoints, err = getDataPoints(params, spec.Config)
+	dataPoints, err = getDataPoints(context.Background(), params, spec.Config)
 	durQ := time.Now().Sub(start).Seconds()
 	lenQ := len(dataPoints)
 	if err != nil {
@@ -211,6 +212,9 @@ func ReloadDataInstrumentData(tx *gorm.DB, c *auth.Context, id uint) (*db.Downlo
 //=== Private methods
 //===
 //=============================================================================
+//--- Down-samples the data points when they are more than 'reduction': consecutive
+//--- points are merged into one, keeping the first open, the last close, the
+//--- high/low range and the summed volumes. Merged points are changed in place
 
 func reduceDataPoints(dataPoints []*ds.DataPoint, reduction int) ([]*ds.DataPoint, bool) {
 	if reduction == 0 || len(dataPoints) <= reduction {
@@ -269,9 +273,9 @@ func calcDataRange(dataPoints []*ds.DataPoint) (string, string) {
 //===
 //=============================================================================
 
-func getDataPoints(params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+func getDataPoints(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
 	if !config.DataInstrument.VirtualInstrument {
-		err := ds.GetDataPoints(params.From, params.To, config.DataConfig, params.ProductLoc, params.Aggregator, params.Limit)
+		err := ds.GetDataPoints(ctx, params.From, params.To, config.DataConfig, params.ProductLoc, params.Aggregator, params.Limit)
 		return params.Aggregator.ToTimezone(params.TargetLoc).DataPoints(), err
 	}
 
@@ -290,13 +294,17 @@ func getDataPoints(params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoi
 	count   := 0
 
 	for i, c := range *chunks {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
 		to := &c.RolloverDate
 		if i == len(*chunks)-1 {
 			to = params.To
 		}
 
 		dconfig.Symbol = c.Symbol
-		err := ds.GetDataPoints(from, to, dconfig, params.ProductLoc, params.Aggregator, params.Limit)
+		err := ds.GetDataPoints(ctx, from, to, dconfig, params.ProductLoc, params.Aggregator, params.Limit)
 		if err != nil {
 			return nil, err
 		}
@@ -11,8 +11,12 @@
 package business
 
 import (
+	"context"
+	"errors"
 	"math"
+	"slices"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/algotiqa/core/auth"
@@ -39,109 +43,948 @@ const (
 	VolatilityVeryVolatile = 3
 )
 
+//--- Volatility cutoffs, expressed as number of ATR% standard deviations from the mean
+
+const (
+	VolatilityQuietStdDevs    = -0.5
+	VolatilityNormalStdDevs   =  0.5
+	VolatilityVolatileStdDevs =  3.0
+)
+
+const (
+	DefaultBatchWorkers = 4
+	MaxBatchWorkers     = 16
+)
+
+//--- Number of bars between two checks for the request's cancellation
+
+const CancelCheckInterval = 256
+
+//--- Minimum number of bars to run the indicator passes concurrently
+
+const ParallelIndicatorsMinBars = 2000
+
+//--- A warning is logged when the fetched data covers less than this fraction of the
+//--- trading days in the requested range
+
+const RawPointsWarningRatio = 0.5
+
+//=============================================================================
+//--- Returned when no bars are found at all, to tell it apart from a range that
+//--- is too short for the indicators' warm-up
+
+var ErrNoData = req.NewNotFoundError("no data found to analyze")
+
+//--- Returned when data points are out of order or have duplicate timestamps, unless
+//--- the spec asks to fix them
+
+var ErrUnorderedData = req.NewUnprocessableEntityError("data points are not sorted by time or have duplicate timestamps")
+
+//--- Matched by InsufficientHistoryError, which also tells how many bars are missing
+
+var ErrInsufficientHistory = errors.New("insufficient history")
+
+//=============================================================================
+
+type InsufficientHistoryError struct {
+	Bars     int
+	Required int
+}
+
+//=============================================================================
+
+func (e *InsufficientHistoryError) Error() string {
+	return "not enough data to analyze: "+ strconv.Itoa(e.Bars) +" bars found but indicators require "+ strconv.Itoa(e.Required) +
+		" ("+ strconv.Itoa(e.Required - e.Bars) +" missing)"
+}
+
+//=============================================================================
+//--- Also unwraps to a bad request, to be properly returned by the service
+
+func (e *InsufficientHistoryError) Unwrap() []error {
+	return []error{ ErrInsufficientHistory, req.NewBadRequestError(e.Error()) }
+}
+
+//=============================================================================
+//--- Stages of the analysis an error can come from
+
 const (
-	SqnLen = 100
+	StageValidate = "validate"
+	StageFetch    = "fetch"
+	StageCompute  = "compute"
 )
 
+//=============================================================================
+//--- Returned by AnalyzeProduct, so that a failure in a batch can be traced back to
+//--- its symbol and stage. The original error is still matched by errors.Is/As
+
+type AnalysisError struct {
+	Symbol string
+	Stage  string
+	Err    error
+}
+
+//=============================================================================
+
+func (e *AnalysisError) Error() string {
+	return e.Stage +" failed for '"+ e.Symbol +"': "+ e.Err.Error()
+}
+
+//=============================================================================
+
+func (e *AnalysisError) Unwrap() error {
+	return e.Err
+}
+
+//=============================================================================
+
+const (
+	DirectionStrongBearSqn = -1.47
+	DirectionBearSqn       = -0.74
+	DirectionBullSqn       =  0.74
+	DirectionStrongBullSqn =  1.47
+)
+
+//--- Version of the response's field set. Bump it whenever a field is added, removed or changed
+
+const AnalysisSchemaVersion = 60
+
 //=============================================================================
 
 type DataProductAnalysisResponse struct {
-	Id           uint          `json:"id"`
-	Symbol       string        `json:"symbol"`
-	From         types.Date    `json:"from"`
-	To           types.Date    `json:"to"`
-	Bars         int           `json:"bars"`
-	Timeframe    int           `json:"timeframe"`
-	AtrLength    int           `json:"atrLength"`
-	Limit        int           `json:"limit"`
-	Overflow     bool          `json:"overflow"`
-	BarResults   []*BarResult  `json:"barResults"`
+	SchemaVersion  int              `json:"schemaVersion"`
+	Id             uint             `json:"id"`
+	Symbol         string           `json:"symbol"`
+	From           types.Date       `json:"from"`
+	To             types.Date       `json:"to"`
+	RequestedFrom  types.Date       `json:"requestedFrom"`
+	RequestedTo    types.Date       `json:"requestedTo"`
+	Bars           int              `json:"bars"`
+	WarmupBars     int              `json:"warmupBars"`
+	TotalBars      int              `json:"totalBars"`
+	RawPoints      int              `json:"rawPoints"`
+	Timeframe      int              `json:"timeframe"`
+	SqnLength      int              `json:"sqnLength"`
+	SqnMethod      string           `json:"sqnMethod"`
+	SqnDecay       float64          `json:"sqnDecay"`
+	StdDevMode     string           `json:"stdDevMode"`
+	SqnSmoothing   int              `json:"sqnSmoothing"`
+	SqnSlopeLength int              `json:"sqnSlopeLength"`
+	DirMargin      float64          `json:"dirMargin"`
+	Indicators     string           `json:"indicators"`
+	AtrLength      int              `json:"atrLength"`
+	AtrMethod      string           `json:"atrMethod"`
+	ReturnMode     string           `json:"returnMode"`
+	PriceField     string           `json:"priceField"`
+	RsiLength      int              `json:"rsiLength"`
+	MaLength       int              `json:"maLength"`
+	MacdFast       int              `json:"macdFast"`
+	MacdSlow       int              `json:"macdSlow"`
+	MacdSignal     int              `json:"macdSignal"`
+	BollLength     int              `json:"bollLength"`
+	BollK          float64          `json:"bollK"`
+	KeltLength     int              `json:"keltLength"`
+	KeltK          float64          `json:"keltK"`
+	DonchLength    int              `json:"donchLength"`
+	AdxLength      int              `json:"adxLength"`
+	VwapLength     int              `json:"vwapLength"`
+	MfiLength      int              `json:"mfiLength"`
+	CmfLength      int              `json:"cmfLength"`
+	RocLength      int              `json:"rocLength"`
+	CciLength      int              `json:"cciLength"`
+	StochLength    int              `json:"stochLength"`
+	WillrLength    int              `json:"willrLength"`
+	TrixLength     int              `json:"trixLength"`
+	RegLength      int              `json:"regLength"`
+	SarStart       float64          `json:"sarStart"`
+	SarStep        float64          `json:"sarStep"`
+	SarMax         float64          `json:"sarMax"`
+	IchiTenkan     int              `json:"ichiTenkan"`
+	IchiKijun      int              `json:"ichiKijun"`
+	IchiSenkou     int              `json:"ichiSenkou"`
+	IncludePartial bool             `json:"includePartial"`
+	AtrSkipLocked  bool             `json:"atrSkipLocked"`
+	Tail           int              `json:"tail"`
+	Offset         int              `json:"offset"`
+	Order          string           `json:"order"`
+	Resampled      string           `json:"resampled,omitempty"`
+	Limit          int              `json:"limit"`
+	Overflow       bool             `json:"overflow"`
+	Reduction      int              `json:"reduction"`
+	Reduced        bool             `json:"reduced"`
+	Outliers       int              `json:"outliers"`
+	GapFill        bool             `json:"gapFill"`
+	ExtendHistory  bool             `json:"extendHistory"`
+	ChangeClamp    float64          `json:"changeClamp"`
+	Session        string           `json:"session"`
+	OffSession     int              `json:"offSession"`
+	Filled         int              `json:"filled"`
+	Winsorize      float64          `json:"winsorize"`
+	ZeroBaseline   string           `json:"zeroBaseline"`
+	Sanitized      int              `json:"sanitized"`
+	Checksum       string           `json:"checksum"`
+	Error          string           `json:"error,omitempty"`
+	Stage          string           `json:"stage,omitempty"`
+	RiskFreeRate   float64          `json:"riskFreeRate"`
+	PeriodsPerYear float64          `json:"periodsPerYear"`
+	Sharpe         float64          `json:"sharpe"`
+	SharpeLength   int              `json:"sharpeLength"`
+	Sortino        float64          `json:"sortino"`
+	HistVol        float64          `json:"histVol"`
+	MaxDrawdown    float64          `json:"maxDrawdown"`
+	DrawdownPeak   *time.Time       `json:"drawdownPeak,omitempty"`
+	DrawdownTrough *time.Time       `json:"drawdownTrough,omitempty"`
+	UpDays         int              `json:"upDays"`
+	DownDays       int              `json:"downDays"`
+	FlatDays       int              `json:"flatDays"`
+	AvgUpPerc      float64          `json:"avgUpPerc"`
+	AvgDownPerc    float64          `json:"avgDownPerc"`
+	Skewness       float64          `json:"skewness"`
+	Kurtosis       float64          `json:"kurtosis"`
+	Var95          float64          `json:"var95"`
+	Var99          float64          `json:"var99"`
+	Cvar95         float64          `json:"cvar95"`
+	Cvar99         float64          `json:"cvar99"`
+	AutoCorr       []float64        `json:"autoCorr,omitempty"`
+	Notes          []string         `json:"notes,omitempty"`
+	PeriodDeltas   *PeriodDeltas    `json:"periodDeltas,omitempty"`
+	BetaLength     int              `json:"betaLength"`
+	Benchmark      string           `json:"benchmark,omitempty"`
+	Gaps           []*BarGap        `json:"gaps,omitempty"`
+	RegimeChanges  []*RegimeSegment `json:"regimeChanges"`
+	BarResults     []*BarResult     `json:"barResults"`
+
+	//--- Leading bar results taken from a previous (already normalized) analysis
+	reused         int
 }
 
 //=============================================================================
 
 type BarResult struct {
 	Time          time.Time `json:"time"`
+	Open          float64   `json:"open"`
+	High          float64   `json:"high"`
+	Low           float64   `json:"low"`
 	Close         float64   `json:"close"`
+	Volume        int       `json:"volume"`
 	BarChangePerc float64   `json:"barChangePerc"`
+	RawChangePerc float64   `json:"rawChangePerc"`
 	TrueRange     float64   `json:"trueRange"`
+	PercTrueRange float64   `json:"percTrueRange"`
+	TypicalPrice  float64   `json:"typicalPrice"`
+	MedianPrice   float64   `json:"medianPrice"`
 	Sqn100        float64   `json:"sqn100"`
+	SqnPercentile float64   `json:"sqnPercentile"`
+	SqnSlope      float64   `json:"sqnSlope"`
 	Atr           float64   `json:"atr"`
 	AtrPerc       float64   `json:"atrPerc"`
 	AtrMeanPerc   float64   `json:"atrMeanPerc"`
 	AtrStdDevPerc float64   `json:"atrStdDevPerc"`
+	AnomalyScore  float64   `json:"anomalyScore"`
+	RollingSharpe float64   `json:"rollingSharpe"`
+	Rsi           float64   `json:"rsi"`
+	Sma           float64   `json:"sma"`
+	Ema           float64   `json:"ema"`
+	MacdLine      float64   `json:"macdLine"`
+	MacdSignal    float64   `json:"macdSignal"`
+	MacdHist      float64   `json:"macdHist"`
+	BollUpper     float64   `json:"bollUpper"`
+	BollMiddle    float64   `json:"bollMiddle"`
+	BollLower     float64   `json:"bollLower"`
+	KeltUpper     float64   `json:"keltUpper"`
+	KeltMiddle    float64   `json:"keltMiddle"`
+	KeltLower     float64   `json:"keltLower"`
+	DonchianHigh  float64   `json:"donchianHigh"`
+	DonchianLow   float64   `json:"donchianLow"`
+	Adx14         float64   `json:"adx14"`
+	Adxr          float64   `json:"adxr"`
+	PlusDi        float64   `json:"plusDi"`
+	MinusDi       float64   `json:"minusDi"`
+	Vwap          float64   `json:"vwap"`
+	Mfi14         float64   `json:"mfi14"`
+	Cmf20         float64   `json:"cmf20"`
+	Obv           float64   `json:"obv"`
+	Roc12         float64   `json:"roc12"`
+	Momentum12    float64   `json:"momentum12"`
+	Cci20         float64   `json:"cci20"`
+	Pivot         float64   `json:"pivot"`
+	PivotR1       float64   `json:"pivotR1"`
+	PivotR2       float64   `json:"pivotR2"`
+	PivotR3       float64   `json:"pivotR3"`
+	PivotS1       float64   `json:"pivotS1"`
+	PivotS2       float64   `json:"pivotS2"`
+	PivotS3       float64   `json:"pivotS3"`
+	StochK        float64   `json:"stochK"`
+	StochD        float64   `json:"stochD"`
+	WilliamsR     float64   `json:"williamsR"`
+	Trix15        float64   `json:"trix15"`
+	RegSlope      float64   `json:"regSlope"`
+	RegR2         float64   `json:"regR2"`
+	Sar           float64   `json:"sar"`
+	Tenkan        float64   `json:"tenkan"`
+	Kijun         float64   `json:"kijun"`
+	SenkouA       float64   `json:"senkouA"`
+	SenkouB       float64   `json:"senkouB"`
+	Chikou        float64   `json:"chikou"`
+	Beta          float64   `json:"beta"`
+	RelStrength   float64   `json:"relStrength"`
 	Direction     int       `json:"direction"`
 	Volatility    int       `json:"volatility"`
+	Partial       bool      `json:"partial"`
+	Locked        bool      `json:"locked"`
+	ZeroBaseline  bool      `json:"zeroBaseline"`
+	Synthetic     bool      `json:"synthetic"`
+
+	point         *ds.DataPoint
+	prevPoint     *ds.DataPoint
+
+	//--- Prices selected by the spec's price field, fed to the returns and the indicators
+	price         float64
+	prevPrice     float64
+
+	//--- Return fed to the statistics: the bar change, winsorized when requested
+	statsReturn   float64
 }
 
 //=============================================================================
+//--- The response is always built in full: the indicators are computed in passes over
+//--- the whole series, so no bar is final before the last pass and the results can't
+//--- be streamed as they are computed
+
+func AnalyzeProduct(c *auth.Context, spec *DataProductAnalysisSpec) (*DataProductAnalysisResponse, error) {
+	//--- Save symbol as it is changed by getDataPoints to loop over the instruments
+	symbol := specSymbol(spec)
 
-func AnalyzeProduct(c *auth.Context, spec *QuerySpec, atrLen string) (*DataProductAnalysisResponse, error) {
-	params, err := NewQueryParams(spec)
+	if err := ValidateSpec(spec); err != nil {
+		return nil, &AnalysisError{ Symbol: symbol, Stage: StageValidate, Err: asBadRequest(err) }
+	}
+
+	ap, err := NewAnalysisParams(spec)
 	if err != nil {
-		return nil, req.NewBadRequestError(err.Error())
+		return nil, &AnalysisError{ Symbol: symbol, Stage: StageValidate, Err: asBadRequest(err) }
 	}
 
-	atr,err := parseAtrLen(atrLen)
+	//--- The timeframe is optional for the analysis and defaults to daily bars
+
+	qs := spec.QuerySpec
+	qs.Timeframe = strconv.Itoa(ap.fetchTimeframe())
+
+	params, err := NewQueryParams(&qs)
 	if err != nil {
-		return nil, req.NewBadRequestError(err.Error())
+		return nil, &AnalysisError{ Symbol: symbol, Stage: StageValidate, Err: req.NewBadRequestError(err.Error()) }
 	}
 
-	//--- Save symbol as it is changed by getDataPoints to loop over the instruments
-	symbol := spec.Config.DataConfig.Symbol
+	//--- The warm-up bars are fetched before the requested range and removed later
+
+	requestedFrom := params.From
+
+	if ap.ExtendHistory && params.From != nil {
+		from := params.From.AddDate(0, 0, -historyDays(ap, spec.Config.TradingSession, ap.warmupBars() +1))
+		params.From    = &from
+		ap.summaryFrom = requestedFrom
+	}
 
-	dataPoints, err := getDataPoints(params, spec.Config)
+	ctx := requestContext(c)
+
+	ap.Progress.report(ProgressFetch, 0)
+
+	dataPoints, err := spec.dataSource().Fetch(ctx, params, spec.Config)
 	if err != nil {
-		return nil, err
+		return nil, &AnalysisError{ Symbol: symbol, Stage: StageFetch, Err: err }
 	}
 
-	initialResults := createBarResults(dataPoints, atr)
-	barResults     := calcSqnAndAtr(initialResults)
+	ap.Progress.report(ProgressFetch, progressFetchEnd)
 
-	res := &DataProductAnalysisResponse{
-		Id        : spec.Id,
-		Symbol    : symbol,
-		From      : types.ToDate(params.From),
-		To        : types.ToDate(params.To),
-		Bars      : len(barResults),
-		Timeframe : params.Timeframe,
-		Limit     : params.Limit,
-		Overflow  : params.Limit > 0 && len(barResults) >= params.Limit,
-		AtrLength : atr,
-		BarResults: barResults,
+	dataPoints, err = checkDataOrder(dataPoints, ap.FixOrder)
+	if err != nil {
+		return nil, &AnalysisError{ Symbol: symbol, Stage: StageFetch, Err: err }
+	}
+
+	dataPoints = trimAfterAsOf(dataPoints, params.AsOf)
+
+	dataPoints, err = adjustDataPoints(dataPoints, spec.Actions)
+	if err != nil {
+		return nil, &AnalysisError{ Symbol: symbol, Stage: StageFetch, Err: err }
+	}
+
+	dataPoints, offSession := filterSession(dataPoints, ap, params.TargetLoc)
+
+	dataPoints, outliers := filterOutliers(dataPoints, ap.OutlierSigma, ap.OutlierMode)
+
+	if days, expected := tradingDays(dataPoints), expectedTradingDays(params.From, params.To, params.TargetLoc); isDataMissing(days, expected) {
+		c.Log.Warn("AnalyzeProduct: Fetched data covers fewer days than expected",
+			"id", spec.Id, "symbol", symbol, "rawPoints", len(dataPoints), "days", days, "expectedDays", expected)
+	}
+
+	//--- Filled bars are flagged once the results are computed
+
+	var filled map[*ds.DataPoint]bool
+	if ap.GapFill {
+		dataPoints, filled = fillGaps(dataPoints, ap.fetchTimeframe(), ap.Calendar)
+	}
+
+	dataPoints = aggregateWeekly(dataPoints, ap, params.CloseFunc)
+
+	rawPoints := len(dataPoints)
+
+	reduced := false
+	dataPoints, reduced = reduceDataPoints(dataPoints, params.Reduction)
+
+	//--- Reused results could have been computed against another benchmark or
+	//--- already post-processed
+
+	prev := spec.Previous
+	if prev != nil && (prev.Id != spec.Id || prev.Symbol != symbol || spec.Benchmark != nil || spec.PostProcess != nil) {
+		prev = nil
+	}
+
+	res, err := analyzeDataPoints(ctx, dataPoints, ap, prev)
+	if err != nil {
+		return nil, &AnalysisError{ Symbol: symbol, Stage: StageCompute, Err: err }
+	}
+
+	res.Id         = spec.Id
+	res.Symbol     = symbol
+	res.RawPoints  = rawPoints
+	res.Limit      = params.Limit
+	res.Overflow   = params.Limit > 0 && res.Bars >= params.Limit
+	res.Reduction  = params.Reduction
+	res.Reduced    = reduced
+	res.Outliers   = outliers
+	res.OffSession = offSession
+	res.GapFill    = ap.GapFill
+	res.Filled     = len(filled)
+
+	for _, br := range res.BarResults[res.reused:] {
+		br.Synthetic = filled[br.point]
+	}
+
+	if ap.ExtendHistory && requestedFrom != nil {
+		trimExtendedHistory(res, *requestedFrom)
 	}
 
+	//--- From and To are the range actually covered: the first data point only provides
+	//--- the previous close and the leading days may have no data
+
+	res.RequestedFrom = types.ToDate(requestedFrom)
+	res.RequestedTo   = types.ToDate(params.To)
+	res.From, res.To  = barsRange(res.BarResults)
+
+	if spec.Benchmark != nil {
+		err = addBeta(ctx, res, dataPoints, spec.Benchmark, ap)
+		if err != nil {
+			return nil, &AnalysisError{ Symbol: symbol, Stage: StageCompute, Err: err }
+		}
+	}
+
+	if ap.ComparePrior {
+		err = addPeriodDeltas(ctx, res, spec, params, requestedFrom, dataPoints, ap)
+		if err != nil {
+			return nil, &AnalysisError{ Symbol: symbol, Stage: StageCompute, Err: err }
+		}
+	}
+
+	if spec.PostProcess != nil {
+		if err = spec.PostProcess(res.BarResults); err != nil {
+			return nil, &AnalysisError{ Symbol: symbol, Stage: StageCompute, Err: err }
+		}
+	}
+
+	//--- Non finite values must be replaced before normalizing: truncation would turn them into bogus numbers
+
+	res.Sanitized = sanitizeBarResults(res.BarResults, ap.NonFiniteValue)
+
+	//--- The clamp is only for display: all the indicators use the raw change
+
+	clampBarChanges(res.BarResults[res.reused:], ap.ClampPercChange / 100)
+
 	normalizeValues(res)
 
+	//--- Bars keeps counting all the results, so that the client can paginate
+
+	res.BarResults = pageBarResults(res.BarResults, ap.Tail, ap.Offset)
+
+	if ap.Order == OrderDesc {
+		slices.Reverse(res.BarResults)
+	}
+
+	res.Checksum = calcChecksum(res)
+
+	ap.Progress.report(ProgressDone, 100)
+
 	return res, nil
 }
 
+//=============================================================================
+//--- Products are analyzed concurrently. A failing product doesn't stop the batch:
+//--- its response carries only the id, the symbol and the error. Workers default to
+//--- DefaultBatchWorkers when not positive
+
+func AnalyzeProducts(c *auth.Context, specs []*DataProductAnalysisSpec, workers int) []*DataProductAnalysisResponse {
+	if workers <= 0 {
+		workers = DefaultBatchWorkers
+	}
+
+	return runAnalysisBatch(specs, workers, func(spec *DataProductAnalysisSpec) (*DataProductAnalysisResponse, error) {
+		return AnalyzeProduct(c, spec)
+	})
+}
+
 //=============================================================================
 //===
 //=== Private functions
 //===
 //=============================================================================
 
-func parseAtrLen(atrLen string) (int, error) {
-	if atrLen == "" {
-		return 20,nil
+func runAnalysisBatch(specs []*DataProductAnalysisSpec, workers int,
+					analyze func(spec *DataProductAnalysisSpec) (*DataProductAnalysisResponse, error)) []*DataProductAnalysisResponse {
+	results := make([]*DataProductAnalysisResponse, len(specs))
+	indexes := make(chan int)
+
+	workers = max(min(workers, MaxBatchWorkers, len(specs)), 1)
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range indexes {
+				spec     := specs[i]
+				res, err := analyze(spec)
+
+				if err != nil {
+					res = &DataProductAnalysisResponse{
+						Id    : spec.Id,
+						Symbol: specSymbol(spec),
+						Error : err.Error(),
+					}
+
+					var ae *AnalysisError
+					if errors.As(err, &ae) {
+						res.Stage = ae.Stage
+					}
+				}
+
+				results[i] = res
+			}
+		}()
+	}
+
+	for i := range specs {
+		indexes <- i
+	}
+
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+//=============================================================================
+//--- Bars consumed by the indicators' warm-up (including the first one, used only
+//--- for its close) are not returned: Bars + WarmupBars = TotalBars
+
+func analyzeDataPoints(ctx context.Context, dataPoints []*ds.DataPoint, ap *AnalysisParams, prev *DataProductAnalysisResponse) (*DataProductAnalysisResponse, error) {
+	err := checkEnoughData(dataPoints, ap)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	val,err := strconv.Atoi(atrLen)
+	initialResults := createBarResults(dataPoints, ap)
+
+	reused := reusableResults(prev, initialResults, ap)
+
+	barResults, err := calcSqnAndAtr(ctx, initialResults, ap, reused)
 	if err != nil {
-		return 0, req.NewBadRequestError("parameter 'atrLen' must be a number: "+ atrLen)
+		return nil, err
 	}
 
-	if val < 5 || val > 50 {
-		return 0, req.NewBadRequestError("parameter 'atrLen' must be between 5 and 50")
+	res := &DataProductAnalysisResponse{
+		SchemaVersion: AnalysisSchemaVersion,
+		Bars         : len(barResults),
+		WarmupBars   : len(dataPoints) - len(barResults),
+		TotalBars    : len(dataPoints),
+		Timeframe    : ap.Timeframe,
+		SqnLength    : ap.SqnLen,
+		SqnMethod    : ap.SqnMethod,
+		SqnDecay     : ap.SqnDecay,
+		StdDevMode   : ap.StdDevMode,
+		SqnSmoothing : ap.ReturnSmoothing,
+		DirMargin    : ap.DirectionMargin,
+		Indicators   : ap.Indicators.String(),
+		AtrLength    : ap.AtrLen,
+		AtrMethod    : ap.AtrMethod,
+		ReturnMode   : ap.ReturnMode,
+		PriceField   : ap.PriceField,
+		RsiLength    : ap.RsiLen,
+		MaLength     : ap.MaLen,
+		MacdFast     : ap.MacdFast,
+		MacdSlow     : ap.MacdSlow,
+		MacdSignal   : ap.MacdSignal,
+		BollLength   : ap.BollLen,
+		BollK        : ap.BollK,
+		KeltLength   : ap.KeltLen,
+		KeltK        : ap.KeltK,
+		DonchLength  : ap.DonchLen,
+		AdxLength    : ap.AdxLen,
+		VwapLength   : ap.VwapLen,
+		MfiLength    : ap.MfiLen,
+		CmfLength    : ap.CmfLen,
+		RocLength    : ap.RocLen,
+		CciLength    : ap.CciLen,
+		StochLength  : ap.StochLen,
+		WillrLength  : ap.WillrLen,
+		TrixLength   : ap.TrixLen,
+		RegLength    : ap.RegLen,
+		SarStart     : ap.SarStart,
+		SarStep      : ap.SarStep,
+		SarMax       : ap.SarMax,
+		IchiTenkan   : ap.IchiTenkan,
+		IchiKijun    : ap.IchiKijun,
+		IchiSenkou   : ap.IchiSenkou,
+		BarResults   : barResults,
+
+		IncludePartial: ap.IncludePartial,
+		AtrSkipLocked : ap.AtrSkipLocked,
+		Tail          : ap.Tail,
+		Offset        : ap.Offset,
+		Order         : ap.Order,
+		Winsorize     : ap.Winsorize,
+		ZeroBaseline  : ap.ZeroBaseline,
+		SqnSlopeLength: ap.SqnSlopeLen,
+		ExtendHistory : ap.ExtendHistory,
+		ChangeClamp   : ap.ClampPercChange,
+		Session       : ap.session(),
+		reused       : len(reused),
 	}
 
-	return val, nil
+	calcSummary(res, dataPoints, initialResults, ap)
+
+	ap.Progress.report(ProgressSummary, progressSummaryEnd)
+
+	return res, nil
 }
 
 //=============================================================================
+//--- Returns the previous bar results when they can be reused as they are: the
+//--- analysis must use the same parameters and its bars (warm-up included) must be
+//--- a prefix of the new ones, otherwise the rolling windows would differ
+
+func reusableResults(prev *DataProductAnalysisResponse, list []*BarResult, ap *AnalysisParams) []*BarResult {
+	if prev == nil || prev.SchemaVersion != AnalysisSchemaVersion || !sameAnalysisParams(prev, ap) {
+		return nil
+	}
+
+	start := ap.firstBar()
+
+	//--- Winsorization limits depend on the whole series, so they change as it grows
+
+	if ap.Winsorize > 0 {
+		return nil
+	}
+
+	//--- A paged or reversed response doesn't hold the results as they are computed
+
+	if prev.WarmupBars != start +1 || len(prev.BarResults) == 0 || len(prev.BarResults) != prev.Bars || prev.Order == OrderDesc || len(prev.BarResults) > len(list) - start {
+		return nil
+	}
+
+	for k, br := range prev.BarResults {
+		curr := list[start + k]
+		if !curr.Time.Equal(br.Time) || curr.Close != br.Close {
+			return nil
+		}
+	}
+
+	return prev.BarResults
+}
+
+//=============================================================================
+//--- Keeps the last 'tail' results before the 'offset' most recent ones. A zero
+//--- tail keeps all of them
+
+func pageBarResults(list []*BarResult, tail int, offset int) []*BarResult {
+	end   := max(len(list) - offset, 0)
+	start := 0
+
+	if tail > 0 {
+		start = max(end - tail, 0)
+	}
 
-func createBarResults(dataPoints []*ds.DataPoint, atrLen int) []*BarResult {
+	return list[start:end]
+}
+
+//=============================================================================
+
+func sameAnalysisParams(prev *DataProductAnalysisResponse, ap *AnalysisParams) bool {
+	return prev.Timeframe   == ap.Timeframe  &&
+		prev.SqnLength   == ap.SqnLen     &&
+		prev.SqnMethod   == ap.SqnMethod  &&
+		prev.SqnDecay    == ap.SqnDecay   &&
+		prev.StdDevMode  == ap.StdDevMode &&
+		prev.AtrLength   == ap.AtrLen     &&
+		prev.AtrMethod   == ap.AtrMethod  &&
+		prev.ReturnMode  == ap.ReturnMode &&
+		prev.PriceField  == ap.PriceField &&
+		prev.RsiLength   == ap.RsiLen     &&
+		prev.MaLength    == ap.MaLen      &&
+		prev.MacdFast    == ap.MacdFast   &&
+		prev.MacdSlow    == ap.MacdSlow   &&
+		prev.MacdSignal  == ap.MacdSignal &&
+		prev.BollLength  == ap.BollLen    &&
+		prev.BollK       == ap.BollK      &&
+		prev.KeltLength  == ap.KeltLen    &&
+		prev.KeltK       == ap.KeltK      &&
+		prev.DonchLength == ap.DonchLen   &&
+		prev.AdxLength   == ap.AdxLen     &&
+		prev.VwapLength  == ap.VwapLen    &&
+		prev.MfiLength   == ap.MfiLen     &&
+		prev.CmfLength   == ap.CmfLen     &&
+		prev.RocLength   == ap.RocLen     &&
+		prev.CciLength   == ap.CciLen     &&
+		prev.StochLength == ap.StochLen   &&
+		prev.WillrLength == ap.WillrLen   &&
+		prev.TrixLength  == ap.TrixLen    &&
+		prev.RegLength   == ap.RegLen     &&
+		prev.SarStart    == ap.SarStart   &&
+		prev.SarStep     == ap.SarStep    &&
+		prev.SarMax      == ap.SarMax     &&
+		prev.IchiTenkan  == ap.IchiTenkan &&
+		prev.IchiKijun   == ap.IchiKijun  &&
+		prev.IchiSenkou  == ap.IchiSenkou &&
+		prev.SqnSmoothing   == ap.ReturnSmoothing &&
+		prev.Winsorize      == ap.Winsorize &&
+		prev.Indicators     == ap.Indicators.String() &&
+		prev.ZeroBaseline   == ap.ZeroBaseline &&
+		prev.DirMargin      == ap.DirectionMargin &&
+		prev.GapFill        == ap.GapFill &&
+		prev.SharpeLength   == ap.SharpeLen &&
+		prev.SqnSlopeLength == ap.SqnSlopeLen &&
+		prev.ChangeClamp    == ap.ClampPercChange &&
+		prev.Session        == ap.session() &&
+		prev.IncludePartial == ap.IncludePartial &&
+		prev.ExtendHistory  == ap.ExtendHistory &&
+		prev.AtrSkipLocked  == ap.AtrSkipLocked
+}
+
+//=============================================================================
+//--- Weekdays in the requested range. Returns 0 (unknown) when the range is open.
+//--- Days start at midnight in the given location
+
+func expectedTradingDays(from *time.Time, to *time.Time, loc *time.Location) int {
+	if from == nil || to == nil {
+		return 0
+	}
+
+	start := from.In(loc)
+	days  := 0
+
+	for d := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc); !d.After(*to); d = d.AddDate(0, 0, 1) {
+		if d.Weekday() != time.Saturday && d.Weekday() != time.Sunday {
+			days++
+		}
+	}
+
+	return days
+}
+
+//=============================================================================
+
+func tradingDays(dataPoints []*ds.DataPoint) int {
+	days := map[time.Time]bool{}
+
+	for _, dp := range dataPoints {
+		y, m, d := dp.Time.Date()
+		days[time.Date(y, m, d, 0, 0, 0, 0, time.UTC)] = true
+	}
+
+	return len(days)
+}
+
+//=============================================================================
+
+func isDataMissing(days int, expected int) bool {
+	return expected > 0 && float64(days) < float64(expected) * RawPointsWarningRatio
+}
+
+//=============================================================================
+
+func addBeta(ctx context.Context, res *DataProductAnalysisResponse, dataPoints []*ds.DataPoint, benchSpec *DataProductAnalysisSpec, ap *AnalysisParams) error {
+	benchSymbol := benchSpec.Config.DataConfig.Symbol
+
+	benchPoints, benchAp, err := fetchSpecDataPoints(ctx, benchSpec)
+	if err != nil {
+		return err
+	}
+
+	calcBeta(res.BarResults, barReturns(dataPoints, ap), barReturns(benchPoints, benchAp), ap.BetaLen, ap.Timeframe)
+	calcRelStrength(res.BarResults, benchPoints, ap.PriceField, ap.RelStrengthFill, ap.Timeframe)
+
+	res.BetaLength = ap.BetaLen
+	res.Benchmark  = benchSymbol
+
+	return nil
+}
+
+//=============================================================================
+//--- Weekly analyses fetch daily bars: they are merged here into weeks
+
+func aggregateWeekly(dataPoints []*ds.DataPoint, ap *AnalysisParams, closeFunc ds.CloseFunc) []*ds.DataPoint {
+	if ap.Timeframe != WeeklyTimeframe {
+		return dataPoints
+	}
+
+	da := ds.NewWeeklyAggregator()
+	da.SetCloseFunc(closeFunc)
+
+	for _, dp := range dataPoints {
+		da.Add(dp)
+	}
+
+	da.Flush()
+
+	return da.DataPoints()
+}
+
+//=============================================================================
+
+func requestContext(c *auth.Context) context.Context {
+	if c != nil && c.Gin != nil && c.Gin.Request != nil {
+		return c.Gin.Request.Context()
+	}
+
+	return context.Background()
+}
+
+//=============================================================================
+
+func checkEnoughData(dataPoints []*ds.DataPoint, ap *AnalysisParams) error {
+	if len(dataPoints) == 0 {
+		return ErrNoData
+	}
+
+	//--- The first data point is only used as the previous close of the second one
+
+	bars     := len(dataPoints) -1
+	required := ap.firstBar() +1
+
+	if bars < required {
+		return &InsufficientHistoryError{
+			Bars    : max(bars, 0),
+			Required: required,
+		}
+	}
+
+	return nil
+}
+
+//=============================================================================
+//--- Moves the bars before the requested range, fetched only for the warm-up, to the
+//--- warm-up ones. The summary already skips them (see AnalysisParams.summaryFrom).
+//--- Reused results come first, so the trimmed ones are no longer counted
+
+func trimExtendedHistory(res *DataProductAnalysisResponse, from time.Time) {
+	n := 0
+	for n < len(res.BarResults) && res.BarResults[n].Time.Before(from) {
+		n++
+	}
+
+	res.BarResults  = res.BarResults[n:]
+	res.Bars       -= n
+	res.WarmupBars += n
+	res.reused      = max(res.reused - n, 0)
+}
+
+//=============================================================================
+//--- Keeps the bar change in the raw one and caps it at ±limit (no cap when 0)
+
+func clampBarChanges(list []*BarResult, limit float64) {
+	for _, br := range list {
+		br.RawChangePerc = br.BarChangePerc
+
+		if limit > 0 {
+			br.BarChangePerc = min(max(br.BarChangePerc, -limit), limit)
+		}
+	}
+}
+
+//=============================================================================
+//--- The symbol is validated later: it can be missing here
+
+func specSymbol(spec *DataProductAnalysisSpec) string {
+	if spec.Config == nil || spec.Config.DataConfig == nil {
+		return ""
+	}
+
+	return spec.Config.DataConfig.Symbol
+}
+
+//=============================================================================
+//--- Returns the data points as they are when their times strictly increase. Otherwise
+//--- fails or, when fixing, sorts them keeping the last of the points with the same time
+
+func checkDataOrder(dataPoints []*ds.DataPoint, fix bool) ([]*ds.DataPoint, error) {
+	ordered := true
+
+	for i := 1; i < len(dataPoints) && ordered; i++ {
+		ordered = dataPoints[i-1].Time.Before(dataPoints[i].Time)
+	}
+
+	if ordered {
+		return dataPoints, nil
+	}
+
+	if !fix {
+		return nil, ErrUnorderedData
+	}
+
+	list := slices.Clone(dataPoints)
+	slices.SortStableFunc(list, func(a, b *ds.DataPoint) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	var res []*ds.DataPoint
+
+	for _, dp := range list {
+		if n := len(res); n > 0 && res[n-1].Time.Equal(dp.Time) {
+			res[n-1] = dp
+		} else {
+			res = append(res, dp)
+		}
+	}
+
+	return res, nil
+}
+
+//=============================================================================
+//--- Sources are not required to honour the range: with a reference date, any data
+//--- point after it is dropped so that the analysis cannot look ahead
+
+func trimAfterAsOf(dataPoints []*ds.DataPoint, asOf *time.Time) []*ds.DataPoint {
+	if asOf == nil {
+		return dataPoints
+	}
+
+	for i, dp := range dataPoints {
+		if dp.Time.After(*asOf) {
+			return dataPoints[:i]
+		}
+	}
+
+	return dataPoints
+}
+
+//=============================================================================
+//--- Bars traded at a single price (halted or limit-locked markets) are flagged, like
+//--- the ones with a zero previous price when requested
+
+func createBarResults(dataPoints []*ds.DataPoint, ap *AnalysisParams) []*BarResult {
 	if len(dataPoints) == 0 {
 		return nil
 	}
@@ -153,25 +996,79 @@ func createBarResults(dataPoints []*ds.DataPoint, atrLen int) []*BarResult {
 			tr := calcTrueRange(dp, dataPoints[i-1])
 			dr := &BarResult{
 				Time         : dp.Time,
+				Open         : dp.Open,
+				High         : dp.High,
+				Low          : dp.Low,
 				Close        : dp.Close,
+				Volume       : dp.UpVolume + dp.DownVolume,
 				BarChangePerc: 0,
 				TrueRange    : tr,
+				TypicalPrice : typicalPrice(dp),
+				MedianPrice  : medianPrice(dp),
+				Locked       : dp.High == dp.Low,
+				point        : dp,
+				prevPoint    : dataPoints[i-1],
+				price        : priceOf(dp, ap.PriceField),
+				prevPrice    : priceOf(dataPoints[i-1], ap.PriceField),
 			}
 
-			prevClose := dataPoints[i-1].Close
+			dr.BarChangePerc = calcReturn(dr.price, dr.prevPrice, ap.ReturnMode)
 
-			if prevClose != 0 {
-				dr.BarChangePerc = (dp.Close - prevClose)/prevClose
+			//--- With no baseline there is no change: it may be a data error, so the
+			//--- bar can be dropped or flagged
+
+			if dr.prevPrice == 0 {
+				if ap.ZeroBaseline == ZeroBaselineSkip {
+					continue
+				}
+
+				dr.ZeroBaseline = ap.ZeroBaseline == ZeroBaselineFlag
+			}
+
+			//--- Comparable across price levels, unlike the absolute true range
+
+			if prevClose := dataPoints[i-1].Close; prevClose != 0 {
+				dr.PercTrueRange = tr / prevClose
 			}
 
 			results = append(results, dr)
-			calcAtr(results, atrLen)
+
+			if ap.computes(IndicatorAtr) {
+				calcAtr(results, ap)
+				calcAnomalyScore(results, ap.AtrLen)
+			}
+
+			if ap.computes(IndicatorDonch) {
+				calcDonchian(results, ap.DonchLen)
+			}
 		}
 	}
 
+	winsorizeReturns(results, ap.Winsorize)
+
 	return results
 }
 
+//=============================================================================
+//--- Simple or log return between two closes. Logs are undefined on non-positive
+//--- prices, so those bars have no change
+
+func calcReturn(close float64, prevClose float64, mode string) float64 {
+	if mode == ReturnModeLog {
+		if close <= 0 || prevClose <= 0 {
+			return 0
+		}
+
+		return math.Log(close / prevClose)
+	}
+
+	if prevClose == 0 {
+		return 0
+	}
+
+	return (close - prevClose)/prevClose
+}
+
 //=============================================================================
 
 func calcTrueRange(curr *ds.DataPoint, prev *ds.DataPoint) float64 {
@@ -183,95 +1080,410 @@ func calcTrueRange(curr *ds.DataPoint, prev *ds.DataPoint) float64 {
 }
 
 //=============================================================================
+//--- When locked bars are skipped, a bar with no other bar to average keeps the
+//--- previous ATR
 
-func calcAtr(list []*BarResult, atrLen int) {
+func calcAtr(list []*BarResult, ap *AnalysisParams) {
 	end  := len(list) -1
 	last := list[end]
 
+	skip := func(br *BarResult) bool {
+		return ap.AtrSkipLocked && br.Locked
+	}
+
 	if end == 0 {
 		last.Atr = last.TrueRange
+	} else if ap.AtrMethod == AtrMethodWilder && end >= ap.AtrLen {
+		//--- Wilder's smoothing, seeded by the simple average of the first atrLen true ranges
+
+		n := float64(ap.AtrLen)
+		last.Atr = list[end-1].Atr
+
+		if !skip(last) {
+			last.Atr = (last.Atr * (n-1) + last.TrueRange) / n
+		}
 	} else {
-		start := end - atrLen +1
+		start := end - ap.AtrLen +1
 		if start < 0 {
 			start = 0
 		}
 
-		sum := 0.0
+		sum   := 0.0
+		count := 0
 
 		for i := start; i <= end; i++ {
-			sum += list[i].TrueRange
+			if !skip(list[i]) {
+				sum += list[i].TrueRange
+				count++
+			}
 		}
 
-		last.Atr = sum / float64(end-start+1)
+		last.Atr = list[end-1].Atr
+
+		if count > 0 {
+			last.Atr = sum / float64(count)
+		}
 	}
 
-	if last.Close != 0 {
-		last.AtrPerc = last.Atr / last.Close
+	if last.price != 0 {
+		last.AtrPerc = last.Atr / last.price
 	}
 }
 
 //=============================================================================
+//--- Z-score of the last true range against the ones of the preceding atrLen bars.
+//--- Only flags unusual bars: nothing is filtered out
+
+func calcAnomalyScore(list []*BarResult, atrLen int) {
+	end := len(list) -1
+	if end < atrLen {
+		return
+	}
+
+	mean, stdDev := calcMeanAndStdDev(list, end-1, atrLen, func(br *BarResult) float64 {
+		return br.TrueRange
+	})
+
+	if stdDev > 0 {
+		list[end].AnomalyScore = (list[end].TrueRange - mean) / stdDev
+	}
+}
+
+//=============================================================================
+//--- Highest high and lowest low of the last donchLen bars
+
+func calcDonchian(list []*BarResult, donchLen int) {
+	end := len(list) -1
+	if end < donchLen-1 {
+		return
+	}
+
+	last := list[end]
+	last.DonchianHigh, last.DonchianLow = windowHighLow(list[end - max(donchLen, 1) +1:])
+}
+
+//=============================================================================
+//--- Highest high and lowest low of the bars in the window
+
+func windowHighLow(window []*BarResult) (float64, float64) {
+	high := window[0].point.High
+	low  := window[0].point.Low
+
+	for _, br := range window[1:] {
+		high = max(high, br.point.High)
+		low  = min(low,  br.point.Low)
+	}
+
+	return high, low
+}
+
+//=============================================================================
+//--- Reused results replace the first returned bars, skipping their window calculations.
+//--- Long series run the indicator passes concurrently
+
+func calcSqnAndAtr(ctx context.Context, list []*BarResult, ap *AnalysisParams, reused []*BarResult) ([]*BarResult, error) {
+	return calcBarIndicators(ctx, list, ap, reused, len(list) >= ParallelIndicatorsMinBars)
+}
+
+//=============================================================================
+//--- Each pass writes its own fields and only reads the ones set by createBarResults,
+//--- so passes can safely run at the same time on the shared list
+
+func calcBarIndicators(ctx context.Context, list []*BarResult, ap *AnalysisParams, reused []*BarResult, parallel bool) ([]*BarResult, error) {
+	warmup := ap.warmupBars()
+	first  := ap.firstBar()
+
+	//--- With smoothing or winsorization, SQN runs on copies of the bars holding the
+	//--- processed returns, so that the reported change stays raw
+
+	sqnList := sqnBars(list, ap)
+
+	//--- Windows run in order, so the direction can depend on the previous one. After
+	//--- the reused bars, it continues from the last of them
+
+	prevDir, hasPrev := DirectionNeutral, false
+
+	//--- The SQN slope of the first new bar needs the SQN of the reused bars before it,
+	//--- so their windows are computed again
+
+	sqnSkipTo := max(first, first + len(reused) - ap.SqnSlopeLen +1)
+
+	sqnWindow := func(_ []*BarResult, i int, sqnLen int) {
+		if ap.SqnMethod == SqnMethodEwma {
+			calcEwmaSqnWindow(sqnList, i, sqnLen, ap.SqnDecay)
+		} else {
+			calcSqnWindow(sqnList, i, sqnLen, ap.StdDevMode == StdDevModeSample)
+		}
+
+		if len(reused) > 0 && i == first + len(reused) {
+			prevDir, hasPrev = reused[len(reused)-1].Direction, true
+		}
+
+		if ap.DirectionMargin > 0 && hasPrev {
+			sqnList[i].Direction = calcDirectionWithMargin(sqnList[i].Sqn100, prevDir, ap.DirectionMargin)
+		}
+
+		list[i].Sqn100, list[i].Direction = sqnList[i].Sqn100, sqnList[i].Direction
+		prevDir, hasPrev = list[i].Direction, true
+	}
+
+	all := []struct {
+		flag IndicatorSet
+		pass func() error
+	}{
+		{ IndicatorRsi,    func() error { calcRsi(list, ap.RsiLen);                                return nil } },
+		{ IndicatorMa,     func() error { calcMovingAverages(list, ap.MaLen);                      return nil } },
+		{ IndicatorMacd,   func() error { calcMacd(list, ap.MacdFast, ap.MacdSlow, ap.MacdSignal); return nil } },
+		{ IndicatorBoll,   func() error { calcBollinger(list, ap.BollLen, ap.BollK);               return nil } },
+		{ IndicatorKelt,   func() error { calcKeltner(list, ap.KeltLen, ap.KeltK);                 return nil } },
+		{ IndicatorAdx,    func() error { calcAdx(list, ap.AdxLen);                                return nil } },
+		{ IndicatorVwap,   func() error { calcVwap(list, ap.VwapLen);                              return nil } },
+		{ IndicatorMfi,    func() error { calcMfi(list, ap.MfiLen);                                return nil } },
+		{ IndicatorCmf,    func() error { calcCmf(list, ap.CmfLen);                                return nil } },
+		{ IndicatorRoc,    func() error { calcRoc(list, ap.RocLen);                                return nil } },
+		{ IndicatorCci,    func() error { calcCci(list, ap.CciLen);                                return nil } },
+		{ IndicatorPivots, func() error { calcPivots(list);                                        return nil } },
+		{ IndicatorStoch,  func() error { calcStochastic(list, ap.StochLen);                       return nil } },
+		{ IndicatorWillr,  func() error { calcWilliamsR(list, ap.WillrLen);                        return nil } },
+		{ IndicatorTrix,   func() error { calcTrix(list, ap.TrixLen);                              return nil } },
+		{ IndicatorReg,    func() error { calcLinReg(list, ap.RegLen);                             return nil } },
+		{ IndicatorSar,    func() error { calcSar(list, ap.SarStart, ap.SarStep, ap.SarMax);       return nil } },
+		{ IndicatorIchi,   func() error { calcIchimoku(list, ap.IchiTenkan, ap.IchiKijun, ap.IchiSenkou); return nil } },
+		{ IndicatorObv,    func() error { calcObv(list, first);                                    return nil } },
+		{ IndicatorSqn,    func() error { return calcWindows(ctx, list, ap.SqnLen, ap.IncludePartial, first, sqnSkipTo, sqnWindow) } },
+		{ IndicatorAtr,    func() error { return calcWindows(ctx, list, ap.SqnLen, ap.IncludePartial, first, first + len(reused), calcAtrWindow) } },
+	}
+
+	//--- Unselected indicators are skipped, leaving their fields at zero
+
+	var passes []func() error
+
+	for _, p := range all {
+		if ap.computes(p.flag) {
+			passes = append(passes, p.pass)
+		}
+	}
+
+	if err := runPasses(ap.Progress.trackPasses(passes), parallel); err != nil {
+		return nil, err
+	}
+
+	if ap.computes(IndicatorSqn) {
+		sqnStart := ap.SqnLen -1
+		if ap.IncludePartial {
+			sqnStart = 0
+		}
+
+		calcSqnSlope(list, sqnStart, ap.SqnSlopeLen)
+	}
+
+	//--- Bars are returned only when all indicators are available, unless partial
+	//--- results are requested: in that case warm-up bars are flagged
 
-func calcSqnAndAtr(list []*BarResult) []*BarResult {
 	var result []*BarResult
 
-	for i, dr := range list {
-		if i >= SqnLen-1 {
-			dr.Sqn100 = calcSqn(list, i-SqnLen +1, i)
+	for i := first; i < len(list); i++ {
+		if k := i - first; k < len(reused) {
+			br := *reused[k]
 
-			atrMean, atrDev := calcAtrMeanAndStdDev(list, i-SqnLen +1, i)
-			dr.AtrMeanPerc   = atrMean
-			dr.AtrStdDevPerc = atrDev
-			dr.Direction     = calcDirection(dr.Sqn100)
-			dr.Volatility    = calcVolatility(dr.AtrPerc, atrMean, atrDev)
+			//--- The lagging line looks ahead: it may be known now but not before. Reused
+			//--- bars are not normalized again, so the value is copied as normalizeValues does
+			br.Chikou = core.Trunc4d(list[i].Chikou)
 
-			result = append(result, dr)
+			result = append(result, &br)
+		} else {
+			list[i].Partial = i < warmup-1
+			result = append(result, list[i])
 		}
 	}
 
-	return result
+	return result, nil
 }
 
 //=============================================================================
+//--- Runs calc on each bar having a full window or, when partial is set, on the
+//--- leading bars too using all the bars available so far. Bars in [skipFrom, skipTo)
+//--- are going to be replaced by reused results
+
+func calcWindows(ctx context.Context, list []*BarResult, sqnLen int, partial bool, skipFrom int, skipTo int,
+				calc func(list []*BarResult, i int, sqnLen int)) error {
+	for i := range list {
+		if i % CancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+		}
 
-func calcSqn(list []*BarResult, start int, end int) float64 {
-	//--- Calc mean
+		if (i < sqnLen-1 && !partial) || (i >= skipFrom && i < skipTo) {
+			continue
+		}
 
-	sum := 0.0
+		calc(list, i, min(i+1, sqnLen))
+	}
 
-	for i:=start; i<=end; i++ {
-		sum += list[i].BarChangePerc
+	return nil
+}
+
+//=============================================================================
+
+func calcSqnWindow(list []*BarResult, i int, sqnLen int, sample bool) {
+	dr := list[i]
+	dr.Sqn100    = calcSqn(list, i, sqnLen, sample)
+	dr.Direction = calcDirection(dr.Sqn100)
+}
+
+//=============================================================================
+//--- Copies of the bars with the change replaced by the (winsorized) statistics
+//--- return, smoothed by its EMA over the smoothing bars seeded by the first return.
+//--- Returns the list itself when returns are not processed
+
+func sqnBars(list []*BarResult, ap *AnalysisParams) []*BarResult {
+	if (ap.ReturnSmoothing == 0 && ap.Winsorize == 0) || len(list) == 0 {
+		return list
 	}
 
-	mean := sum / float64(SqnLen)
+	alpha := 1.0
+	if n := ap.ReturnSmoothing; n > 0 {
+		alpha = 2 / float64(n+1)
+	}
 
-	//--- Calc stdDev
+	res    := make([]*BarResult, len(list))
+	smooth := list[0].statsReturn
 
-	sum   = 0.0
-	diff := 0.0
+	for i, br := range list {
+		if i > 0 {
+			smooth += alpha * (br.statsReturn - smooth)
+		}
+
+		dr := *br
+		dr.BarChangePerc = smooth
+		res[i] = &dr
+	}
+
+	return res
+}
+
+//=============================================================================
+//--- Like the simple SQN, but returns are weighted by decay^age, so that recent
+//--- bars count more and regime changes show up sooner
 
-	for i := start; i <= end; i++ {
-		diff = list[i].BarChangePerc - mean
-		sum += diff * diff
+func calcEwmaSqnWindow(list []*BarResult, i int, sqnLen int, decay float64) {
+	sumW, mean := 0.0, 0.0
+	weight     := 1.0
+
+	for j := i; j > i - sqnLen; j-- {
+		mean   += weight * list[j].BarChangePerc
+		sumW   += weight
+		weight *= decay
 	}
 
-	stdDev := math.Sqrt(sum / float64(SqnLen))
+	mean /= sumW
+
+	variance := 0.0
+	weight    = 1.0
+
+	for j := i; j > i - sqnLen; j-- {
+		diff     := list[j].BarChangePerc - mean
+		variance += weight * diff * diff
+		weight   *= decay
+	}
 
-	return mean * math.Sqrt(SqnLen) / stdDev
+	variance /= sumW
+
+	dr := list[i]
+	dr.Sqn100 = 0
+
+	if variance > 0 {
+		dr.Sqn100 = mean * math.Sqrt(float64(sqnLen)) / math.Sqrt(variance)
+	}
+
+	dr.Direction = calcDirection(dr.Sqn100)
+}
+
+//=============================================================================
+
+func calcAtrWindow(list []*BarResult, i int, sqnLen int) {
+	atrMean, atrDev := calcMeanAndStdDev(list, i, sqnLen, func(br *BarResult) float64 {
+		return br.AtrPerc
+	})
+
+	dr := list[i]
+	dr.AtrMeanPerc   = atrMean
+	dr.AtrStdDevPerc = atrDev
+	dr.Volatility    = calcVolatility(dr.AtrPerc, atrMean, atrDev)
 }
 
 //=============================================================================
+//--- On error, the one of the first failing pass (in list order) is returned
+
+func runPasses(passes []func() error, parallel bool) error {
+	errs := make([]error, len(passes))
+
+	if parallel {
+		var wg sync.WaitGroup
+
+		for i, pass := range passes {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs[i] = pass()
+			}()
+		}
+
+		wg.Wait()
+	} else {
+		for i, pass := range passes {
+			errs[i] = pass()
+		}
+	}
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//=============================================================================
+//--- The standard deviation is the population one (divided by N) unless sample
+//--- is set (divided by N-1)
+
+func calcSqn(list []*BarResult, end int, sqnLen int, sample bool) float64 {
+	mean, stdDev := calcMeanAndStdDev(list, end, sqnLen, func(br *BarResult) float64 {
+		return br.BarChangePerc
+	})
+
+	if sample && sqnLen > 1 {
+		n := float64(sqnLen)
+		stdDev *= math.Sqrt(n / (n-1))
+	}
+
+	//--- A flat series has no dispersion: SQN is undefined, so we consider it neutral
+
+	if stdDev == 0 {
+		return 0
+	}
+
+	return mean * math.Sqrt(float64(sqnLen)) / stdDev
+}
+
+//=============================================================================
+//--- Population mean and standard deviation of a field over the 'length' bars ending at 'end'
+
+func calcMeanAndStdDev(list []*BarResult, end int, length int, value func(br *BarResult) float64) (float64, float64) {
+	start := end - length +1
 
-func calcAtrMeanAndStdDev(list []*BarResult, start int, end int) (float64, float64) {
 	//--- Calc mean
 
 	sum := 0.0
 
 	for i:=start; i<=end; i++ {
-		sum += list[i].AtrPerc
+		sum += value(list[i])
 	}
 
-	mean := sum / float64(SqnLen)
+	mean := sum / float64(length)
 
 	//--- Calc stdDev
 
@@ -279,11 +1491,11 @@ func calcAtrMeanAndStdDev(list []*BarResult, start int, end int) (float64, float
 	diff := 0.0
 
 	for i:=start; i<=end; i++ {
-		diff = list[i].AtrPerc - mean
+		diff = value(list[i]) - mean
 		sum += diff*diff
 	}
 
-	stdDev := math.Sqrt(sum/float64(SqnLen))
+	stdDev := math.Sqrt(sum/float64(length))
 
 	return mean, stdDev
 }
@@ -291,28 +1503,52 @@ func calcAtrMeanAndStdDev(list []*BarResult, start int, end int) (float64, float
 //=============================================================================
 
 func calcDirection(sqn float64) int {
-	if sqn < -1.47 {
+	if sqn < DirectionStrongBearSqn {
 		return DirectionStrongBear
 	}
-	if sqn < -0.74 {
+	if sqn < DirectionBearSqn {
 		return DirectionBear
 	}
-	if sqn < 0.74 {
+	if sqn < DirectionBullSqn {
 		return DirectionNeutral
 	}
-	if sqn < 1.47 {
+	if sqn < DirectionStrongBullSqn {
 		return DirectionBull
 	}
 
 	return DirectionStrongBull
 }
 
+//=============================================================================
+//--- Hysteresis: the direction only changes when SQN goes past a threshold by more
+//--- than the margin, otherwise the previous one is held
+
+func calcDirectionWithMargin(sqn float64, prev int, margin float64) int {
+	dir := calcDirection(sqn)
+
+	if dir > prev {
+		return max(prev, calcDirection(sqn - margin))
+	}
+
+	if dir < prev {
+		return min(prev, calcDirection(sqn + margin))
+	}
+
+	return dir
+}
+
 //=============================================================================
 
 func calcVolatility(percAtr float64, mean float64, std float64) int {
-	if percAtr < mean - std/2 { return VolatilityQuiet    }
-	if percAtr < mean + std/2 { return VolatilityNormal   }
-	if percAtr < mean + std*3 { return VolatilityVolatile }
+	//--- A constant ATR has no dispersion (apart from rounding errors): nothing is unusual
+
+	if std < 1e-12 {
+		return VolatilityNormal
+	}
+
+	if percAtr < mean + std*VolatilityQuietStdDevs    { return VolatilityQuiet    }
+	if percAtr < mean + std*VolatilityNormalStdDevs   { return VolatilityNormal   }
+	if percAtr < mean + std*VolatilityVolatileStdDevs { return VolatilityVolatile }
 
 	return VolatilityVeryVolatile
 }
@@ -320,13 +1556,104 @@ func calcVolatility(percAtr float64, mean float64, std float64) int {
 //=============================================================================
 
 func normalizeValues(res *DataProductAnalysisResponse) {
-	for _, dr := range res.BarResults {
+	res.RiskFreeRate = core.Trunc2d(res.RiskFreeRate * 100)
+	res.Sharpe       = core.Trunc2d(res.Sharpe)
+	res.Sortino      = core.Trunc2d(res.Sortino)
+	res.HistVol      = core.Trunc4d(res.HistVol)
+	res.MaxDrawdown  = core.Trunc4d(res.MaxDrawdown)
+	res.AvgUpPerc    = core.Trunc2d(res.AvgUpPerc   * 100)
+	res.AvgDownPerc  = core.Trunc2d(res.AvgDownPerc * 100)
+	res.Skewness     = core.Trunc4d(res.Skewness)
+	res.Kurtosis     = core.Trunc4d(res.Kurtosis)
+	res.Var95        = core.Trunc4d(res.Var95)
+	res.Var99        = core.Trunc4d(res.Var99)
+	res.Cvar95       = core.Trunc4d(res.Cvar95)
+	res.Cvar99       = core.Trunc4d(res.Cvar99)
+
+	for i, ac := range res.AutoCorr {
+		res.AutoCorr[i] = core.Trunc4d(ac)
+	}
+
+	res.PeriodsPerYear = core.Trunc2d(res.PeriodsPerYear)
+
+	if pd := res.PeriodDeltas; pd != nil {
+		for _, ps := range []*PeriodStats{ &pd.Current, &pd.Prior, &pd.Delta } {
+			ps.Return      = core.Trunc4d(ps.Return)
+			ps.HistVol     = core.Trunc4d(ps.HistVol)
+			ps.MaxDrawdown = core.Trunc4d(ps.MaxDrawdown)
+		}
+	}
+
+	//--- Reused results have already been normalized
+
+	for _, dr := range res.BarResults[res.reused:] {
 		dr.BarChangePerc = core.Trunc2d(dr.BarChangePerc * 100)
+		dr.RawChangePerc = core.Trunc2d(dr.RawChangePerc * 100)
 		dr.Sqn100        = core.Trunc2d(dr.Sqn100)
+		dr.SqnSlope      = core.Trunc4d(dr.SqnSlope)
+		dr.PercTrueRange = core.Trunc2d(dr.PercTrueRange * 100)
+		dr.TypicalPrice  = core.Trunc4d(dr.TypicalPrice)
+		dr.MedianPrice   = core.Trunc4d(dr.MedianPrice)
 		dr.Atr           = core.Trunc4d(dr.Atr)
 		dr.AtrPerc       = core.Trunc2d(dr.AtrPerc       * 100)
 		dr.AtrMeanPerc   = core.Trunc2d(dr.AtrMeanPerc   * 100)
 		dr.AtrStdDevPerc = core.Trunc4d(dr.AtrStdDevPerc * 100)
+		dr.AnomalyScore  = core.Trunc2d(dr.AnomalyScore)
+		dr.RollingSharpe = core.Trunc4d(dr.RollingSharpe)
+		dr.Rsi           = core.Trunc2d(dr.Rsi)
+		dr.Sma           = core.Trunc4d(dr.Sma)
+		dr.Ema           = core.Trunc4d(dr.Ema)
+		dr.MacdLine      = core.Trunc4d(dr.MacdLine)
+		dr.MacdSignal    = core.Trunc4d(dr.MacdSignal)
+		dr.MacdHist      = core.Trunc4d(dr.MacdHist)
+		dr.BollUpper     = core.Trunc4d(dr.BollUpper)
+		dr.BollMiddle    = core.Trunc4d(dr.BollMiddle)
+		dr.BollLower     = core.Trunc4d(dr.BollLower)
+		dr.KeltUpper     = core.Trunc4d(dr.KeltUpper)
+		dr.KeltMiddle    = core.Trunc4d(dr.KeltMiddle)
+		dr.KeltLower     = core.Trunc4d(dr.KeltLower)
+		dr.DonchianHigh  = core.Trunc4d(dr.DonchianHigh)
+		dr.DonchianLow   = core.Trunc4d(dr.DonchianLow)
+		dr.Adx14         = core.Trunc2d(dr.Adx14)
+		dr.Adxr          = core.Trunc2d(dr.Adxr)
+		dr.PlusDi        = core.Trunc2d(dr.PlusDi)
+		dr.MinusDi       = core.Trunc2d(dr.MinusDi)
+		dr.Vwap          = core.Trunc4d(dr.Vwap)
+		dr.Mfi14         = core.Trunc2d(dr.Mfi14)
+		dr.Cmf20         = core.Trunc4d(dr.Cmf20)
+		dr.Roc12         = core.Trunc2d(dr.Roc12 * 100)
+		dr.Momentum12    = core.Trunc4d(dr.Momentum12)
+		dr.Cci20         = core.Trunc2d(dr.Cci20)
+		dr.Pivot         = core.Trunc4d(dr.Pivot)
+		dr.PivotR1       = core.Trunc4d(dr.PivotR1)
+		dr.PivotR2       = core.Trunc4d(dr.PivotR2)
+		dr.PivotR3       = core.Trunc4d(dr.PivotR3)
+		dr.PivotS1       = core.Trunc4d(dr.PivotS1)
+		dr.PivotS2       = core.Trunc4d(dr.PivotS2)
+		dr.PivotS3       = core.Trunc4d(dr.PivotS3)
+		dr.StochK        = core.Trunc2d(dr.StochK)
+		dr.StochD        = core.Trunc2d(dr.StochD)
+		dr.WilliamsR     = core.Trunc2d(dr.WilliamsR)
+		dr.Trix15        = core.Trunc4d(dr.Trix15 * 100)
+		dr.RegSlope      = core.Trunc4d(dr.RegSlope)
+		dr.RegR2         = core.Trunc4d(dr.RegR2)
+		dr.Sar           = core.Trunc4d(dr.Sar)
+		dr.Tenkan        = core.Trunc4d(dr.Tenkan)
+		dr.Kijun         = core.Trunc4d(dr.Kijun)
+		dr.SenkouA       = core.Trunc4d(dr.SenkouA)
+		dr.SenkouB       = core.Trunc4d(dr.SenkouB)
+		dr.Chikou        = core.Trunc4d(dr.Chikou)
+		dr.Beta          = core.Trunc4d(dr.Beta)
+		dr.RelStrength   = core.Trunc4d(dr.RelStrength)
+	}
+
+	//--- Percentiles need the whole series of SQN values. Using the normalized ones
+	//--- keeps the ranking consistent when previous results are reused
+
+	calcSqnPercentiles(res.BarResults)
+
+	for _, dr := range res.BarResults {
+		dr.SqnPercentile = core.Trunc2d(dr.SqnPercentile)
 	}
 }
 
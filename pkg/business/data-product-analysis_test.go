@@ -0,0 +1,2007 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/core/req"
+	"github.com/algotiqa/data-collector/pkg/core"
+	"github.com/algotiqa/data-collector/pkg/db"
+	"github.com/algotiqa/data-collector/pkg/ds"
+	"github.com/algotiqa/types"
+)
+
+//=============================================================================
+
+var startTime = time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC)
+
+const sessionConfig = `{ "slots": [
+	{ "day":0, "open": 1700, "close": 1600, "end": true },
+	{ "day":1, "open": 1700, "close": 1600, "end": true },
+	{ "day":2, "open": 1700, "close": 1600, "end": true },
+	{ "day":3, "open": 1700, "close": 1600, "end": true },
+	{ "day":4, "open": 1700, "close": 1600, "end": true }
+]}`
+
+//=============================================================================
+
+func buildQueryConfig() *core.QueryConfig {
+	session, err := types.NewTradingSession(sessionConfig)
+	if err != nil {
+		panic(err)
+	}
+
+	di := &db.DataInstrument{ Symbol: "ES" }
+	dp := &db.DataProduct   { Symbol: "ES", SystemCode: "TS", Timezone: "UTC" }
+
+	return core.NewQueryConfig(di, dp, nil, session)
+}
+
+//=============================================================================
+
+func buildDataPoints(closes []float64) []*ds.DataPoint {
+	var list []*ds.DataPoint
+
+	for i, c := range closes {
+		list = append(list, &ds.DataPoint{
+			Time : startTime.Add(time.Hour * 24 * time.Duration(i)),
+			Open : c,
+			High : c + 1,
+			Low  : c - 1,
+			Close: c,
+		})
+	}
+
+	return list
+}
+
+//=============================================================================
+
+func buildCloses(count int, start float64, step func(i int) float64) []float64 {
+	var list []float64
+
+	value := start
+
+	for i := 0; i < count; i++ {
+		list  = append(list, value)
+		value = value + step(i)
+	}
+
+	return list
+}
+
+//=============================================================================
+
+func defaultParams() *AnalysisParams {
+	ap, err := NewAnalysisParams(&DataProductAnalysisSpec{})
+	if err != nil {
+		panic(err)
+	}
+
+	return ap
+}
+
+//=============================================================================
+
+func analyze(points []*ds.DataPoint, ap *AnalysisParams) []*BarResult {
+	list, err := calcSqnAndAtr(context.Background(), createBarResults(points, ap), ap, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	return list
+}
+
+//=============================================================================
+
+func TestDirectionBoundaries(t *testing.T) {
+	cases := []struct {
+		sqn float64
+		exp int
+	}{
+		{ -2.00                 , DirectionStrongBear },
+		{ DirectionStrongBearSqn, DirectionBear       },
+		{ DirectionBearSqn      , DirectionNeutral    },
+		{ 0                     , DirectionNeutral    },
+		{ DirectionBullSqn      , DirectionBull       },
+		{ DirectionStrongBullSqn, DirectionStrongBull },
+		{ 3.00                  , DirectionStrongBull },
+	}
+
+	for _, c := range cases {
+		if dir := calcDirection(c.sqn); dir != c.exp {
+			t.Errorf("Bad direction for sqn=%v. Expected %v but got %v", c.sqn, c.exp, dir)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestDirectionFlatSeries(t *testing.T) {
+	closes  := buildCloses(DefaultSqnLen +10, 100, func(i int) float64 { return 0 })
+	results := analyze(buildDataPoints(closes), defaultParams())
+
+	if len(results) == 0 {
+		t.Fatal("No results for a flat series")
+	}
+
+	for _, br := range results {
+		if br.Sqn100 != 0 || br.Direction != DirectionNeutral {
+			t.Fatalf("Flat series must be neutral. Got sqn=%v, direction=%v", br.Sqn100, br.Direction)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestDirectionTrendingSeries(t *testing.T) {
+	closes  := buildCloses(DefaultSqnLen +10, 100, func(i int) float64 { return 1 + float64(i%3) })
+	results := analyze(buildDataPoints(closes), defaultParams())
+	last    := results[len(results)-1]
+
+	if last.Direction != DirectionStrongBull {
+		t.Errorf("Uptrend must be strong bull. Got sqn=%v, direction=%v", last.Sqn100, last.Direction)
+	}
+}
+
+//=============================================================================
+
+func TestVolatilityLowVolSeries(t *testing.T) {
+	closes  := buildCloses(DefaultSqnLen +30, 100, func(i int) float64 { return 0 })
+	results := analyze(buildDataPoints(closes), defaultParams())
+
+	for _, br := range results {
+		if br.Volatility != VolatilityNormal {
+			t.Fatalf("Constant ATR must be normal volatility. Got %v at %v", br.Volatility, br.Time)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestVolatilitySpikingSeries(t *testing.T) {
+	closes := buildCloses(DefaultSqnLen +30, 100, func(i int) float64 { return 0 })
+	points := buildDataPoints(closes)
+
+	//--- Widen the last bars to make the ATR spike
+
+	for i := len(points) -5; i < len(points); i++ {
+		points[i].High += 15
+		points[i].Low  -= 15
+	}
+
+	ap := defaultParams()
+	ap.AtrLen = 5
+
+	results := analyze(points, ap)
+	last    := results[len(results)-1]
+
+	if last.Volatility != VolatilityVeryVolatile {
+		t.Errorf("ATR spike must be very volatile. Got %v", last.Volatility)
+	}
+
+	//--- Before the spike, ATR is steady
+
+	if results[0].Volatility != VolatilityNormal {
+		t.Errorf("Steady ATR must be normal volatility. Got %v", results[0].Volatility)
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisParamsLengths(t *testing.T) {
+	ap, err := NewAnalysisParams(&DataProductAnalysisSpec{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ap.SqnLen != DefaultSqnLen || ap.AtrLen != DefaultAtrLen {
+		t.Errorf("Bad default lengths. Got sqnLen=%v, atrLen=%v", ap.SqnLen, ap.AtrLen)
+	}
+
+	ap, err = NewAnalysisParams(&DataProductAnalysisSpec{ SqnLen: "50", AtrLen: "14" })
+	if err != nil || ap.SqnLen != 50 || ap.AtrLen != 14 {
+		t.Errorf("Bad custom lengths. Got %+v (%v)", ap, err)
+	}
+
+	for _, spec := range []*DataProductAnalysisSpec{ {SqnLen: "0"}, {SqnLen: "-5"}, {AtrLen: "abc"}, {AtrLen: "1000"} } {
+		if _, err = NewAnalysisParams(spec); err == nil {
+			t.Errorf("Invalid lengths must be rejected: %+v", spec)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisParamsFactors(t *testing.T) {
+	ap, err := NewAnalysisParams(&DataProductAnalysisSpec{ BollK: "2.5" })
+	if err != nil || ap.BollK != 2.5 {
+		t.Errorf("Bad custom factor. Got %v (%v)", ap.BollK, err)
+	}
+
+	for _, value := range []string{ "NaN", "nan", "Inf", "+Inf", "-Inf", "abc", "100" } {
+		if _, err = NewAnalysisParams(&DataProductAnalysisSpec{ BollK: value }); err == nil {
+			t.Errorf("Invalid factor must be rejected: %v", value)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestSqnLengthAndHistory(t *testing.T) {
+	ap := defaultParams()
+	ap.SqnLen    = 50
+	ap.SharpeLen = 20
+
+	points := buildDataPoints(buildCloses(70, 100, func(i int) float64 { return 1 }))
+
+	if err := checkEnoughData(points, ap); err != nil {
+		t.Fatal(err)
+	}
+
+	//--- The SQN slope needs SqnSlopeLen SQN values
+
+	results := analyze(points, ap)
+	if exp := 70 - ap.SqnLen - ap.SqnSlopeLen +1; len(results) != exp {
+		t.Errorf("Bad number of results. Expected %v but got %v", exp, len(results))
+	}
+
+	if results[0].SqnSlope == 0 {
+		t.Errorf("The first returned bar must have its SQN slope")
+	}
+
+	ap.SqnLen = 100
+	if err := checkEnoughData(points, ap); err == nil {
+		t.Error("Not enough data must be reported")
+	}
+}
+
+//=============================================================================
+
+func TestAtrMethods(t *testing.T) {
+	trueRanges := []float64{ 1, 2, 3, 4, 5, 6 }
+
+	cases := []struct {
+		method string
+		exp    []float64
+	}{
+		{ AtrMethodSimple, []float64{ 1, 1.5, 2, 3     , 4     , 5      } },
+		{ AtrMethodWilder, []float64{ 1, 1.5, 2, 2.6667, 3.4444, 4.2963 } },
+	}
+
+	for _, c := range cases {
+		ap := &AnalysisParams{ AtrLen: 3, AtrMethod: c.method }
+
+		var list []*BarResult
+
+		for i, tr := range trueRanges {
+			list = append(list, &BarResult{ Close: 100, TrueRange: tr })
+			calcAtr(list, ap)
+
+			if math.Abs(list[i].Atr - c.exp[i]) > 0.0001 {
+				t.Errorf("Bad %v ATR at %v. Expected %v but got %v", c.method, i, c.exp[i], list[i].Atr)
+			}
+		}
+	}
+}
+
+//=============================================================================
+
+func TestRawAtrAndPercAtr(t *testing.T) {
+	points  := buildDataPoints(buildCloses(30, 100, func(i int) float64 { return float64(i%4) -1 }))
+	results := createBarResults(points, &AnalysisParams{ AtrLen: 5, AtrMethod: AtrMethodSimple })
+
+	for _, br := range results {
+		if br.Close != 0 && math.Abs(br.AtrPerc - br.Atr/br.Close) > 1e-12 {
+			t.Fatalf("ATR%% must be the raw ATR divided by the close. Got atr=%v, atrPerc=%v, close=%v", br.Atr, br.AtrPerc, br.Close)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisTimeframe(t *testing.T) {
+	cases := []struct {
+		timeframe string
+		expTf     int
+		expAggreg string
+	}{
+		{ ""    , 1440, "1440m" },
+		{ "60"  ,   60, "60m"   },
+		{ "1440", 1440, "1440m" },
+	}
+
+	for _, c := range cases {
+		spec := &DataProductAnalysisSpec{ QuerySpec: QuerySpec{ Timeframe: c.timeframe, Config: buildQueryConfig() } }
+
+		ap, err := NewAnalysisParams(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ap.Timeframe != c.expTf {
+			t.Errorf("Bad timeframe for '%v'. Expected %v but got %v", c.timeframe, c.expTf, ap.Timeframe)
+		}
+
+		spec.Timeframe = strconv.Itoa(ap.Timeframe)
+
+		params, err := NewQueryParams(&spec.QuerySpec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if tf := params.Aggregator.TargetTimeframe(); tf != c.expAggreg {
+			t.Errorf("Bad aggregator timeframe for '%v'. Expected %v but got %v", c.timeframe, c.expAggreg, tf)
+		}
+	}
+
+	for _, tf := range []string{ "7", "2880", "1h", "-60" } {
+		if _, err := NewAnalysisParams(&DataProductAnalysisSpec{ QuerySpec: QuerySpec{ Timeframe: tf } }); err == nil {
+			t.Errorf("Unsupported timeframe must be rejected: %v", tf)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestWarmupBars(t *testing.T) {
+	points := buildDataPoints(buildCloses(120, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	res, err := analyzeDataPoints(context.Background(), points, defaultParams(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	warmup := DefaultSqnLen + DefaultSqnSlopeLen -1
+
+	if res.TotalBars != 120 || res.Bars != 120 - warmup || res.WarmupBars != warmup {
+		t.Errorf("Bad bar counts. Got bars=%v, warmup=%v, total=%v", res.Bars, res.WarmupBars, res.TotalBars)
+	}
+
+	if res.Bars + res.WarmupBars != res.TotalBars {
+		t.Error("Usable and warm-up bars must add up to the total")
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisBatch(t *testing.T) {
+	var specs []*DataProductAnalysisSpec
+
+	for i := 1; i <= 10; i++ {
+		specs = append(specs, &DataProductAnalysisSpec{ QuerySpec: QuerySpec{ Id: uint(i) } })
+	}
+
+	results := runAnalysisBatch(specs, 3, func(spec *DataProductAnalysisSpec) (*DataProductAnalysisResponse, error) {
+		if spec.Id % 2 == 0 {
+			return nil, errors.New("no data")
+		}
+
+		return &DataProductAnalysisResponse{ Id: spec.Id, Bars: 10 }, nil
+	})
+
+	if len(results) != len(specs) {
+		t.Fatalf("Bad number of results. Expected %v but got %v", len(specs), len(results))
+	}
+
+	for i, res := range results {
+		if res.Id != specs[i].Id {
+			t.Errorf("Results must follow the specs order. Expected %v but got %v", specs[i].Id, res.Id)
+		}
+
+		failed := res.Id % 2 == 0
+		if failed != (res.Error != "") || failed == (res.Bars == 10) {
+			t.Errorf("Bad result for %v: %+v", res.Id, res)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestAnalyzeProductsDefaultWorkers(t *testing.T) {
+	points  := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+	started := make(chan bool, DefaultBatchWorkers *2)
+	release := make(chan bool)
+
+	//--- Fetches block until DefaultBatchWorkers of them are running at the same time
+
+	source := DataSourceFunc(func(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+		started <- true
+		<-release
+		return points, nil
+	})
+
+	var specs []*DataProductAnalysisSpec
+
+	for i := 0; i < DefaultBatchWorkers *2; i++ {
+		specs = append(specs, &DataProductAnalysisSpec{
+			QuerySpec: QuerySpec{
+				Id      : uint(i),
+				From    : "2024-01-01 00:00:00",
+				To      : "2024-12-31 00:00:00",
+				Timezone: "UTC",
+				Config  : buildQueryConfig(),
+			},
+			Source: source,
+		})
+	}
+
+	done := make(chan []*DataProductAnalysisResponse)
+
+	go func() {
+		done <- AnalyzeProducts(&auth.Context{ Log: slog.New(slog.DiscardHandler) }, specs, 0)
+	}()
+
+	for i := 0; i < DefaultBatchWorkers; i++ {
+		select {
+		case <-started:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %d concurrent workers. Got %d", DefaultBatchWorkers, i)
+		}
+	}
+
+	close(release)
+
+	for _, res := range <-done {
+		if res.Error != "" {
+			t.Errorf("Unexpected error: %s", res.Error)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	points := buildDataPoints(buildCloses(1000, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	if _, err := analyzeDataPoints(ctx, points, defaultParams(), nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("A cancelled context must abort the analysis. Got %v", err)
+	}
+
+	ap := defaultParams()
+	if _, err := calcSqnAndAtr(ctx, createBarResults(points, ap), ap, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("A cancelled context must abort the indicators. Got %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisSchemaVersion(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	res, err := analyzeDataPoints(context.Background(), points, defaultParams(), nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.SchemaVersion != AnalysisSchemaVersion {
+		t.Errorf("Bad schema version. Got %d, expected %d", res.SchemaVersion, AnalysisSchemaVersion)
+	}
+}
+
+//=============================================================================
+
+func TestIncrementalAnalysis(t *testing.T) {
+	ap     := defaultParams()
+	closes := buildCloses(400, 100, func(i int) float64 { return (float64(i%7) -3) / 3 })
+	points := buildDataPoints(closes)
+
+	normalized := func(points []*ds.DataPoint, prev *DataProductAnalysisResponse) *DataProductAnalysisResponse {
+		res, err := analyzeDataPoints(context.Background(), points, ap, prev)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		normalizeValues(res)
+		return res
+	}
+
+	//--- Appending bars reuses the previous results
+
+	prev := normalized(points[:350], nil)
+	full := normalized(points, nil)
+	incr := normalized(points, prev)
+
+	if incr.reused != len(prev.BarResults) {
+		t.Errorf("Previous results must be reused. Got %d, expected %d", incr.reused, len(prev.BarResults))
+	}
+
+	if len(incr.BarResults) != len(full.BarResults) {
+		t.Fatalf("Bad bar count. Got %d, expected %d", len(incr.BarResults), len(full.BarResults))
+	}
+
+	for i := range full.BarResults {
+		if *incr.BarResults[i] != *full.BarResults[i] {
+			t.Fatalf("Incremental result differs from full recompute at bar %d: %+v vs %+v", i, incr.BarResults[i], full.BarResults[i])
+		}
+	}
+
+	//--- A disjoint range falls back to a full recompute
+
+	shifted := normalized(points[20:], nil)
+	incr     = normalized(points[20:], prev)
+
+	if incr.reused != 0 {
+		t.Errorf("A disjoint range must not reuse results. Got %d", incr.reused)
+	}
+
+	for i := range shifted.BarResults {
+		if *incr.BarResults[i] != *shifted.BarResults[i] {
+			t.Fatalf("Fallback result differs from full recompute at bar %d", i)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestReturnModes(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%5) -1.5 }))
+
+	simple := defaultParams()
+	logAp  := defaultParams()
+	logAp.ReturnMode = ReturnModeLog
+
+	simpleRes := analyze(points, simple)
+	logRes    := analyze(points, logAp)
+
+	for i, br := range logRes {
+		sr := simpleRes[i]
+
+		if math.Abs(br.BarChangePerc - math.Log(1 + sr.BarChangePerc)) > 1e-12 {
+			t.Fatalf("Log return must be ln(1+r) at bar %d. Got %f, simple %f", i, br.BarChangePerc, sr.BarChangePerc)
+		}
+	}
+
+	//--- SQN must be computed on the selected return series
+
+	last := len(logRes) -1
+	if logRes[last].Sqn100 == simpleRes[last].Sqn100 {
+		t.Errorf("SQN must change with the return mode. Got %f in both modes", logRes[last].Sqn100)
+	}
+
+	//--- Non-positive prices have no log return
+
+	if r := calcReturn(10, 0, ReturnModeLog); r != 0 {
+		t.Errorf("Log return on a zero price must be 0. Got %f", r)
+	}
+
+	if r := calcReturn(-1, 5, ReturnModeLog); r != 0 {
+		t.Errorf("Log return on a negative price must be 0. Got %f", r)
+	}
+
+	if _, err := NewAnalysisParams(&DataProductAnalysisSpec{ ReturnMode: "ratio" }); err == nil {
+		t.Errorf("Unknown return mode must be rejected")
+	}
+}
+
+//=============================================================================
+
+func TestParallelIndicators(t *testing.T) {
+	ap     := defaultParams()
+	points := buildDataPoints(buildCloses(3000, 1000, func(i int) float64 { return float64(i%11) -5 }))
+
+	serial, err := calcBarIndicators(context.Background(), createBarResults(points, ap), ap, nil, false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	parallel, err := calcBarIndicators(context.Background(), createBarResults(points, ap), ap, nil, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(serial) != len(parallel) {
+		t.Fatalf("Bad bar count. Got %d, expected %d", len(parallel), len(serial))
+	}
+
+	for i := range serial {
+		s, p := *serial[i], *parallel[i]
+		s.point, s.prevPoint = nil, nil
+		p.point, p.prevPoint = nil, nil
+
+		if s != p {
+			t.Fatalf("Parallel result differs from the serial one at bar %d: %+v vs %+v", i, p, s)
+		}
+	}
+}
+
+//=============================================================================
+
+func BenchmarkIndicatorsSerial(b *testing.B) {
+	benchmarkIndicators(b, false)
+}
+
+//=============================================================================
+
+func BenchmarkIndicatorsParallel(b *testing.B) {
+	benchmarkIndicators(b, true)
+}
+
+//=============================================================================
+
+func benchmarkIndicators(b *testing.B, parallel bool) {
+	ap     := defaultParams()
+	points := buildDataPoints(buildCloses(5000, 1000, func(i int) float64 { return float64(i%11) -5 }))
+
+	for b.Loop() {
+		list := createBarResults(points, ap)
+		if _, err := calcBarIndicators(context.Background(), list, ap, nil, parallel); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisRawPoints(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	var logs bytes.Buffer
+	c := &auth.Context{ Log: slog.New(slog.NewTextHandler(&logs, nil)) }
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2023-01-02 00:00:00",
+			To      : "2025-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		Source: NewSliceDataSource(points),
+	}
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.RawPoints != len(points) {
+		t.Errorf("Bad raw points. Got %d, expected %d", res.RawPoints, len(points))
+	}
+
+	if !bytes.Contains(logs.Bytes(), []byte("fewer days than expected")) {
+		t.Errorf("A warning must be logged when data is missing. Got logs: %s", logs.String())
+	}
+
+	//--- Enough data, no warning
+
+	logs.Reset()
+	spec.From = "2024-01-01 00:00:00"
+	spec.To   = "2024-12-31 00:00:00"
+
+	if _, err = AnalyzeProduct(c, spec); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if logs.Len() != 0 {
+		t.Errorf("No warning expected. Got logs: %s", logs.String())
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisNoData(t *testing.T) {
+	ap := defaultParams()
+
+	if _, err := analyzeDataPoints(context.Background(), nil, ap, nil); !errors.Is(err, ErrNoData) {
+		t.Errorf("No data must return ErrNoData. Got %v", err)
+	}
+
+	//--- Data consumed by the warm-up is a different error
+
+	points := buildDataPoints(buildCloses(10, 100, func(i int) float64 { return 1 }))
+
+	if _, err := analyzeDataPoints(context.Background(), points, ap, nil); err == nil || errors.Is(err, ErrNoData) {
+		t.Errorf("Too few bars must not return ErrNoData. Got %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestDonchian(t *testing.T) {
+	ap     := defaultParams()
+	points := buildDataPoints(buildCloses(60, 100, func(i int) float64 { return 1 }))
+	list   := createBarResults(points, ap)
+
+	for i, br := range list {
+		if i < ap.DonchLen-1 {
+			if br.DonchianHigh != 0 || br.DonchianLow != 0 {
+				t.Fatalf("Donchian channel must not be set during warm-up at %d", i)
+			}
+			continue
+		}
+
+		//--- In a monotonic uptrend the highest high is today's and the lowest low the oldest one
+
+		if br.DonchianHigh != br.point.High {
+			t.Fatalf("Donchian high must be the current high at %d. Got %v, expected %v", i, br.DonchianHigh, br.point.High)
+		}
+
+		if oldest := list[i - ap.DonchLen +1].point.Low; br.DonchianLow != oldest {
+			t.Fatalf("Donchian low must be the window's first low at %d. Got %v, expected %v", i, br.DonchianLow, oldest)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestWarmupBarsLaggedIndicators(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%5) -1.5 }))
+
+	first := func(spec *DataProductAnalysisSpec) *BarResult {
+		ap, err := NewAnalysisParams(spec)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		res, err := analyzeDataPoints(context.Background(), points, ap, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		return res.BarResults[0]
+	}
+
+	if br := first(&DataProductAnalysisSpec{ Indicators: "adx", SharpeLen: "10" }); br.Adxr == 0 {
+		t.Errorf("The first returned bar must have its ADXR")
+	}
+
+	if br := first(&DataProductAnalysisSpec{ Indicators: "sar", SharpeLen: "10" }); br.Sar == 0 {
+		t.Errorf("The first returned bar must have its SAR")
+	}
+
+	if br := first(&DataProductAnalysisSpec{ Indicators: "sar", SharpeLen: "100" }); br.RollingSharpe == 0 {
+		t.Errorf("The first returned bar must have its rolling Sharpe")
+	}
+}
+
+//=============================================================================
+
+func TestInsufficientHistory(t *testing.T) {
+	points := buildDataPoints(buildCloses(50, 100, func(i int) float64 { return 1 }))
+
+	res, err := analyzeDataPoints(context.Background(), points, defaultParams(), nil)
+	if res != nil || !errors.Is(err, ErrInsufficientHistory) {
+		t.Fatalf("Expected ErrInsufficientHistory. Got %v", err)
+	}
+
+	var ihe *InsufficientHistoryError
+	if !errors.As(err, &ihe) || ihe.Bars != 49 || ihe.Required != DefaultSqnLen + DefaultSqnSlopeLen -1 {
+		t.Errorf("The error must tell the shortfall. Got %+v", ihe)
+	}
+
+	//--- Still a bad request for the service
+
+	var ae req.AppError
+	if !errors.As(err, &ae) || ae.Code != http.StatusBadRequest {
+		t.Errorf("The error must be a bad request. Got %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestIncludePartial(t *testing.T) {
+	ap := defaultParams()
+	ap.IncludePartial = true
+
+	points := buildDataPoints(buildCloses(150, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	res, err := analyzeDataPoints(context.Background(), points, ap, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	//--- Every bar having a previous close is returned
+
+	if res.Bars != len(points) -1 || res.WarmupBars != 1 {
+		t.Fatalf("Bad bars. Got %d (warm-up %d), expected %d", res.Bars, res.WarmupBars, len(points) -1)
+	}
+
+	warmup := ap.warmupBars()
+
+	for i, br := range res.BarResults {
+		if expected := i < warmup-1; br.Partial != expected {
+			t.Fatalf("Bad partial flag at %d. Got %v, expected %v", i, br.Partial, expected)
+		}
+	}
+
+	if res.BarResults[10].AtrMeanPerc == 0 || res.BarResults[10].Sqn100 == 0 {
+		t.Errorf("Partial bars must have SQN and ATR stats on the available window")
+	}
+
+	//--- Full bars are the same as without the option
+
+	full := analyze(points, defaultParams())
+	tail := res.BarResults[len(res.BarResults) - len(full):]
+
+	for i, br := range full {
+		if tail[i].Sqn100 != br.Sqn100 || tail[i].AtrMeanPerc != br.AtrMeanPerc || tail[i].Partial {
+			t.Fatalf("Full bar %d differs from the default analysis", i)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestTypicalAndMedianPrice(t *testing.T) {
+	prev := &ds.DataPoint{ Time: startTime,                     Open: 100, High: 102, Low:  99, Close: 101 }
+	curr := &ds.DataPoint{ Time: startTime.Add(time.Hour * 24), Open: 101, High: 110, Low: 100, Close: 105 }
+
+	list := createBarResults([]*ds.DataPoint{ prev, curr }, defaultParams())
+
+	if len(list) != 1 {
+		t.Fatalf("Bad results. Got %d, expected 1", len(list))
+	}
+
+	if list[0].TypicalPrice != 105 {
+		t.Errorf("Bad typical price. Got %v, expected 105", list[0].TypicalPrice)
+	}
+
+	if list[0].MedianPrice != 105 {
+		t.Errorf("Bad median price. Got %v, expected 105", list[0].MedianPrice)
+	}
+
+	//--- Different values when the close is not in the middle of the range
+
+	curr.Close = 108
+	list = createBarResults([]*ds.DataPoint{ prev, curr }, defaultParams())
+
+	if math.Abs(list[0].TypicalPrice - 106) > 1e-9 || list[0].MedianPrice != 105 {
+		t.Errorf("Bad prices. Got typical %v and median %v, expected 106 and 105", list[0].TypicalPrice, list[0].MedianPrice)
+	}
+}
+
+//=============================================================================
+
+func TestExpectedTradingDaysTimezone(t *testing.T) {
+	rome, err := time.LoadLocation("Europe/Rome")
+	if err != nil {
+		t.Skipf("Timezone data not available: %v", err)
+	}
+
+	//--- Saturday and Sunday in Rome. In UTC the range starts on Friday evening
+
+	from := time.Date(2024, 1, 6,  0, 0, 0, 0, rome)
+	to   := time.Date(2024, 1, 7, 23, 0, 0, 0, rome)
+
+	if days := expectedTradingDays(&from, &to, rome); days != 0 {
+		t.Errorf("A weekend in the local timezone has no trading days. Got %d", days)
+	}
+
+	if days := expectedTradingDays(&from, &to, time.UTC); days != 1 {
+		t.Errorf("In UTC the range starts on Friday. Got %d trading days, expected 1", days)
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisPostProcess(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		Source     : NewSliceDataSource(points),
+		PostProcess: func(list []*BarResult) error {
+			for _, br := range list {
+				br.Beta = 1.23456
+			}
+			return nil
+		},
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, br := range res.BarResults {
+		if br.Beta != 1.2345 {
+			t.Fatalf("Changes made by the callback must be in the response. Got %v", br.Beta)
+		}
+	}
+
+	//--- Errors are propagated
+
+	failure := errors.New("post-process failure")
+	spec.PostProcess = func(list []*BarResult) error { return failure }
+
+	if _, err = AnalyzeProduct(c, spec); !errors.Is(err, failure) {
+		t.Errorf("The callback error must be returned. Got %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisReduction(t *testing.T) {
+	points := buildDataPoints(buildCloses(1000, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id       : 1,
+			From     : "2024-01-01 00:00:00",
+			To       : "2026-09-30 00:00:00",
+			Timezone : "UTC",
+			Reduction: "200",
+			Config   : buildQueryConfig(),
+		},
+		Source: NewSliceDataSource(points),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	//--- 1000 points merged 6 by 6
+
+	if res.Reduction != 200 || !res.Reduced || res.RawPoints != 1000 || res.TotalBars != 166 {
+		t.Errorf("Bad reduction. Got reduction=%d, reduced=%v, raw=%d, total=%d", res.Reduction, res.Reduced, res.RawPoints, res.TotalBars)
+	}
+
+	//--- Invalid values are rejected
+
+	spec.Reduction = "50"
+
+	var ae req.AppError
+	if _, err = AnalyzeProduct(c, spec); !errors.As(err, &ae) || ae.Code != http.StatusBadRequest {
+		t.Errorf("An invalid reduction must be a bad request. Got %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestPercTrueRange(t *testing.T) {
+	points := buildDataPoints(buildCloses(30, 100, func(i int) float64 { return float64(i%4) -1 }))
+	points[10].Close = 0
+
+	list := createBarResults(points, defaultParams())
+
+	for i, br := range list {
+		prevClose := br.prevPoint.Close
+
+		if prevClose == 0 {
+			if br.PercTrueRange != 0 {
+				t.Fatalf("A zero previous close must give no percentage true range at %d", i)
+			}
+			continue
+		}
+
+		if br.PercTrueRange != br.TrueRange / prevClose {
+			t.Fatalf("Bad percentage true range at %d. Got %v, expected %v", i, br.PercTrueRange, br.TrueRange / prevClose)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestEwmaSqn(t *testing.T) {
+	//--- A calm, slightly bullish market followed by a sudden sell-off
+
+	closes := buildCloses(300, 1000, func(i int) float64 {
+		if i >= 290 {
+			return -15
+		}
+		return float64(i%3)
+	})
+
+	points := buildDataPoints(closes)
+
+	simple := analyze(points, defaultParams())
+
+	ap := defaultParams()
+	ap.SqnMethod = SqnMethodEwma
+	ewma := analyze(points, ap)
+
+	before := len(simple) - 12
+	last   := len(simple) - 1
+
+	if simple[before].Sqn100 <= 0 || ewma[before].Sqn100 <= 0 {
+		t.Fatalf("Both SQNs must be bullish before the shock. Got %v and %v", simple[before].Sqn100, ewma[before].Sqn100)
+	}
+
+	//--- The EWMA SQN must fall further after the shock
+
+	dropSimple := simple[before].Sqn100 - simple[last].Sqn100
+	dropEwma   := ewma[before].Sqn100   - ewma[last].Sqn100
+
+	if dropEwma <= dropSimple {
+		t.Errorf("EWMA SQN must react faster. Dropped by %v, simple by %v", dropEwma, dropSimple)
+	}
+
+	if ewma[last].Direction >= simple[last].Direction {
+		t.Errorf("EWMA direction must turn bearish sooner. Got %d, simple %d", ewma[last].Direction, simple[last].Direction)
+	}
+}
+
+//=============================================================================
+
+func TestBarOhlcv(t *testing.T) {
+	points := buildDataPoints(buildCloses(10, 100, func(i int) float64 { return 1 }))
+	points[5].Open, points[5].High, points[5].Low = 103.5, 107.25, 102
+	points[5].UpVolume, points[5].DownVolume      = 1200, 800
+
+	list := createBarResults(points, defaultParams())
+	br   := list[4]
+
+	if !br.Time.Equal(points[5].Time) {
+		t.Fatalf("Bar 4 must come from data point 5")
+	}
+
+	if br.Open != 103.5 || br.High != 107.25 || br.Low != 102 || br.Close != points[5].Close || br.Volume != 2000 {
+		t.Errorf("Bad OHLCV. Got %v/%v/%v/%v/%v", br.Open, br.High, br.Low, br.Close, br.Volume)
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisProgress(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	var stages []string
+	var pcts   []float64
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		Source  : NewSliceDataSource(points),
+		Progress: func(stage string, pct float64) {
+			stages = append(stages, stage)
+			pcts   = append(pcts, pct)
+		},
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	if _, err := AnalyzeProduct(c, spec); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(pcts) < 4 || stages[0] != ProgressFetch || pcts[0] != 0 || stages[len(stages)-1] != ProgressDone || pcts[len(pcts)-1] != 100 {
+		t.Fatalf("Progress must go from the fetch to the end. Got %v %v", stages, pcts)
+	}
+
+	for i := 1; i < len(pcts); i++ {
+		if pcts[i] < pcts[i-1] {
+			t.Fatalf("Progress must never decrease. Got %v", pcts)
+		}
+	}
+
+	//--- Concurrent passes report too
+
+	pcts = nil
+
+	ap := defaultParams()
+	ap.Progress = spec.Progress
+
+	if _, err := calcBarIndicators(context.Background(), createBarResults(points, ap), ap, nil, true); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for i := 1; i < len(pcts); i++ {
+		if pcts[i] < pcts[i-1] {
+			t.Fatalf("Progress must never decrease with concurrent passes. Got %v", pcts)
+		}
+	}
+
+	if len(pcts) == 0 || pcts[len(pcts)-1] != progressIndicatorsEnd {
+		t.Errorf("All passes must be reported. Got %v", pcts)
+	}
+}
+
+//=============================================================================
+
+func TestSqnStdDevMode(t *testing.T) {
+	var list []*BarResult
+	for _, perc := range []float64{ 1, 2, 3, 4 } {
+		list = append(list, &BarResult{ BarChangePerc: perc })
+	}
+
+	//--- mean = 2.5, squared deviations sum to 5
+	//--- population: 2.5 * sqrt(4) / sqrt(5/4) = 4.4721
+	//--- sample    : 2.5 * sqrt(4) / sqrt(5/3) = 3.8730
+
+	if sqn := calcSqn(list, 3, 4, false); math.Abs(sqn - 4.4721) > 0.0001 {
+		t.Errorf("Bad population SQN. Expected 4.4721, got %v", sqn)
+	}
+
+	if sqn := calcSqn(list, 3, 4, true); math.Abs(sqn - 3.8730) > 0.0001 {
+		t.Errorf("Bad sample SQN. Expected 3.8730, got %v", sqn)
+	}
+
+	//--- Population is the default
+
+	if ap := defaultParams(); ap.StdDevMode != StdDevModePopulation {
+		t.Errorf("Default mode must be population. Got %v", ap.StdDevMode)
+	}
+
+	if _, err := NewAnalysisParams(&DataProductAnalysisSpec{ StdDevMode: "unbiased" }); err == nil {
+		t.Errorf("An unknown mode must be rejected")
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisTail(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%5) -2 }))
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		Source: NewSliceDataSource(points),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	full, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	spec.Tail = "10"
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(res.BarResults) != 10 || res.Bars != full.Bars || res.Tail != 10 {
+		t.Fatalf("Bad tail. Got %d results, bars=%d (expected %d), tail=%d", len(res.BarResults), res.Bars, full.Bars, res.Tail)
+	}
+
+	//--- Indicators are computed on the whole history
+
+	checkPage := func(page []*BarResult, expected []*BarResult) {
+		for k, br := range page {
+			exp := expected[k]
+			if !br.Time.Equal(exp.Time) || br.Sqn100 != exp.Sqn100 || br.Rsi != exp.Rsi || br.Sma != exp.Sma {
+				t.Fatalf("Bad result at %d. Got %+v, expected %+v", k, br, exp)
+			}
+		}
+	}
+
+	checkPage(res.BarResults, full.BarResults[full.Bars -10:])
+
+	//--- The offset skips the most recent results
+
+	spec.Offset = "5"
+
+	res, err = AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(res.BarResults) != 10 {
+		t.Fatalf("Bad page size. Got %d", len(res.BarResults))
+	}
+
+	checkPage(res.BarResults, full.BarResults[full.Bars -15 : full.Bars -5])
+
+	//--- A paged response can't be reused
+
+	spec.Tail, spec.Offset = "", ""
+	spec.Previous = res
+
+	res, err = AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.reused != 0 || len(res.BarResults) != full.Bars {
+		t.Errorf("Paged results must not be reused. Got reused=%d, results=%d", res.reused, len(res.BarResults))
+	}
+}
+
+//=============================================================================
+
+func TestLockedBars(t *testing.T) {
+	points := buildDataPoints(buildCloses(40, 100, func(i int) float64 { return 1 }))
+
+	//--- Locked at the previous close: no range at all
+
+	points[30].Close = points[29].Close
+	points[30].Open, points[30].High, points[30].Low = points[30].Close, points[30].Close, points[30].Close
+
+	ap := defaultParams()
+	list := createBarResults(points, ap)
+	locked := list[29]
+
+	if !locked.Locked || locked.TrueRange != 0 {
+		t.Fatalf("Bar must be locked with no true range. Got locked=%v, tr=%v", locked.Locked, locked.TrueRange)
+	}
+
+	for i, br := range list {
+		if br != locked && br.Locked {
+			t.Fatalf("Only the locked bar must be flagged. Got one at %d", i)
+		}
+	}
+
+	//--- By default it lowers the ATR
+
+	if locked.Atr >= list[28].Atr {
+		t.Errorf("Locked bar must be averaged by default. Got %v, previous %v", locked.Atr, list[28].Atr)
+	}
+
+	//--- Skipped, the ATR is the average of the other bars (all with a range of 2)
+
+	ap.AtrSkipLocked = true
+	list = createBarResults(points, ap)
+
+	if list[29].Atr != 2 {
+		t.Errorf("Locked bar must be skipped. Got %v", list[29].Atr)
+	}
+
+	ap.AtrMethod = AtrMethodWilder
+	list = createBarResults(points, ap)
+
+	if list[29].Atr != list[28].Atr {
+		t.Errorf("Locked bar must keep the previous Wilder ATR. Got %v, previous %v", list[29].Atr, list[28].Atr)
+	}
+}
+
+//=============================================================================
+
+func TestPriceField(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%7) -3 }))
+
+	//--- Skewed bars, so that the typical price differs from the close
+
+	for i, dp := range points {
+		dp.High += float64(i%4)
+	}
+
+	closeRes := analyze(points, defaultParams())
+
+	ap := defaultParams()
+	ap.PriceField = PriceFieldTypical
+	typical := analyze(points, ap)
+
+	differs := false
+	moved   := false
+
+	for k, br := range typical {
+		dp  := br.point
+		exp := typicalPrice(dp) / typicalPrice(br.prevPoint) -1
+
+		if math.Abs(br.BarChangePerc - exp) > 1e-12 {
+			t.Fatalf("Change must use the typical price at %d. Got %v, expected %v", k, br.BarChangePerc, exp)
+		}
+
+		//--- The SMA of the last maLen typical prices, ending at this bar
+
+		sum := 0.0
+		for _, p := range points[len(points) - len(typical) + k - ap.MaLen +1 : len(points) - len(typical) + k +1] {
+			sum += typicalPrice(p)
+		}
+
+		if math.Abs(br.Sma - sum / float64(ap.MaLen)) > 1e-9 {
+			t.Fatalf("SMA must use the typical price at %d. Got %v, expected %v", k, br.Sma, sum / float64(ap.MaLen))
+		}
+
+		if br.Close != closeRes[k].Close {
+			t.Fatalf("Close must not change at %d", k)
+		}
+
+		//--- Oscillators, ATR% and the lagging line read the same price
+
+		if math.Abs(br.AtrPerc - br.Atr / typicalPrice(dp)) > 1e-12 {
+			t.Fatalf("ATR%% must use the typical price at %d. Got %v", k, br.AtrPerc)
+		}
+
+		if j := k + ap.IchiKijun; j < len(typical) && br.Chikou != typicalPrice(typical[j].point) {
+			t.Fatalf("Chikou must use the typical price at %d. Got %v", k, br.Chikou)
+		}
+
+		if ap.StochLen == ap.WillrLen && math.Abs(br.WilliamsR - (br.StochK - 100)) > 1e-9 {
+			t.Fatalf("Stochastic and Williams %%R must use the same price at %d. Got %v and %v", k, br.StochK, br.WilliamsR)
+		}
+
+		differs = differs || br.Sqn100 != closeRes[k].Sqn100 || br.Rsi != closeRes[k].Rsi
+		moved   = moved   || br.StochK != closeRes[k].StochK || br.Obv != closeRes[k].Obv
+	}
+
+	if !moved {
+		t.Errorf("Stochastic and OBV must change with the price field")
+	}
+
+	if !differs {
+		t.Errorf("Indicators must change with the price field")
+	}
+
+	if _, err := NewAnalysisParams(&DataProductAnalysisSpec{ PriceField: "vwap" }); err == nil {
+		t.Errorf("An unknown price field must be rejected")
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisOrder(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%5) -2 }))
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		Source: NewSliceDataSource(points),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	asc, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	spec.Order = OrderDesc
+
+	desc, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	n := len(asc.BarResults)
+	if len(desc.BarResults) != n || desc.Order != OrderDesc {
+		t.Fatalf("Bad descending response. Got %d results (expected %d), order=%v", len(desc.BarResults), n, desc.Order)
+	}
+
+	for k, br := range desc.BarResults {
+		exp := asc.BarResults[n-1 -k]
+		if !br.Time.Equal(exp.Time) || br.Close != exp.Close || br.Sqn100 != exp.Sqn100 || br.Rsi != exp.Rsi || br.Ema != exp.Ema {
+			t.Fatalf("Rows must be the same in reverse at %d. Got %+v, expected %+v", k, br, exp)
+		}
+	}
+
+	//--- The tail is still the most recent results
+
+	spec.Tail = "5"
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(res.BarResults) != 5 || !res.BarResults[0].Time.Equal(asc.BarResults[n-1].Time) {
+		t.Errorf("The tail must start from the newest result. Got %v", res.BarResults[0].Time)
+	}
+}
+
+//=============================================================================
+
+func TestReturnSmoothing(t *testing.T) {
+	//--- Noisy returns around a slowly changing trend
+
+	seed   := uint32(7)
+	closes := buildCloses(400, 1000, func(i int) float64 {
+		seed = seed*1664525 + 1013904223
+		return float64(seed>>24)/16 - 8 + 2*math.Sin(float64(i)/20)
+	})
+
+	points := buildDataPoints(closes)
+	raw    := analyze(points, defaultParams())
+
+	ap := defaultParams()
+	ap.ReturnSmoothing = 5
+	smooth := analyze(points, ap)
+
+	//--- Smoothed returns have a lower dispersion, so SQN levels grow: the bar to bar
+	//--- moves are then compared to the spread of the SQN itself
+
+	roughness := func(list []*BarResult) float64 {
+		moves := 0.0
+		for i := 1; i < len(list); i++ {
+			moves += math.Abs(list[i].Sqn100 - list[i-1].Sqn100)
+		}
+
+		_, stdDev := calcMeanAndStdDev(list, len(list)-1, len(list), func(br *BarResult) float64 { return br.Sqn100 })
+		return moves / float64(len(list)-1) / stdDev
+	}
+
+	if r, s := roughness(raw), roughness(smooth); s >= r {
+		t.Errorf("Smoothing must make SQN less jumpy. Got %v, raw %v", s, r)
+	}
+
+	//--- The reported change is not smoothed
+
+	for i := range raw {
+		if raw[i].BarChangePerc != smooth[i].BarChangePerc {
+			t.Fatalf("The bar change must stay raw at %d. Got %v, expected %v", i, smooth[i].BarChangePerc, raw[i].BarChangePerc)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisActualRange(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%5) -2 }))
+
+	//--- The requested range starts a month before the data
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2023-12-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		IncludePartial: "true",
+		Source        : NewSliceDataSource(points),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.RequestedFrom != types.NewDate(2023, 12, 1) || res.RequestedTo != types.NewDate(2024, 12, 31) {
+		t.Errorf("Bad requested range. Got %v..%v", res.RequestedFrom, res.RequestedTo)
+	}
+
+	//--- The first data point only provides the previous close
+
+	if first := types.ToDate(&points[1].Time); res.From != first {
+		t.Errorf("From must be the first bar with a result. Got %v, expected %v", res.From, first)
+	}
+
+	if last := types.ToDate(&points[len(points)-1].Time); res.To != last {
+		t.Errorf("To must be the last bar with a result. Got %v, expected %v", res.To, last)
+	}
+}
+
+//=============================================================================
+
+func TestUnorderedData(t *testing.T) {
+	points := buildDataPoints(buildCloses(10, 100, func(i int) float64 { return 1 }))
+
+	if list, err := checkDataOrder(points, false); err != nil || len(list) != len(points) {
+		t.Fatalf("Sorted data points must be accepted. Got %v", err)
+	}
+
+	//--- Out of order
+
+	swapped := slices.Clone(points)
+	swapped[3], swapped[4] = swapped[4], swapped[3]
+
+	if _, err := checkDataOrder(swapped, false); !errors.Is(err, ErrUnorderedData) {
+		t.Errorf("Out of order data points must fail by default. Got %v", err)
+	}
+
+	list, err := checkDataOrder(swapped, true)
+	if err != nil || len(list) != len(points) || list[3] != points[3] || list[4] != points[4] {
+		t.Errorf("Out of order data points must be sorted when fixing. Got %v", err)
+	}
+
+	//--- Duplicate timestamps: the last one wins
+
+	revised := *points[5]
+	revised.Close = 999
+
+	duplicated := append(slices.Clone(points[:6]), append([]*ds.DataPoint{ &revised }, points[6:]...)...)
+
+	if _, err = checkDataOrder(duplicated, false); !errors.Is(err, ErrUnorderedData) {
+		t.Errorf("Duplicate timestamps must fail by default. Got %v", err)
+	}
+
+	list, err = checkDataOrder(duplicated, true)
+	if err != nil || len(list) != len(points) || list[5].Close != 999 {
+		t.Errorf("Duplicates must be dropped keeping the last one. Got %d points, %v", len(list), err)
+	}
+
+	//--- Through the analysis
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		Source: NewSliceDataSource(swapped),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	if _, err = AnalyzeProduct(c, spec); !errors.Is(err, ErrUnorderedData) {
+		t.Errorf("The analysis must fail on unordered data. Got %v", err)
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisAsOf(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	//--- The source ignores the range and returns everything
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-01-01 00:00:00",
+			AsOf    : "20240815",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		Source: DataSourceFunc(func(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+			return cloneDataPoints(points), nil
+		}),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	asOf := time.Date(2024, 8, 15, 23, 59, 59, 0, time.UTC)
+	exp  := int(asOf.Sub(startTime).Hours() / 24) + 1
+
+	if res.RawPoints != exp {
+		t.Errorf("Bad raw points. Got %d, expected %d", res.RawPoints, exp)
+	}
+
+	for _, br := range res.BarResults {
+		if br.Time.After(asOf) {
+			t.Fatalf("Bars after the reference date must be excluded. Got %v", br.Time)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestIndicatorSelection(t *testing.T) {
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	ap, err := NewAnalysisParams(&DataProductAnalysisSpec{ Indicators: "sqn,rsi" })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if ap.computes(IndicatorAtr) || !ap.computes(IndicatorSqn) {
+		t.Fatalf("Bad selection. Got %s", ap.Indicators)
+	}
+
+	list := analyze(points, ap)
+
+	for _, br := range list {
+		if br.Atr != 0 || br.AtrPerc != 0 || br.AtrMeanPerc != 0 || br.Volatility != 0 || br.MacdLine != 0 {
+			t.Fatalf("Unselected indicators must stay at zero. Got ATR %v (%v%%)", br.Atr, br.AtrPerc)
+		}
+	}
+
+	if last := list[len(list)-1]; last.Sqn100 == 0 || last.Rsi == 0 {
+		t.Errorf("Selected indicators must be computed. Got SQN %v, RSI %v", last.Sqn100, last.Rsi)
+	}
+
+	//--- The Keltner channel needs the ATR
+
+	if ap, err = NewAnalysisParams(&DataProductAnalysisSpec{ Indicators: "kelt" }); err != nil || !ap.computes(IndicatorAtr) {
+		t.Errorf("Keltner must select the ATR. Got %v", err)
+	}
+
+	if _, err = NewAnalysisParams(&DataProductAnalysisSpec{ Indicators: "sqn,foo" }); err == nil {
+		t.Errorf("Unknown indicators must be rejected")
+	}
+
+	if defaultParams().Indicators != AllIndicators {
+		t.Errorf("All indicators must be computed by default")
+	}
+}
+
+//=============================================================================
+
+func TestAnomalyScore(t *testing.T) {
+	ap     := defaultParams()
+	points := buildDataPoints(buildCloses(200, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	//--- Vary the ranges a little, then add a spike
+
+	for i, dp := range points {
+		dp.High += float64(i%4) / 4
+	}
+
+	spike := 150
+	points[spike].High += 20
+
+	list := createBarResults(points, ap)
+
+	//--- Bar results start from the second point
+
+	for i, br := range list {
+		switch {
+		case i == spike-1:
+			if br.AnomalyScore < 10 {
+				t.Errorf("A spike must get a high score. Got %v", br.AnomalyScore)
+			}
+		case i < spike-1 && br.AnomalyScore > 3:
+			t.Errorf("Bad score for a regular bar %d. Got %v", i, br.AnomalyScore)
+		}
+	}
+
+	if list[ap.AtrLen-1].AnomalyScore != 0 {
+		t.Errorf("No score expected without a full window")
+	}
+}
+
+//=============================================================================
+
+func TestZeroBaseline(t *testing.T) {
+	points := buildDataPoints(buildCloses(30, 100, func(i int) float64 { return 1 }))
+	points[10].Close = 0
+
+	//--- Bar results start from the second point
+
+	after := points[11].Time
+
+	for _, mode := range []string{ "", ZeroBaselineZero, ZeroBaselineFlag, ZeroBaselineSkip } {
+		ap, err := NewAnalysisParams(&DataProductAnalysisSpec{ ZeroBaseline: mode })
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		list  := createBarResults(points, ap)
+		found := false
+
+		for _, br := range list {
+			if !br.Time.Equal(after) {
+				if br.ZeroBaseline {
+					t.Errorf("[%s] Only the bar after the zero close must be flagged. Got %v", mode, br.Time)
+				}
+				continue
+			}
+
+			found = true
+
+			if br.BarChangePerc != 0 {
+				t.Errorf("[%s] No change expected without baseline. Got %v", mode, br.BarChangePerc)
+			}
+
+			if br.ZeroBaseline != (mode == ZeroBaselineFlag) {
+				t.Errorf("[%s] Bad zero baseline flag. Got %v", mode, br.ZeroBaseline)
+			}
+		}
+
+		if found == (mode == ZeroBaselineSkip) {
+			t.Errorf("[%s] The bar after the zero close must be dropped only when skipping", mode)
+		}
+	}
+
+	if _, err := NewAnalysisParams(&DataProductAnalysisSpec{ ZeroBaseline: "drop" }); err == nil {
+		t.Errorf("Unknown modes must be rejected")
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisError(t *testing.T) {
+	points  := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 }))
+	failure := errors.New("failure")
+
+	newSpec := func() *DataProductAnalysisSpec {
+		return &DataProductAnalysisSpec{
+			QuerySpec: QuerySpec{
+				Id      : 1,
+				From    : "2024-01-01 00:00:00",
+				To      : "2024-12-31 00:00:00",
+				Timezone: "UTC",
+				Config  : buildQueryConfig(),
+			},
+			Source: NewSliceDataSource(points),
+		}
+	}
+
+	badParams := newSpec()
+	badParams.SqnLen = "1"
+
+	badFetch := newSpec()
+	badFetch.Source = DataSourceFunc(func(ctx context.Context, params *QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+		return nil, failure
+	})
+
+	badCompute := newSpec()
+	badCompute.PostProcess = func(list []*BarResult) error {
+		return failure
+	}
+
+	cases := []struct {
+		spec  *DataProductAnalysisSpec
+		stage string
+	}{
+		{ badParams,  StageValidate },
+		{ badFetch,   StageFetch    },
+		{ badCompute, StageCompute  },
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	for _, tc := range cases {
+		_, err := AnalyzeProduct(c, tc.spec)
+
+		var ae *AnalysisError
+		if !errors.As(err, &ae) {
+			t.Fatalf("[%s] An analysis error is expected. Got %v", tc.stage, err)
+		}
+
+		if ae.Symbol != "ES" || ae.Stage != tc.stage {
+			t.Errorf("[%s] Bad symbol or stage. Got %s, %s", tc.stage, ae.Symbol, ae.Stage)
+		}
+
+		if tc.stage != StageValidate && !errors.Is(err, failure) {
+			t.Errorf("[%s] The original error must be unwrapped. Got %v", tc.stage, err)
+		}
+	}
+
+	//--- Validation errors are still returned as bad requests
+
+	var ae req.AppError
+	if _, err := AnalyzeProduct(c, badParams); !errors.As(err, &ae) || ae.Code != http.StatusBadRequest {
+		t.Errorf("A bad request is expected. Got %v", err)
+	}
+
+	var ve *ValidationError
+	if _, err := AnalyzeProduct(c, badParams); !errors.As(err, &ve) || len(ve.Fields) == 0 {
+		t.Errorf("The validation error must be kept. Got %v", err)
+	}
+
+	//--- In a batch, the failing stage is reported
+
+	list := AnalyzeProducts(c, []*DataProductAnalysisSpec{ newSpec(), badFetch }, 2)
+
+	if list[0].Error != "" || list[1].Stage != StageFetch || list[1].Symbol != "ES" {
+		t.Errorf("Bad batch results. Got %q, %q", list[0].Error, list[1].Stage)
+	}
+}
+
+//=============================================================================
+
+func TestDirectionMargin(t *testing.T) {
+	//--- SQN dithering around the bull threshold
+
+	series := []float64{ 0.70, 0.78, 0.72, 0.80, 0.71, 0.79, 0.90, 0.70, 0.78, 0.66, 0.60 }
+	exp    := []int{ 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 0 }
+
+	dir := DirectionNeutral
+
+	for i, sqn := range series {
+		dir = calcDirectionWithMargin(sqn, dir, 0.1)
+
+		if dir != exp[i] {
+			t.Errorf("Bad direction for sqn=%v at %d. Expected %v but got %v", sqn, i, exp[i], dir)
+		}
+	}
+
+	//--- Through the analysis: fewer flips
+
+	points := buildDataPoints(buildCloses(600, 100, func(i int) float64 { return math.Sin(float64(i) / 7) }))
+
+	flips := func(margin string) int {
+		ap, err := NewAnalysisParams(&DataProductAnalysisSpec{ DirectionMargin: margin })
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		count := 0
+		list  := analyze(points, ap)
+
+		for i := 1; i < len(list); i++ {
+			if list[i].Direction != list[i-1].Direction {
+				count++
+			}
+		}
+
+		return count
+	}
+
+	if raw, held := flips(""), flips("0.3"); held >= raw {
+		t.Errorf("The margin must reduce the direction changes. Got %d, without margin %d", held, raw)
+	}
+}
+
+//=============================================================================
+
+func TestSqnSlope(t *testing.T) {
+	ap := defaultParams()
+
+	//--- Alternating changes with a growing drift: the SQN ramps up
+
+	points := buildDataPoints(buildCloses(300, 100, func(i int) float64 { return float64(i%2*2 -1) + float64(i) * 0.01 }))
+	list   := analyze(points, ap)
+	first  := ap.firstBar()
+	start  := ap.SqnLen + ap.SqnSlopeLen -2
+
+	for k, br := range list {
+		if i := first + k; i < start {
+			if br.SqnSlope != 0 {
+				t.Errorf("Bar %d has fewer than %d SQN values. Got slope %v", i, ap.SqnSlopeLen, br.SqnSlope)
+			}
+		} else if br.SqnSlope <= 0 {
+			t.Errorf("The SQN slope must be positive at bar %d. Got %v", i, br.SqnSlope)
+		}
+	}
+}
+
+//=============================================================================
+
+func TestExtendHistory(t *testing.T) {
+	points := buildDataPoints(buildCloses(400, 100, func(i int) float64 { return float64(i%3) -1 }))
+
+	spec := &DataProductAnalysisSpec{
+		QuerySpec: QuerySpec{
+			Id      : 1,
+			From    : "2024-12-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+			Config  : buildQueryConfig(),
+		},
+		SqnLen: "100",
+		Source: NewSliceDataSource(points),
+	}
+
+	c := &auth.Context{ Log: slog.New(slog.DiscardHandler) }
+
+	//--- 30 days can't cover the warm-up
+
+	if _, err := AnalyzeProduct(c, spec); err == nil {
+		t.Fatalf("A range shorter than the warm-up must fail")
+	}
+
+	//--- Unless the warm-up is fetched before the range
+
+	spec.ExtendHistory = "true"
+
+	res, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(res.BarResults) != 30 || res.Bars != 30 {
+		t.Fatalf("Bad bar count. Got %d (%d), expected 30", len(res.BarResults), res.Bars)
+	}
+
+	from := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	if !res.BarResults[0].Time.After(from) || res.BarResults[0].Partial {
+		t.Errorf("Bad first bar. Got %+v", res.BarResults[0])
+	}
+
+	if res.Bars + res.WarmupBars != res.TotalBars {
+		t.Errorf("Bars and warm-up bars must add up to the total. Got %d + %d, expected %d", res.Bars, res.WarmupBars, res.TotalBars)
+	}
+
+	if res.RequestedFrom != types.ToDate(&from) {
+		t.Errorf("The requested range must be reported. Got %v", res.RequestedFrom)
+	}
+
+	//--- The summary only covers the requested range
+
+	if days := res.UpDays + res.DownDays + res.FlatDays; days != res.Bars {
+		t.Errorf("The summary must skip the extra history. Got %d days, expected %d", days, res.Bars)
+	}
+
+	if res.DrawdownPeak != nil && res.DrawdownPeak.Before(from) {
+		t.Errorf("The drawdown must start in the requested range. Got %v", res.DrawdownPeak)
+	}
+
+	//--- Along with a previous result, which must not be reused when the history differs
+
+	spec.Previous = res
+
+	again, err := AnalyzeProduct(c, spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if again.Checksum != res.Checksum || again.Bars != res.Bars {
+		t.Errorf("Results with a previous one must not change. Got %d bars (%s), expected %d (%s)", again.Bars, again.Checksum, res.Bars, res.Checksum)
+	}
+
+	ap := defaultParams()
+	if prev := *res; sameAnalysisParams(&prev, ap) {
+		t.Errorf("An extended result must not be reused for a spec that is not extended")
+	}
+}
+
+//=============================================================================
+
+func TestClampPercChange(t *testing.T) {
+	closes := buildCloses(300, 100, func(i int) float64 { return float64(i%3) -1 })
+	closes[250] = closes[249] * 1.5
+
+	analyzeClamped := func(clamp string) *DataProductAnalysisResponse {
+		spec := &DataProductAnalysisSpec{
+			QuerySpec: QuerySpec{
+				Id      : 1,
+				Timezone: "UTC",
+				Config  : buildQueryConfig(),
+			},
+			ClampPercChange: clamp,
+			Source         : NewSliceDataSource(buildDataPoints(closes)),
+		}
+
+		res, err := AnalyzeProduct(&auth.Context{ Log: slog.New(slog.DiscardHandler) }, spec)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		return res
+	}
+
+	raw     := analyzeClamped("")
+	clamped := analyzeClamped("10")
+
+	var big, bigRaw *BarResult
+	for i, br := range clamped.BarResults {
+		if br.Time.Equal(startTime.AddDate(0, 0, 250)) {
+			big, bigRaw = br, raw.BarResults[i]
+		}
+	}
+
+	if big == nil {
+		t.Fatalf("Missing the bar with the 50%% move")
+	}
+
+	if big.BarChangePerc != 10 || big.RawChangePerc != 50 {
+		t.Errorf("The change must be clamped for display only. Got %v (raw %v)", big.BarChangePerc, big.RawChangePerc)
+	}
+
+	if bigRaw.BarChangePerc != 50 || bigRaw.RawChangePerc != 50 {
+		t.Errorf("No clamp by default. Got %v (raw %v)", bigRaw.BarChangePerc, bigRaw.RawChangePerc)
+	}
+
+	//--- Indicators still see the true move
+
+	for i, br := range clamped.BarResults {
+		if br.Sqn100 != raw.BarResults[i].Sqn100 || br.AtrPerc != raw.BarResults[i].AtrPerc {
+			t.Fatalf("The clamp must not change the indicators at bar %d", i)
+		}
+	}
+}
+
+//=============================================================================
@@ -24,32 +24,45 @@ import (
 
 const HardLimit = 3000000
 
+//--- How the close of an aggregated bar is computed from the closes of its base bars
+
+const (
+	AggregationLast   = "last"
+	AggregationMedian = "median"
+	AggregationMean   = "mean"
+)
+
 //=============================================================================
 
 type QuerySpec struct {
-	Id        uint
-	From      string
-	To        string
-	DaysBack  string
-	Timezone  string
-	Timeframe string
-	Reduction string
-	Limit     string
-	SessionId uint
-	Config    *core.QueryConfig
+	Id          uint
+	From        string
+	To          string
+	DaysBack    string
+	Timezone    string
+	Timeframe   string
+	Reduction   string
+	Limit       string
+	AsOf        string
+	Aggregation string
+	SessionId   uint
+	Config      *core.QueryConfig `json:"-"`
 }
 
 //=============================================================================
 
 type QueryParams struct {
-	TargetLoc  *time.Location
-	ProductLoc *time.Location
-	From       *time.Time
-	To         *time.Time
-	Reduction  int
-	Limit      int
-	Timeframe  int
-	Aggregator ds.DataAggregator
+	TargetLoc   *time.Location
+	ProductLoc  *time.Location
+	From        *time.Time
+	To          *time.Time
+	Reduction   int
+	Limit       int
+	Timeframe   int
+	Aggregator  ds.DataAggregator
+	Aggregation string
+	CloseFunc   ds.CloseFunc
+	AsOf        *time.Time
 }
 
 //=============================================================================
@@ -70,23 +83,55 @@ func NewQueryParams(spec *QuerySpec) (*QueryParams, error) {
 		return nil, errors.New("Bad 'backDays': " + spec.DaysBack + " (" + err.Error() + ")")
 	}
 
-	var from, to *time.Time
+	from, err := parseTime(spec.From, targLoc)
+	if err != nil {
+		return nil, errors.New("Bad 'from': " + spec.From + " (" + err.Error() + ")")
+	}
 
-	if daysBack > 0 {
-		now := time.Now()
-		back := now.Add(-time.Hour * 24 * time.Duration(daysBack))
-		from = &back
-		to = &now
-	} else {
-		from, err = parseTime(spec.From, targLoc)
-		if err != nil {
-			return nil, errors.New("Bad 'from': " + spec.From + " (" + err.Error() + ")")
+	to, err := parseTime(spec.To, targLoc)
+	if err != nil {
+		return nil, errors.New("Bad 'to': " + spec.To + " (" + err.Error() + ")")
+	}
+
+	asOf, err := parseAsOf(spec.AsOf, targLoc)
+	if err != nil {
+		return nil, errors.New("Bad 'asOf': " + spec.AsOf + " (" + err.Error() + ")")
+	}
+
+	//--- A reference date replays the query as it was run at the end of that day
+
+	now := time.Now()
+
+	if asOf != nil {
+		now = *asOf
+
+		if to != nil && to.After(now) {
+			to = &now
 		}
+	}
 
-		to, err = parseTime(spec.To, targLoc)
-		if err != nil {
-			return nil, errors.New("Bad 'to': " + spec.From + " (" + err.Error() + ")")
+	if from != nil && from.After(now) {
+		if asOf != nil {
+			return nil, errors.New("Bad 'from': " + spec.From + " (must be before 'asOf')")
 		}
+
+		return nil, errors.New("Bad 'from': " + spec.From + " (range is in the future)")
+	}
+
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, errors.New("Bad 'from': " + spec.From + " (must be before 'to')")
+	}
+
+	//--- An explicit range takes precedence over the days back
+
+	if from == nil && to == nil && daysBack > 0 {
+		back := now.Add(-time.Hour * 24 * time.Duration(daysBack))
+		from = &back
+		to   = &now
+	}
+
+	if asOf != nil && to == nil {
+		to = &now
 	}
 
 	timeframe, err := parseTimeframe(spec.Timeframe)
@@ -94,7 +139,13 @@ func NewQueryParams(spec *QuerySpec) (*QueryParams, error) {
 		return nil, errors.New("Bad 'timeframe': " + spec.Timeframe + " (" + err.Error() + ")")
 	}
 
+	closeFunc, err := parseAggregation(spec.Aggregation)
+	if err != nil {
+		return nil, errors.New("Bad 'aggregation': " + spec.Aggregation + " (" + err.Error() + ")")
+	}
+
 	da := buildDataAggregator(timeframe, spec.Config.TradingSession)
+	da.SetCloseFunc(closeFunc)
 
 	red, err := parseReduction(spec.Reduction)
 	if err != nil {
@@ -106,15 +157,23 @@ func NewQueryParams(spec *QuerySpec) (*QueryParams, error) {
 		return nil, errors.New("Bad 'limit': " + spec.Limit + " (" + err.Error() + ")")
 	}
 
+	aggregation := spec.Aggregation
+	if aggregation == "" {
+		aggregation = AggregationLast
+	}
+
 	return &QueryParams{
-		From      : from,
-		To        : to,
-		TargetLoc : targLoc,
-		ProductLoc: prodLoc,
-		Reduction : red,
-		Limit     : lim,
-		Timeframe : timeframe,
-		Aggregator: da,
+		From       : from,
+		To         : to,
+		TargetLoc  : targLoc,
+		ProductLoc : prodLoc,
+		Reduction  : red,
+		Limit      : lim,
+		Timeframe  : timeframe,
+		Aggregator : da,
+		Aggregation: aggregation,
+		CloseFunc  : closeFunc,
+		AsOf       : asOf,
 	}, nil
 }
 
@@ -184,6 +243,21 @@ func parseTime(t string, loc *time.Location) (*time.Time, error) {
 }
 
 //=============================================================================
+//--- The reference date is given as yyyymmdd and covers the whole day
+
+func parseAsOf(value string, loc *time.Location) (*time.Time, error) {
+	date, err := types.ParseIntDate(value, false)
+	if err != nil || date.IsNil() {
+		return nil, err
+	}
+
+	asOf := date.ToDateTime(true, loc).UTC()
+	return &asOf, nil
+}
+
+//=============================================================================
+//--- The reduction is the maximum number of data points to return: 0 (the default)
+//--- returns all of them, otherwise it must be in [100..100000]
 
 func parseReduction(value string) (int, error) {
 	if value == "" {
@@ -231,6 +305,22 @@ func parseLimit(value string) (int, error) {
 	return lim, nil
 }
 
+//=============================================================================
+//--- The default takes the last close, like the aggregators do: no function is needed
+
+func parseAggregation(value string) (ds.CloseFunc, error) {
+	switch value {
+	case "", AggregationLast:
+		return nil, nil
+	case AggregationMedian:
+		return ds.MedianClose, nil
+	case AggregationMean:
+		return ds.MeanClose, nil
+	}
+
+	return nil, errors.New("allowed values are '"+ AggregationLast +"', '"+ AggregationMedian +"' and '"+ AggregationMean +"'")
+}
+
 //=============================================================================
 
 func buildDataAggregator(timeframe int, session *types.TradingSession) ds.DataAggregator {
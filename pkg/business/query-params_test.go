@@ -0,0 +1,161 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package business
+
+import (
+	"testing"
+	"time"
+)
+
+//=============================================================================
+
+func TestQueryParamsExplicitRange(t *testing.T) {
+	spec := &QuerySpec{
+		From     : "2024-01-02 00:00:00",
+		To       : "2024-06-28 00:00:00",
+		DaysBack : "30",
+		Timezone : "UTC",
+		Timeframe: "1440",
+		Config   : buildQueryConfig(),
+	}
+
+	params, err := NewQueryParams(spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	from := time.Date(2024, 1,  2, 0, 0, 0, 0, time.UTC)
+	to   := time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC)
+
+	if !params.From.Equal(from) || !params.To.Equal(to) {
+		t.Errorf("Explicit range must override days back. Got %v..%v", params.From, params.To)
+	}
+
+	//--- Invalid ranges
+
+	spec.From, spec.To = "2024-06-28 00:00:00", "2024-01-02 00:00:00"
+	if _, err = NewQueryParams(spec); err == nil {
+		t.Errorf("'from' after 'to' must be rejected")
+	}
+
+	spec.From, spec.To = time.Now().AddDate(0, 0, 2).Format(time.DateTime), ""
+	if _, err = NewQueryParams(spec); err == nil {
+		t.Errorf("A range in the future must be rejected")
+	}
+}
+
+//=============================================================================
+
+func TestQueryParamsDaysBack(t *testing.T) {
+	spec := &QuerySpec{
+		DaysBack : "30",
+		Timezone : "UTC",
+		Timeframe: "1440",
+		Config   : buildQueryConfig(),
+	}
+
+	params, err := NewQueryParams(spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if params.From == nil || params.To == nil {
+		t.Fatalf("Days back must set the range")
+	}
+
+	if days := params.To.Sub(*params.From).Hours() / 24; days != 30 {
+		t.Errorf("Bad range length. Got %v days, expected 30", days)
+	}
+
+	if time.Since(*params.To) > time.Minute {
+		t.Errorf("Range must end now. Got %v", params.To)
+	}
+}
+
+//=============================================================================
+
+func TestQueryParamsAsOf(t *testing.T) {
+	spec := &QuerySpec{
+		From     : "2024-01-02 00:00:00",
+		To       : "2024-06-28 00:00:00",
+		AsOf     : "20240331",
+		Timezone : "UTC",
+		Timeframe: "1440",
+		Config   : buildQueryConfig(),
+	}
+
+	params, err := NewQueryParams(spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	asOf := time.Date(2024, 3, 31, 23, 59, 59, 0, time.UTC)
+
+	if !params.To.Equal(asOf) || !params.AsOf.Equal(asOf) {
+		t.Errorf("'to' must be capped at the reference date. Got %v", params.To)
+	}
+
+	//--- Days back count from the reference date
+
+	spec.From, spec.To, spec.DaysBack = "", "", "30"
+
+	if params, err = NewQueryParams(spec); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !params.To.Equal(asOf) || !params.From.Equal(asOf.AddDate(0, 0, -30)) {
+		t.Errorf("Days back must end at the reference date. Got %v..%v", params.From, params.To)
+	}
+
+	//--- Invalid values
+
+	spec.From = "2024-04-02 00:00:00"
+	if _, err = NewQueryParams(spec); err == nil {
+		t.Errorf("'from' after the reference date must be rejected")
+	}
+
+	spec.From, spec.AsOf = "", "20241340"
+	if _, err = NewQueryParams(spec); err == nil {
+		t.Errorf("A bad reference date must be rejected")
+	}
+}
+
+//=============================================================================
+
+func TestQueryParamsAggregation(t *testing.T) {
+	spec := &QuerySpec{
+		Timezone : "UTC",
+		Timeframe: "1440",
+		Config   : buildQueryConfig(),
+	}
+
+	params, err := NewQueryParams(spec)
+	if err != nil || params.CloseFunc != nil {
+		t.Fatalf("The last close must be used by default. Got %v", err)
+	}
+
+	spec.Aggregation = AggregationMedian
+
+	if params, err = NewQueryParams(spec); err != nil || params.CloseFunc == nil {
+		t.Fatalf("Expected a close function. Got %v", err)
+	}
+
+	if c := params.CloseFunc([]float64{ 4, 1, 2 }); c != 2 {
+		t.Errorf("Bad median close. Got %v", c)
+	}
+
+	spec.Aggregation = "first"
+	if _, err = NewQueryParams(spec); err == nil {
+		t.Errorf("Unknown aggregations must be rejected")
+	}
+}
+
+//=============================================================================
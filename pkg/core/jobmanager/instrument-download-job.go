@@ -11,6 +11,7 @@
 package jobmanager
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"time"
@@ -182,7 +183,7 @@ func recalcDailyBars(blk *db.DataBlock, session *types.TradingSession, prodLoc *
 	config := ds.NewDataConfig(blk.SystemCode, blk.Symbol)
 	da5m   := ds.NewIdentityAggregator(5)
 
-	err := ds.GetDataPoints(nil, nil, config, prodLoc, da5m, 0)
+	err := ds.GetDataPoints(context.Background(), nil, nil, config, prodLoc, da5m, 0)
 	if err != nil {
 		return err
 	}
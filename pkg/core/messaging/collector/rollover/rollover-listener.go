@@ -11,6 +11,7 @@
 package rollover
 
 import (
+	"context"
 	"errors"
 	"log/slog"
 	"strconv"
@@ -221,7 +222,7 @@ func getPrices(systemCode, symbol string, from time.Time) ([]*ds.DataPoint, erro
 	da     := ds.NewIdentityAggregator(60)
 	to     := from.Add(5 * 24 * time.Hour)
 
-	err := ds.GetDataPoints(&from, &to, config, time.UTC, da, 0)
+	err := ds.GetDataPoints(context.Background(), &from, &to, config, time.UTC, da, 0)
 	if err != nil {
 		return nil, err
 	}
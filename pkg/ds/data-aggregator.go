@@ -13,6 +13,7 @@ package ds
 import (
 	"log/slog"
 	"math"
+	"slices"
 	"strconv"
 	"time"
 
@@ -36,6 +37,39 @@ type DataAggregator interface {
 	Aggregate(daDes DataAggregator)
 	DataPoints() []*DataPoint
 	ToTimezone(loc *time.Location) DataAggregator
+
+	// SetCloseFunc sets how the close of an aggregated bar is computed (the last close when nil)
+	SetCloseFunc(f CloseFunc)
+}
+
+//=============================================================================
+//--- Computes the close of an aggregated bar from the closes of its base bars
+
+type CloseFunc func(closes []float64) float64
+
+//=============================================================================
+
+func MedianClose(closes []float64) float64 {
+	sorted := slices.Clone(closes)
+	slices.Sort(sorted)
+
+	n := len(sorted)
+	if n % 2 == 1 {
+		return sorted[n/2]
+	}
+
+	return (sorted[n/2 -1] + sorted[n/2]) / 2
+}
+
+//=============================================================================
+
+func MeanClose(closes []float64) float64 {
+	sum := 0.0
+	for _, c := range closes {
+		sum += c
+	}
+
+	return sum / float64(len(closes))
 }
 
 //=============================================================================
@@ -47,6 +81,8 @@ type DataAggregator interface {
 type AbstractAggregator struct {
 	currDp     *DataPoint
 	dataPoints []*DataPoint
+	closeFunc  CloseFunc
+	closes     []float64
 }
 
 //=============================================================================
@@ -71,8 +107,8 @@ func (a *AbstractAggregator) Add(dp *DataPoint) {
 
 func (a *AbstractAggregator) Flush() {
 	if a.currDp != nil {
-		a.dataPoints = append(a.dataPoints, a.currDp)
-		a.currDp     = nil
+		a.endBar()
+		a.currDp = nil
 	}
 }
 
@@ -109,6 +145,43 @@ func (a *AbstractAggregator) ToTimezone(loc *time.Location) DataAggregator {
 	return a
 }
 
+//=============================================================================
+
+func (a *AbstractAggregator) SetCloseFunc(f CloseFunc) {
+	a.closeFunc = f
+}
+
+//=============================================================================
+//--- Starts a new bar. The closes are only kept when a close function is set
+
+func (a *AbstractAggregator) startBar(dp *DataPoint) {
+	a.currDp = dp
+
+	if a.closeFunc != nil {
+		a.closes = append(a.closes[:0], dp.Close)
+	}
+}
+
+//=============================================================================
+
+func (a *AbstractAggregator) mergeBar(dp *DataPoint) {
+	merge(a.currDp, dp)
+
+	if a.closeFunc != nil {
+		a.closes = append(a.closes, dp.Close)
+	}
+}
+
+//=============================================================================
+
+func (a *AbstractAggregator) endBar() {
+	if a.closeFunc != nil {
+		a.currDp.Close = a.closeFunc(a.closes)
+	}
+
+	a.dataPoints = append(a.dataPoints, a.currDp)
+}
+
 //=============================================================================
 //===
 //=== SimpleAggregator
@@ -159,13 +232,13 @@ func (a *SimpleAggregator) Add(dp *DataPoint) {
 	dp.Time = dpTime
 
 	if a.currDp == nil {
-		a.currDp = dp
+		a.startBar(dp)
 	} else {
 		if a.currDp.Time.Equal(dpTime) {
-			merge(a.currDp, dp)
+			a.mergeBar(dp)
 		} else {
-			a.dataPoints = append(a.dataPoints, a.currDp)
-			a.currDp = dp
+			a.endBar()
+			a.startBar(dp)
 		}
 	}
 }
@@ -212,7 +285,7 @@ func (a *StandardAggregator) BaseTimeframe() string {
 //=============================================================================
 
 func (a *StandardAggregator) TargetTimeframe() string {
-	return strconv.Itoa(a.base) +"m"
+	return strconv.Itoa(a.target) +"m"
 }
 
 //=============================================================================
@@ -221,7 +294,7 @@ func (a *StandardAggregator) Add(dp *DataPoint) {
 	dpTime := dp.Time
 
 	if a.currDp == nil {
-		a.currDp    = dp
+		a.startBar(dp)
 		a.firstTime = dpTime
 	} else {
 		crossSlots := false
@@ -237,11 +310,11 @@ func (a *StandardAggregator) Add(dp *DataPoint) {
 				a.currDp.Time = a.currDp.Time.Add(time.Duration(rem) * time.Minute)
 			}
 
-			a.dataPoints = append(a.dataPoints, a.currDp)
-			a.currDp     = dp
-			a.firstTime  = dpTime
+			a.endBar()
+			a.startBar(dp)
+			a.firstTime = dpTime
 		} else {
-			merge(a.currDp, dp)
+			a.mergeBar(dp)
 			a.currDp.Time = dpTime
 		}
 	}
@@ -316,13 +389,68 @@ func (a *DailyAggregator) Add(dp *DataPoint) {
 	dpTime := dp.Time
 
 	if a.currDp == nil {
-		a.currDp = dp
+		a.startBar(dp)
 	} else {
 		if a.session.CrossSessions(a.currDp.Time, dpTime) {
-			a.dataPoints = append(a.dataPoints, a.currDp)
-			a.currDp = dp
+			a.endBar()
+			a.startBar(dp)
+		} else {
+			a.mergeBar(dp)
+			a.currDp.Time = dpTime
+		}
+	}
+}
+
+//=============================================================================
+//===
+//=== WeeklyAggregator
+//===
+//=============================================================================
+
+type WeeklyAggregator struct {
+	AbstractAggregator
+}
+
+//=============================================================================
+
+func NewWeeklyAggregator() *WeeklyAggregator {
+	return &WeeklyAggregator{
+		AbstractAggregator: AbstractAggregator{
+			dataPoints: []*DataPoint{},
+		},
+	}
+}
+
+//=============================================================================
+
+func (a *WeeklyAggregator) BaseTimeframe() string {
+	return "1440m"
+}
+
+//=============================================================================
+
+func (a *WeeklyAggregator) TargetTimeframe() string {
+	return "10080m"
+}
+
+//=============================================================================
+
+func (a *WeeklyAggregator) Add(dp *DataPoint) {
+	//--- Data is daily bars. Weeks are ISO weeks, stamped with their last bar's time
+
+	dpTime := dp.Time
+
+	if a.currDp == nil {
+		a.startBar(dp)
+	} else {
+		currYear, currWeek := a.currDp.Time.ISOWeek()
+		year, week         := dpTime.ISOWeek()
+
+		if currYear != year || currWeek != week {
+			a.endBar()
+			a.startBar(dp)
 		} else {
-			merge(a.currDp, dp)
+			a.mergeBar(dp)
 			a.currDp.Time = dpTime
 		}
 	}
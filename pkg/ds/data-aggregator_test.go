@@ -162,3 +162,75 @@ func TestAggregatorBarResync(t *testing.T) {
 }
 
 //=============================================================================
+
+func TestWeeklyAggregator(t *testing.T) {
+	days := []DataPoint{
+		{Time: p("2024-07-11T16:00:00+00:00"), Open: 100, High: 102, Low:  99, Close: 101, UpVolume: 10},
+		{Time: p("2024-07-12T16:00:00+00:00"), Open: 101, High: 103, Low: 100, Close: 102, UpVolume: 10},
+		//--- New week
+		{Time: p("2024-07-15T16:00:00+00:00"), Open: 102, High: 105, Low:  98, Close: 104, UpVolume: 10},
+		{Time: p("2024-07-16T16:00:00+00:00"), Open: 104, High: 106, Low: 103, Close: 103, UpVolume: 10},
+		{Time: p("2024-07-19T16:00:00+00:00"), Open: 103, High: 104, Low:  97, Close: 99,  UpVolume: 10},
+	}
+
+	da := NewWeeklyAggregator()
+	for _, dp := range days {
+		da.Add(&dp)
+	}
+	da.Flush()
+
+	if len(da.dataPoints) != 2 {
+		t.Fatalf("Too few/many data points in a weekly aggregate. Expected %v but got %v", 2, len(da.dataPoints))
+	}
+
+	exp := DataPoint{ Time: p("2024-07-19T16:00:00+00:00"), Open: 102, High: 106, Low: 97, Close: 99, UpVolume: 30 }
+
+	if dp := da.dataPoints[1]; *dp != exp {
+		t.Errorf("Data point %v does not match expected value %v", dp, exp)
+	}
+}
+
+//=============================================================================
+
+func TestAggregatorCloseFunc(t *testing.T) {
+	build := func(f CloseFunc) []*DataPoint {
+		days := []DataPoint{
+			{Time: p("2024-07-15T16:00:00+00:00"), Open: 100, High: 106, Low: 99, Close: 105},
+			{Time: p("2024-07-16T16:00:00+00:00"), Open: 105, High: 106, Low: 99, Close: 100},
+			{Time: p("2024-07-17T16:00:00+00:00"), Open: 100, High: 106, Low: 99, Close: 104},
+			{Time: p("2024-07-18T16:00:00+00:00"), Open: 104, High: 106, Low: 99, Close: 101},
+		}
+
+		da := NewWeeklyAggregator()
+		da.SetCloseFunc(f)
+
+		for _, dp := range days {
+			da.Add(&dp)
+		}
+		da.Flush()
+
+		return da.DataPoints()
+	}
+
+	last   := build(nil)
+	median := build(MedianClose)
+	mean   := build(MeanClose)
+
+	if len(last) != 1 || len(median) != 1 || len(mean) != 1 {
+		t.Fatalf("Expected a single weekly bar. Got %d, %d, %d", len(last), len(median), len(mean))
+	}
+
+	if last[0].Close != 101 || median[0].Close != 102.5 || mean[0].Close != 102.5 {
+		t.Errorf("Bad closes. Got last %v, median %v, mean %v", last[0].Close, median[0].Close, mean[0].Close)
+	}
+
+	if last[0].Open != median[0].Open || last[0].High != median[0].High || last[0].Low != median[0].Low {
+		t.Errorf("Only the close must change. Got %v and %v", last[0], median[0])
+	}
+
+	if c := MedianClose([]float64{ 3, 1, 2 }); c != 2 {
+		t.Errorf("Bad median of an odd count. Got %v", c)
+	}
+}
+
+//=============================================================================
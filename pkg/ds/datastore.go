@@ -117,7 +117,7 @@ func NewDataConfig(systemCode, symbol string) *DataConfig {
 
 //=============================================================================
 
-func GetDataPoints(from *time.Time, to *time.Time, config *DataConfig, prodLoc *time.Location, da DataAggregator, limit int) error {
+func GetDataPoints(ctx context.Context, from *time.Time, to *time.Time, config *DataConfig, prodLoc *time.Location, da DataAggregator, limit int) error {
 	if from == nil {
 		from = &DefaultFrom
 	}
@@ -127,7 +127,7 @@ func GetDataPoints(from *time.Time, to *time.Time, config *DataConfig, prodLoc *
 
 	query := buildGetQuery(da.BaseTimeframe(), config)
 
-	rows, err := pool.Query(context.Background(), query, config.Symbol, config.Selector, from, to)
+	rows, err := pool.Query(ctx, query, config.Symbol, config.Selector, from, to)
 	if err != nil {
 		return req.NewServerErrorByError(err)
 	}
@@ -0,0 +1,139 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/core/auth/role"
+	"github.com/algotiqa/core/auth/roles"
+	"github.com/algotiqa/core/dbms"
+	"github.com/algotiqa/core/req"
+	"github.com/algotiqa/data-collector/pkg/business"
+	"github.com/algotiqa/data-collector/pkg/core"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+//=============================================================================
+//--- Wraps a service verifying the request's token and the user's roles, like
+//--- OidcController.Secure does
+
+type SecureFunc func(h auth.RestService, roles []role.Role) func(c *gin.Context)
+
+//=============================================================================
+//--- Returns the query config of a product, checking that the user can access it
+
+type queryConfigFunc func(c *auth.Context, id uint, sessionConfig string) (*core.QueryConfig, error)
+
+//=============================================================================
+
+type analysisError struct {
+	Code  int    `json:"code"`
+	Error string `json:"error"`
+}
+
+//=============================================================================
+//--- Serves the product analysis over plain HTTP, reading data points from src.
+//--- The spec is the JSON body of the request. The token is verified by secure
+//--- (usually the controller's Secure) and the query configs of the product and
+//--- of its benchmark are built here, after checking the user's access
+
+func NewAnalysisHandler(secure SecureFunc, src business.DataSource) http.Handler {
+	return newAnalysisHandler(secure, src, productQueryConfig)
+}
+
+//=============================================================================
+//===
+//=== Private functions
+//===
+//=============================================================================
+
+func newAnalysisHandler(secure SecureFunc, src business.DataSource, queryConfig queryConfigFunc) http.Handler {
+	router := gin.New()
+
+	router.Any("/*path", secure(func(c *auth.Context) {
+		var spec business.DataProductAnalysisSpec
+
+		if err := json.NewDecoder(c.Gin.Request.Body).Decode(&spec); err != nil {
+			writeAnalysisError(c.Gin.Writer, req.NewBadRequestError("Bad analysis spec: "+ err.Error()))
+			return
+		}
+
+		sessionConfig := c.GetParamAsString("sessionConfig", "")
+
+		for s := &spec; s != nil; s = s.Benchmark {
+			config, err := queryConfig(c, s.Id, sessionConfig)
+			if err != nil {
+				writeAnalysisError(c.Gin.Writer, err)
+				return
+			}
+
+			s.Config = config
+			s.Source = src
+		}
+
+		res, err := business.AnalyzeProduct(c, &spec)
+		if err != nil {
+			writeAnalysisError(c.Gin.Writer, err)
+			return
+		}
+
+		writeAnalysisJSON(c.Gin.Writer, http.StatusOK, res)
+	}, roles.Admin_User_Service))
+
+	return router
+}
+
+//=============================================================================
+
+func productQueryConfig(c *auth.Context, id uint, sessionConfig string) (*core.QueryConfig, error) {
+	var config *core.QueryConfig
+
+	err := dbms.RunInTransaction(func(tx *gorm.DB) error {
+		cfg, err := business.CreateQueryConfigForProduct(c, tx, id, sessionConfig)
+		config = cfg
+		return err
+	})
+
+	return config, err
+}
+
+//=============================================================================
+//--- Validation errors are returned as bad requests, any other error (like a
+//--- failing fetch) as a server error
+
+func writeAnalysisError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+
+	var ae req.AppError
+	if errors.As(err, &ae) {
+		code = ae.Code
+	}
+
+	writeAnalysisJSON(w, code, &analysisError{
+		Code : code,
+		Error: err.Error(),
+	})
+}
+
+//=============================================================================
+
+func writeAnalysisJSON(w http.ResponseWriter, code int, data any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(data)
+}
+
+//=============================================================================
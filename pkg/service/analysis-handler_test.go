@@ -0,0 +1,198 @@
+//=============================================================================
+//===
+//=== Copyright (C) 2025-present Andrea Carboni
+//===
+//=== This source code is licensed under the Elastic License 2.0 (ELv2) available at:
+//=== https://github.com/algotiqa/docs/blob/main/LICENSE.md
+//=== By using this file, you agree to the terms and conditions of that license.
+//=============================================================================
+
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/algotiqa/core/auth"
+	"github.com/algotiqa/core/auth/role"
+	"github.com/algotiqa/core/req"
+	"github.com/algotiqa/data-collector/pkg/business"
+	"github.com/algotiqa/data-collector/pkg/core"
+	"github.com/algotiqa/data-collector/pkg/db"
+	"github.com/algotiqa/data-collector/pkg/ds"
+	"github.com/gin-gonic/gin"
+)
+
+//=============================================================================
+//--- Only the 'good' token is valid
+
+func fakeSecure(h auth.RestService, _ []role.Role) func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if c.Request.Header.Get("Authorization") != "Bearer good" {
+			req.ReturnUnauthorizedError(c, "Authorisation failed while verifying the token")
+			return
+		}
+
+		h(&auth.Context{
+			Gin    : c,
+			Session: &auth.UserSession{ Username: "user" },
+			Log    : slog.New(slog.DiscardHandler),
+			Token  : "good",
+		})
+	}
+}
+
+//=============================================================================
+//--- The user owns product 1 only
+
+func fakeQueryConfig(c *auth.Context, id uint, sessionConfig string) (*core.QueryConfig, error) {
+	if id != 1 {
+		return nil, req.NewForbiddenError("Data product is not owned by user: %v", id)
+	}
+
+	di := &db.DataInstrument{ Symbol: "ES" }
+	dp := &db.DataProduct   { Symbol: "ES", SystemCode: "TS", Timezone: "UTC", Username: c.Session.Username }
+
+	return core.NewQueryConfig(di, dp, nil, nil), nil
+}
+
+//=============================================================================
+
+func buildAnalysisSource() business.DataSource {
+	var list []*ds.DataPoint
+
+	start := time.Date(2024, 1, 1, 16, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 300; i++ {
+		c := 100 + float64(i%5)
+		list = append(list, &ds.DataPoint{
+			Time : start.Add(time.Hour * 24 * time.Duration(i)),
+			Open : c,
+			High : c + 1,
+			Low  : c - 1,
+			Close: c,
+		})
+	}
+
+	return business.NewSliceDataSource(list)
+}
+
+//=============================================================================
+
+func postAnalysis(h http.Handler, body []byte) *httptest.ResponseRecorder {
+	return postAnalysisWithToken(h, body, "good")
+}
+
+//=============================================================================
+
+func postAnalysisWithToken(h http.Handler, body []byte, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/analysis", bytes.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+ token)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+
+	return w
+}
+
+//=============================================================================
+
+func buildAnalysisBody(t *testing.T, id uint, sqnLen string) []byte {
+	spec := &business.DataProductAnalysisSpec{
+		QuerySpec: business.QuerySpec{
+			Id      : id,
+			From    : "2024-01-01 00:00:00",
+			To      : "2024-12-31 00:00:00",
+			Timezone: "UTC",
+		},
+		SqnLen: sqnLen,
+	}
+
+	body, err := json.Marshal(spec)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	return body
+}
+
+//=============================================================================
+
+func TestAnalysisHandler(t *testing.T) {
+	h := newAnalysisHandler(fakeSecure, buildAnalysisSource(), fakeQueryConfig)
+
+	w := postAnalysis(h, buildAnalysisBody(t, 1, ""))
+	if w.Code != http.StatusOK {
+		t.Fatalf("Bad status. Got %d: %s", w.Code, w.Body.String())
+	}
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Bad content type. Got %v", ct)
+	}
+
+	var res business.DataProductAnalysisResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if res.Symbol != "ES" || len(res.BarResults) == 0 {
+		t.Errorf("Bad response. Got symbol=%v, results=%d", res.Symbol, len(res.BarResults))
+	}
+
+	//--- Bad requests
+
+	if w = postAnalysis(h, []byte("{ not json")); w.Code != http.StatusBadRequest {
+		t.Errorf("A malformed body must be a bad request. Got %d", w.Code)
+	}
+
+	if w = postAnalysis(h, buildAnalysisBody(t, 1, "1")); w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "sqnLen") {
+		t.Errorf("An invalid spec must be a bad request. Got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisHandlerAccess(t *testing.T) {
+	h := newAnalysisHandler(fakeSecure, buildAnalysisSource(), fakeQueryConfig)
+
+	if w := postAnalysisWithToken(h, buildAnalysisBody(t, 1, ""), "forged"); w.Code != http.StatusUnauthorized {
+		t.Errorf("An invalid token must be rejected. Got %d", w.Code)
+	}
+
+	if w := postAnalysis(h, buildAnalysisBody(t, 2, "")); w.Code != http.StatusForbidden {
+		t.Errorf("Products of other users must be forbidden. Got %d: %s", w.Code, w.Body.String())
+	}
+
+	//--- The query config comes from the product, never from the body
+
+	body := []byte(`{ "Id": 2, "Config": { "DataConfig": { "Symbol": "NQ" } } }`)
+
+	if w := postAnalysis(h, body); w.Code != http.StatusForbidden {
+		t.Errorf("A config in the body must be ignored. Got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+//=============================================================================
+
+func TestAnalysisHandlerFetchError(t *testing.T) {
+	failing := business.DataSourceFunc(func(ctx context.Context, params *business.QueryParams, config *core.QueryConfig) ([]*ds.DataPoint, error) {
+		return nil, errors.New("datastore is down")
+	})
+
+	w := postAnalysis(newAnalysisHandler(fakeSecure, failing, fakeQueryConfig), buildAnalysisBody(t, 1, ""))
+
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "datastore is down") {
+		t.Errorf("A fetch error must be a server error. Got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+//=============================================================================
@@ -117,15 +117,17 @@ func reloadDataInstrumentData(c *auth.Context) {
 
 func createQuerySpec(c *auth.Context, id uint, config *core.QueryConfig) *business.QuerySpec {
 	return &business.QuerySpec{
-		Id       : id,
-		From     : c.GetParamAsString("from",      ""),
-		To       : c.GetParamAsString("to",        ""),
-		DaysBack : c.GetParamAsString("daysBack",  ""),
-		Timezone : c.GetParamAsString("timezone",  ""),
-		Timeframe: c.GetParamAsString("timeframe", ""),
-		Reduction: c.GetParamAsString("reduction", ""),
-		Limit    : c.GetParamAsString("limit",     ""),
-		Config   : config,
+		Id         : id,
+		From       : c.GetParamAsString("from",        ""),
+		To         : c.GetParamAsString("to",          ""),
+		DaysBack   : c.GetParamAsString("daysBack",    ""),
+		Timezone   : c.GetParamAsString("timezone",    ""),
+		Timeframe  : c.GetParamAsString("timeframe",   ""),
+		Reduction  : c.GetParamAsString("reduction",   ""),
+		Limit      : c.GetParamAsString("limit",       ""),
+		AsOf       : c.GetParamAsString("asOf",        ""),
+		Aggregation: c.GetParamAsString("aggregation", ""),
+		Config     : config,
 	}
 }
 
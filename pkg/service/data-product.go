@@ -18,6 +18,7 @@ import (
 
 	"github.com/algotiqa/core/auth"
 	"github.com/algotiqa/core/dbms"
+	"github.com/algotiqa/core/req"
 	"github.com/algotiqa/data-collector/pkg/business"
 	"github.com/algotiqa/data-collector/pkg/core"
 	"github.com/algotiqa/data-collector/pkg/ds"
@@ -100,6 +101,49 @@ func uploadDataInstrumentData(c *auth.Context) {
 
 func analyzeDataProduct(c *auth.Context) {
 	var result *business.DataProductAnalysisResponse
+	var config, benchConfig *core.QueryConfig
+	var benchmark int
+
+	id, err := c.GetIdFromUrl()
+
+	if err == nil {
+		benchmark, err = c.GetParamAsInt("benchmark", 0)
+	}
+
+	if err == nil {
+		err = dbms.RunInTransaction(func(tx *gorm.DB) error {
+			sessionConfig := c.GetParamAsString("sessionConfig", "")
+			cfg, err1 := business.CreateQueryConfigForProduct(c, tx, id, sessionConfig)
+			config = cfg
+
+			if err1 == nil && benchmark > 0 {
+				benchConfig, err1 = business.CreateQueryConfigForProduct(c, tx, uint(benchmark), sessionConfig)
+			}
+
+			return err1
+		})
+
+		if err == nil {
+			spec := createAnalysisSpec(c, id, config)
+
+			if benchConfig != nil {
+				spec.Benchmark = createAnalysisSpec(c, uint(benchmark), benchConfig)
+			}
+
+			result, err = business.AnalyzeProduct(c, spec)
+			if err == nil {
+				_ = c.ReturnObject(result)
+				return
+			}
+		}
+	}
+
+	c.ReturnError(err)
+}
+
+//=============================================================================
+
+func getLatestDataProductResult(c *auth.Context) {
 	var config *core.QueryConfig
 
 	id, err := c.GetIdFromUrl()
@@ -113,9 +157,8 @@ func analyzeDataProduct(c *auth.Context) {
 		})
 
 		if err == nil {
-			spec   := createQuerySpec(c, id, config)
-			atrLen := c.GetParamAsString("atrLen", "")
-			result, err = business.AnalyzeProduct(c, spec, atrLen)
+			var result *business.BarResult
+			result, err = business.LatestResult(c, createAnalysisSpec(c, id, config))
 			if err == nil {
 				_ = c.ReturnObject(result)
 				return
@@ -126,6 +169,44 @@ func analyzeDataProduct(c *auth.Context) {
 	c.ReturnError(err)
 }
 
+//=============================================================================
+
+func analyzeDataProducts(c *auth.Context) {
+	var specs []*business.DataProductAnalysisSpec
+
+	ids, err := c.GetParamAsInts("id")
+
+	if err == nil {
+		var workers int
+		workers, err = c.GetParamAsInt("workers", business.DefaultBatchWorkers)
+
+		if err == nil {
+			err = dbms.RunInTransaction(func(tx *gorm.DB) error {
+				sessionConfig := c.GetParamAsString("sessionConfig", "")
+
+				for _, id := range ids {
+					cfg, err1 := business.CreateQueryConfigForProduct(c, tx, uint(id), sessionConfig)
+					if err1 != nil {
+						return err1
+					}
+
+					specs = append(specs, createAnalysisSpec(c, uint(id), cfg))
+				}
+
+				return nil
+			})
+
+			if err == nil {
+				list := business.AnalyzeProducts(c, specs, workers)
+				_ = c.ReturnList(list, 0, len(list), len(list))
+				return
+			}
+		}
+	}
+
+	c.ReturnError(err)
+}
+
 //=============================================================================
 //===
 //=== Private methods
@@ -153,3 +234,125 @@ func retrieveUploadSpec(part *multipart.Part) (*business.DatafileUploadSpec, err
 }
 
 //=============================================================================
+
+func correlateDataProducts(c *auth.Context) {
+	var specA, specB *business.DataProductAnalysisSpec
+
+	id, err := c.GetIdFromUrl()
+
+	if err == nil {
+		var with int
+		with, err = c.GetParamAsInt("with", 0)
+
+		if err == nil && with <= 0 {
+			err = req.NewBadRequestError("Missing 'with' parameter")
+		}
+
+		if err == nil {
+			err = dbms.RunInTransaction(func(tx *gorm.DB) error {
+				sessionConfig := c.GetParamAsString("sessionConfig", "")
+
+				cfgA, err1 := business.CreateQueryConfigForProduct(c, tx, id, sessionConfig)
+				if err1 != nil {
+					return err1
+				}
+
+				cfgB, err1 := business.CreateQueryConfigForProduct(c, tx, uint(with), sessionConfig)
+				if err1 != nil {
+					return err1
+				}
+
+				specA = createAnalysisSpec(c, id, cfgA)
+				specB = createAnalysisSpec(c, uint(with), cfgB)
+				return nil
+			})
+
+			if err == nil {
+				var result *business.ProductCorrelation
+				result, err = business.CorrelateProducts(c, specA, specB)
+				if err == nil {
+					_ = c.ReturnObject(result)
+					return
+				}
+			}
+		}
+	}
+
+	c.ReturnError(err)
+}
+
+//=============================================================================
+
+func createAnalysisSpec(c *auth.Context, id uint, config *core.QueryConfig) *business.DataProductAnalysisSpec {
+	return &business.DataProductAnalysisSpec{
+		QuerySpec : *createQuerySpec(c, id, config),
+		SqnLen    : c.GetParamAsString("sqnLen",     ""),
+		SqnMethod : c.GetParamAsString("sqnMethod",  ""),
+		SqnDecay  : c.GetParamAsString("sqnDecay",   ""),
+		StdDevMode: c.GetParamAsString("stdDevMode", ""),
+		AtrLen    : c.GetParamAsString("atrLen",     ""),
+		AtrMethod : c.GetParamAsString("atrMethod",  ""),
+		RsiLen    : c.GetParamAsString("rsiLen",     ""),
+		MaLen     : c.GetParamAsString("maLen",      ""),
+		MacdFast  : c.GetParamAsString("macdFast",   ""),
+		MacdSlow  : c.GetParamAsString("macdSlow",   ""),
+		MacdSignal: c.GetParamAsString("macdSignal", ""),
+		BollLen   : c.GetParamAsString("bollLen",    ""),
+		BollK     : c.GetParamAsString("bollK",      ""),
+		KeltLen   : c.GetParamAsString("keltLen",    ""),
+		KeltK     : c.GetParamAsString("keltK",      ""),
+		DonchLen  : c.GetParamAsString("donchLen",   ""),
+		AdxLen    : c.GetParamAsString("adxLen",     ""),
+		VwapLen   : c.GetParamAsString("vwapLen",    ""),
+		MfiLen    : c.GetParamAsString("mfiLen",     ""),
+		CmfLen    : c.GetParamAsString("cmfLen",     ""),
+		RocLen    : c.GetParamAsString("rocLen",     ""),
+		CciLen    : c.GetParamAsString("cciLen",     ""),
+		StochLen  : c.GetParamAsString("stochLen",   ""),
+		WillrLen  : c.GetParamAsString("willrLen",   ""),
+		TrixLen   : c.GetParamAsString("trixLen",    ""),
+		RegLen    : c.GetParamAsString("regLen",     ""),
+		SarStart  : c.GetParamAsString("sarStart",   ""),
+		SarStep   : c.GetParamAsString("sarStep",    ""),
+		SarMax    : c.GetParamAsString("sarMax",     ""),
+		IchiTenkan: c.GetParamAsString("ichiTenkan", ""),
+		IchiKijun : c.GetParamAsString("ichiKijun",  ""),
+		IchiSenkou: c.GetParamAsString("ichiSenkou", ""),
+		DetectGaps: c.GetParamAsString("detectGaps", ""),
+		Calendar  : c.GetParamAsString("calendar",   ""),
+
+		RiskFreeRate: c.GetParamAsString("riskFreeRate", ""),
+		ReturnMode  : c.GetParamAsString("returnMode",   ""),
+		PriceField  : c.GetParamAsString("priceField",   ""),
+		MinRegimeLen: c.GetParamAsString("minRegimeLen", ""),
+		BetaLen     : c.GetParamAsString("betaLen",      ""),
+		OutlierSigma: c.GetParamAsString("outlierSigma", ""),
+		OutlierMode : c.GetParamAsString("outlierMode",  ""),
+		AutoCorrLags: c.GetParamAsString("autoCorrLags", ""),
+		SharpeLen   : c.GetParamAsString("sharpeLen",    ""),
+		SqnSlopeLen : c.GetParamAsString("sqnSlopeLen",  ""),
+		Winsorize   : c.GetParamAsString("winsorize",    ""),
+
+		IncludePartial : c.GetParamAsString("includePartial",  ""),
+		ExtendHistory  : c.GetParamAsString("extendHistory",   ""),
+		AtrSkipLocked  : c.GetParamAsString("atrSkipLocked",   ""),
+		PeriodsPerYear : c.GetParamAsString("periodsPerYear",  ""),
+		Tail           : c.GetParamAsString("tail",            ""),
+		Offset         : c.GetParamAsString("offset",          ""),
+		Order          : c.GetParamAsString("order",           ""),
+		ComparePrior   : c.GetParamAsString("comparePrior",    ""),
+		NonFiniteValue : c.GetParamAsString("nonFiniteValue",  ""),
+		RelStrengthFill: c.GetParamAsString("relStrengthFill", ""),
+		ReturnSmoothing: c.GetParamAsString("returnSmoothing", ""),
+		FixOrder       : c.GetParamAsString("fixOrder",        ""),
+		Indicators     : c.GetParamAsString("indicators",      ""),
+		ZeroBaseline   : c.GetParamAsString("zeroBaseline",    ""),
+		DirectionMargin: c.GetParamAsString("directionMargin", ""),
+		GapFill        : c.GetParamAsString("gapFill",         ""),
+		ClampPercChange: c.GetParamAsString("clampPercChange", ""),
+		SessionStart   : c.GetParamAsString("sessionStart",    ""),
+		SessionEnd     : c.GetParamAsString("sessionEnd",      ""),
+	}
+}
+
+//=============================================================================
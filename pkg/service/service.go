@@ -17,6 +17,7 @@ import (
 	"github.com/algotiqa/core/auth/roles"
 	"github.com/algotiqa/core/req"
 	"github.com/algotiqa/data-collector/pkg/app"
+	"github.com/algotiqa/data-collector/pkg/business"
 	"github.com/gin-gonic/gin"
 )
 
@@ -26,6 +27,8 @@ func Init(router *gin.Engine, cfg *app.Config, logger *slog.Logger) {
 
 	ctrl := auth.NewOidcController(cfg.Authentication.Authority, req.GetDefaultClient(), logger, cfg)
 
+	business.SetDataPointCache(business.NewDataPointCache(business.DefaultDataPointCacheTTL, nil))
+
 	router.GET  ("/api/collector/v1/config/parsers",                 ctrl.Secure(getParsers, roles.Admin_User_Service))
 
 	router.GET   ("/api/collector/v1/data-instruments",              ctrl.Secure(getDataInstruments,            roles.Admin_User_Service))
@@ -33,9 +36,12 @@ func Init(router *gin.Engine, cfg *app.Config, logger *slog.Logger) {
 	router.GET   ("/api/collector/v1/data-instruments/:id/data",     ctrl.Secure(getDataInstrumentData,         roles.Admin_User_Service))
 	router.POST  ("/api/collector/v1/data-instruments/:id/reload",   ctrl.Secure(reloadDataInstrumentData,      roles.Admin_User_Service))
 
+	router.GET   ("/api/collector/v1/data-products/analysis",        ctrl.Secure(analyzeDataProducts,           roles.Admin_User_Service))
 	router.GET   ("/api/collector/v1/data-products/:id/instruments", ctrl.Secure(getDataInstrumentsByProductId, roles.Admin_User_Service))
 	router.POST  ("/api/collector/v1/data-products/:id/instruments", ctrl.Secure(uploadDataInstrumentData,      roles.Admin_User_Service))
 	router.GET   ("/api/collector/v1/data-products/:id/analysis",    ctrl.Secure(analyzeDataProduct,            roles.Admin_User_Service))
+	router.GET   ("/api/collector/v1/data-products/:id/latest",      ctrl.Secure(getLatestDataProductResult,    roles.Admin_User_Service))
+	router.GET   ("/api/collector/v1/data-products/:id/correlation", ctrl.Secure(correlateDataProducts,         roles.Admin_User_Service))
 
 	router.GET   ("/api/collector/v1/bias-analyses",                  ctrl.Secure(getBiasAnalyses,     roles.Admin_User_Service))
 	router.POST  ("/api/collector/v1/bias-analyses",                  ctrl.Secure(addBiasAnalysis,     roles.Admin_User_Service))